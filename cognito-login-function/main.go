@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -151,10 +152,25 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, fmt.Errorf("failed to marshal response: %v", err)
 	}
 
-	token, err := util.GenerateAccessToken()
+	if res.AuthenticationResult == nil {
+		// Cognito answered with a challenge instead of tokens
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       fmt.Sprintf("Authentication challenge required: %s", aws.StringValue(res.ChallengeName)),
+		}, nil
+	}
+
+	// Derive the session claims from the Cognito ID token
+	claims, err := sessionClaimsFromIDToken(aws.StringValue(res.AuthenticationResult.IdToken))
 	if err != nil {
-		log.Println("Failed to generate access token:", err)
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, fmt.Errorf("failed to generate access token: %v", err)
+		log.Println("Failed to read ID token:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, fmt.Errorf("failed to read ID token: %v", err)
+	}
+
+	token, err := util.GenerateSessionToken(claims, util.SessionTokenTTL())
+	if err != nil {
+		log.Println("Failed to generate session token:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, fmt.Errorf("failed to generate session token: %v", err)
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -167,6 +183,52 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		}, nil
 }
 
+// sessionClaimsFromIDToken reads the identity of the user from the payload of the Cognito ID token.
+// The token was just returned by Cognito itself, so its signature is not checked again here.
+func sessionClaimsFromIDToken(idToken string) (util.SessionClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return util.SessionClaims{}, fmt.Errorf("malformed ID token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return util.SessionClaims{}, fmt.Errorf("failed to decode ID token payload: %w", err)
+	}
+
+	var idClaims struct {
+		Subject  string   `json:"sub"`
+		Username string   `json:"cognito:username"`
+		Email    string   `json:"email"`
+		Groups   []string `json:"cognito:groups"`
+		// Text messages only go to verified phone numbers
+		PhoneNumber         string `json:"phone_number"`
+		PhoneNumberVerified bool   `json:"phone_number_verified"`
+		// The congregation is a custom attribute only admins can change
+		CongregationId string `json:"custom:congregation_id"`
+	}
+	err = json.Unmarshal(payload, &idClaims)
+	if err != nil {
+		return util.SessionClaims{}, fmt.Errorf("failed to parse ID token payload: %w", err)
+	}
+
+	phoneNumber := ""
+	if idClaims.PhoneNumberVerified {
+		phoneNumber = idClaims.PhoneNumber
+	}
+
+	return util.SessionClaims{
+		Subject:     idClaims.Subject,
+		Username:    idClaims.Username,
+		Email:       idClaims.Email,
+		PhoneNumber: phoneNumber,
+		Groups:      idClaims.Groups,
+		Scopes:      []string{util.ScopeRead, util.ScopeWrite},
+		// Sessions are bound to the user's congregation
+		CongregationId: idClaims.CongregationId,
+	}, nil
+}
+
 func main() {
 	lambda.Start(Handler)
 }
@@ -199,4 +199,9 @@ func TestLogin(t *testing.T) {
 	assert.Nil(t, err, "Expected no error")
 	assert.Equal(t, 200, response.StatusCode, "Expected status code 200")
 	assert.Equal(t, "Authentication successful", loginResponse.Message, "Expected success message")
+
+	// The auth header carries a signed session token for the user
+	claims, err := util.VerifySessionToken(response.Headers["auth"])
+	assert.NoError(t, err, "Expected a valid session token")
+	assert.Equal(t, "testuser", claims.Username, "Expected session token for the test user")
 }
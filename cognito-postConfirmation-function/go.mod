@@ -0,0 +1,23 @@
+module github.com/mildnl/congregation-noticeboard-backend/cognito-postConfirmation-function
+
+go 1.20
+
+require (
+	github.com/aws/aws-lambda-go v1.41.0
+	github.com/joho/godotenv v1.5.1
+	github.com/mildnl/congregation-noticeboard-backend/util v0.0.0-20230628210507-cae21b9cbac5
+	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/aws/aws-sdk-go v1.44.284 // indirect
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/gorilla/css v1.0.1 // indirect
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/microcosm-cc/bluemonday v1.0.27 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/yuin/goldmark v1.7.1 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
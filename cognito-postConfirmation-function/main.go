@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// congregationAttribute is the Cognito custom attribute holding the user's congregation
+const congregationAttribute = "custom:congregation_id"
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler is the post confirmation trigger of the user pool. When a user confirms their
+// sign-up, it stores the default notification preferences under their Cognito sub and
+// publishes the UserConfirmed event. Users are confirmed even if that fails; they can
+// still save preferences themselves.
+func Handler(ctx context.Context, event events.CognitoEventUserPoolsPostConfirmation) (events.CognitoEventUserPoolsPostConfirmation, error) {
+	if event.TriggerSource != "PostConfirmation_ConfirmSignUp" {
+		return event, nil
+	}
+
+	attributes := event.Request.UserAttributes
+	preferences := util.DefaultPreferences(attributes["sub"], attributes["email"], attributes[congregationAttribute])
+	if attributes["phone_number_verified"] == "true" {
+		preferences.PhoneNumber = attributes["phone_number"]
+	}
+	err := util.Preferences.CreatePreferences(ctx, preferences)
+	if err != nil && !errors.Is(err, util.ErrPreferencesExist) {
+		log.Printf("Failed to create preferences for %s: %v", event.UserName, err)
+	}
+
+	// Tell other applications about the new member
+	confirmed, err := util.NewDomainEvent(util.DomainUserConfirmed, util.UserEventData{
+		UserId:         attributes["sub"],
+		Username:       event.UserName,
+		CongregationId: attributes[congregationAttribute],
+	})
+	if err == nil {
+		err = util.PublishDomainEvents(ctx, confirmed)
+	}
+	if err != nil {
+		log.Printf("Failed to publish confirmation of %s: %v", event.UserName, err)
+	}
+	return event, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
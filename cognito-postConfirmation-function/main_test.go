@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the preferences and events in memory for the tests
+	util.Preferences = util.NewMemoryPreferencesStore()
+	util.Outbox = util.NewMemoryOutboxStore()
+	util.Bus = &util.MemoryDomainEventBus{}
+
+	os.Exit(m.Run())
+}
+
+func confirmation(triggerSource, sub string) events.CognitoEventUserPoolsPostConfirmation {
+	var event events.CognitoEventUserPoolsPostConfirmation
+	event.TriggerSource = triggerSource
+	event.UserName = "jane"
+	event.Request.UserAttributes = map[string]string{
+		"sub":                    sub,
+		"email":                  "jane@example.org",
+		"phone_number":           "+31612345678",
+		"phone_number_verified":  "true",
+		"custom:congregation_id": "north",
+	}
+	return event
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+
+	// Confirmed users start with the default preferences
+	event := confirmation("PostConfirmation_ConfirmSignUp", "sub-1")
+	result, err := Handler(ctx, event)
+	assert.NoError(t, err)
+	assert.Equal(t, event, result)
+
+	preferences, err := util.Preferences.GetPreferences(ctx, "sub-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "jane@example.org", preferences.Email)
+	assert.Equal(t, "north", preferences.CongregationId)
+	assert.Equal(t, "+31612345678", preferences.PhoneNumber)
+	assert.Equal(t, []string{util.ChannelEmail}, preferences.Channels)
+	assert.Equal(t, util.DigestNone, preferences.Digest)
+
+	// and other applications hear about them
+	published := util.Bus.(*util.MemoryDomainEventBus).Published()
+	assert.Len(t, published, 1)
+	assert.Equal(t, util.DomainUserConfirmed, published[0].Type)
+	assert.JSONEq(t, `{"user_id": "sub-1", "username": "jane", "congregation_id": "north"}`, string(published[0].Data))
+
+	// Preferences the user already saved are kept
+	preferences.Digest = util.DigestWeekly
+	err = util.Preferences.PutPreferences(ctx, preferences)
+	assert.NoError(t, err)
+	_, err = Handler(ctx, event)
+	assert.NoError(t, err)
+	preferences, err = util.Preferences.GetPreferences(ctx, "sub-1")
+	assert.NoError(t, err)
+	assert.Equal(t, util.DigestWeekly, preferences.Digest)
+
+	// Password resets also confirm users, but are not sign-ups
+	_, err = Handler(ctx, confirmation("PostConfirmation_ConfirmForgotPassword", "sub-2"))
+	assert.NoError(t, err)
+	preferences, err = util.Preferences.GetPreferences(ctx, "sub-2")
+	assert.NoError(t, err)
+	assert.Nil(t, preferences)
+	assert.Len(t, util.Bus.(*util.MemoryDomainEventBus).Published(), 2)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -11,16 +12,17 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	cognito "github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
 	"github.com/joho/godotenv"
 )
 
 type User struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Email    string `json:"email"`
-	family_name string `json:"family_name"`
-	given_name string `json:"given_name"`
-	phone_number string `json:"phone_number"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Email       string `json:"email"`
+	FamilyName  string `json:"family_name"`
+	GivenName   string `json:"given_name"`
+	PhoneNumber string `json:"phone_number"`
 }
 
 func init() {
@@ -60,24 +62,36 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 			},
 			{
 				Name:  aws.String("family_name"),
-				Value: aws.String(user.family_name),
+				Value: aws.String(user.FamilyName),
 			},
 			{
 				Name:  aws.String("given_name"),
-				Value: aws.String(user.given_name),
+				Value: aws.String(user.GivenName),
 			},
 			{
 				Name:  aws.String("phone_number"),
-				Value: aws.String(user.phone_number),
+				Value: aws.String(user.PhoneNumber),
 			},
 		},
 	}
 
-	_, err = client.SignUp(input)
+	result, err := client.SignUp(input)
 	if err != nil {
 		return events.APIGatewayProxyResponse{StatusCode: 500}, err
 	}
 
+	// Tell other applications about the new user; the sign-up stands if this fails
+	registered, err := util.NewDomainEvent(util.DomainUserRegistered, util.UserEventData{
+		UserId:   aws.StringValue(result.UserSub),
+		Username: user.Username,
+	})
+	if err == nil {
+		err = util.PublishDomainEvents(ctx, registered)
+	}
+	if err != nil {
+		log.Printf("Failed to publish registration of %s: %v", user.Username, err)
+	}
+
 	// Return a successful response
 	response := map[string]string{
 		"message": "User registration successful",
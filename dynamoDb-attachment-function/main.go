@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// UploadRequest describes the file a client wants to attach
+type UploadRequest struct {
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// UploadResponse tells the client where to upload the file
+type UploadResponse struct {
+	UploadURL  string          `json:"upload_url"`
+	ExpiresAt  time.Time       `json:"expires_at"`
+	Attachment util.Attachment `json:"attachment"`
+}
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler adds attachments to a notice (POST /notices/{id}/attachments) and
+// removes them again (DELETE /notices/{id}/attachments/{attachment_id})
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Read the notice ID from the path parameter
+	id, err := util.NoticeIdFromRequest(events.APIGatewayProxyRequest{PathParameters: request.PathParameters})
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	// Get the notice from DynamoDB
+	notice, err := util.Notices.GetNotice(ctx, id)
+	if err != nil {
+		log.Println("Failed to get notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	claims, _ := util.SessionFromContext(ctx)
+	if notice == nil || !util.NoticeVisibleTo(claims, notice, time.Now()) {
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found")
+	}
+	if !util.CanEditNotice(claims, notice) {
+		return util.ErrorResponse(http.StatusForbidden, "Notice is under review or published")
+	}
+
+	switch request.HTTPMethod {
+	case http.MethodPost:
+		return addAttachment(ctx, claims, notice, request.Body)
+	case http.MethodDelete:
+		return removeAttachment(ctx, claims, notice, request.PathParameters["attachment_id"])
+	}
+	return util.ErrorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+func addAttachment(ctx context.Context, claims *util.SessionClaims, notice *util.Notice, body string) (events.APIGatewayProxyResponse, error) {
+	// Parse the request body
+	var upload UploadRequest
+	err := json.Unmarshal([]byte(body), &upload)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	// Check the file against the type and size limits
+	attachment, err := util.NewAttachment(notice.ID, upload.FileName, upload.ContentType, upload.Size)
+	var invalid *util.ValidationError
+	if errors.As(err, &invalid) {
+		return util.ErrorResponse(http.StatusBadRequest, invalid.Message)
+	}
+	if err != nil {
+		log.Println("Failed to create attachment:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// The presigned URL only accepts this content type and size
+	uploadURL, err := util.Objects.PresignPut(ctx, attachment.Key, attachment.ContentType, attachment.Size, util.AttachmentURLTTL)
+	if err != nil {
+		log.Println("Failed to presign upload:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// Record the attachment on the notice
+	notice.Attachments = append(notice.Attachments, *attachment)
+	response, err := saveAttachments(ctx, claims, notice)
+	if response != nil {
+		return *response, err
+	}
+
+	return util.JSONResponse(http.StatusCreated, UploadResponse{
+		UploadURL:  uploadURL,
+		ExpiresAt:  time.Now().UTC().Add(util.AttachmentURLTTL),
+		Attachment: *attachment,
+	})
+}
+
+func removeAttachment(ctx context.Context, claims *util.SessionClaims, notice *util.Notice, attachmentId string) (events.APIGatewayProxyResponse, error) {
+	attachment := notice.Attachment(attachmentId)
+	if attachment == nil {
+		return util.ErrorResponse(http.StatusNotFound, "Attachment not found")
+	}
+	keys := attachment.Keys()
+
+	// Drop the attachment from the notice
+	var kept []util.Attachment
+	for _, a := range notice.Attachments {
+		if a.Id != attachmentId {
+			kept = append(kept, a)
+		}
+	}
+	notice.Attachments = kept
+	response, err := saveAttachments(ctx, claims, notice)
+	if response != nil {
+		return *response, err
+	}
+
+	// Then delete the file and its variants so they are not left behind
+	err = util.Objects.DeleteObjects(ctx, keys)
+	if err != nil {
+		log.Println("Failed to delete attachment:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusOK, notice)
+}
+
+// saveAttachments writes the notice's attachment list, returning a response only if that failed
+func saveAttachments(ctx context.Context, claims *util.SessionClaims, notice *util.Notice) (*events.APIGatewayProxyResponse, error) {
+	expectedVersion := notice.Version
+	notice.UpdatedAt = time.Now().UTC()
+
+	updated, err := util.Notices.UpdateNotice(ctx, notice, []string{"attachments"}, expectedVersion, claims.Username)
+	if errors.Is(err, util.ErrConflict) {
+		response, err := util.ErrorResponse(http.StatusConflict, "Notice was changed by someone else, please try again")
+		return &response, err
+	}
+	if err != nil {
+		log.Println("Failed to update notice:", err)
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	*notice = *updated
+	return nil, nil
+}
+
+func main() {
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeWrite, Handler))
+}
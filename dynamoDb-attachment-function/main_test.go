@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+var objects *util.MemoryObjectStore
+
+func TestMain(m *testing.M) {
+	// Keep notices and files in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+	objects = util.NewMemoryObjectStore()
+	util.Objects = objects
+
+	os.Exit(m.Run())
+}
+
+func coordinatorContext() context.Context {
+	return util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject:  "coordinator-sub",
+		Username: "coordinator",
+		Groups:   []string{util.GroupCoordinators},
+		Scopes:   []string{util.ScopeRead, util.ScopeWrite},
+	})
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	err := util.Notices.SaveNotice(ctx, &util.Notice{ID: 1, Title: "Cleaning rota"}, 0, "coordinator")
+	assert.NoError(t, err)
+
+	// Ask for an upload URL
+	response, err := Handler(coordinatorContext(), events.APIGatewayProxyRequest{
+		HTTPMethod:     "POST",
+		PathParameters: map[string]string{"id": "1"},
+		Body:           `{"file_name": "June rota.pdf", "content_type": "application/pdf", "size": 52000}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 201, response.StatusCode)
+
+	var upload UploadResponse
+	err = json.Unmarshal([]byte(response.Body), &upload)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^notices/1/[0-9a-f]{16}/June-rota\.pdf$`, upload.Attachment.Key)
+	assert.Contains(t, upload.UploadURL, "content-type=application%2Fpdf")
+	assert.Contains(t, upload.UploadURL, "content-length=52000")
+
+	// The attachment is recorded on the notice
+	notice, err := util.Notices.GetNotice(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, notice.Attachments, 1)
+	assert.Equal(t, "June rota.pdf", notice.Attachments[0].FileName)
+	assert.Empty(t, notice.Attachments[0].URL)
+
+	// Remove it again, together with the uploaded file
+	objects.Put(upload.Attachment.Key, []byte("%PDF"))
+	response, err = Handler(coordinatorContext(), events.APIGatewayProxyRequest{
+		HTTPMethod:     "DELETE",
+		PathParameters: map[string]string{"id": "1", "attachment_id": upload.Attachment.Id},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Empty(t, objects.Keys())
+
+	notice, err = util.Notices.GetNotice(ctx, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, notice.Attachments)
+}
+
+func TestHandler_Limits(t *testing.T) {
+	err := util.Notices.SaveNotice(context.Background(), &util.Notice{ID: 2, Title: "Assembly hall"}, 0, "coordinator")
+	assert.NoError(t, err)
+
+	for _, body := range []string{
+		`{"file_name": "setup.exe", "content_type": "application/octet-stream", "size": 100}`,
+		`{"file_name": "map.png", "content_type": "image/png", "size": 0}`,
+		`{"file_name": "map.png", "content_type": "image/png", "size": 104857600}`,
+		`{"content_type": "image/png", "size": 100}`,
+	} {
+		response, err := Handler(coordinatorContext(), events.APIGatewayProxyRequest{
+			HTTPMethod:     "POST",
+			PathParameters: map[string]string{"id": "2"},
+			Body:           body,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 400, response.StatusCode, body)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler serves the iCalendar feed of event notices (GET /calendar.ics). Calendar
+// apps cannot send a session token, so the feed URL carries a calendar token in the
+// token query parameter, created with POST /calendar/token. With CALENDAR_PUBLIC set
+// to true, a congregation's feed is also served without one (?congregation=north).
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod == http.MethodPost {
+		// Signed-in users create the token for their own feed
+		return util.RequireSession(util.ScopeRead, util.FeedTokenHandler(util.ScopeCalendar))(ctx, request)
+	}
+
+	// Find the congregation whose events to show
+	congregationId, err := util.FeedCongregation(request, util.ScopeCalendar, os.Getenv("CALENDAR_PUBLIC") == "true")
+	if errors.Is(err, util.ErrMissingFeedToken) {
+		return util.ErrorResponse(http.StatusUnauthorized, "Missing calendar token")
+	}
+	if err != nil {
+		log.Println("Failed to verify calendar token:", err)
+		return util.ErrorResponse(http.StatusUnauthorized, "Invalid calendar token")
+	}
+
+	calendar := util.Calendar{Name: "Noticeboard", Now: time.Now()}
+	if congregationId != "" {
+		congregation, err := util.Congregations.GetCongregation(ctx, congregationId)
+		if err != nil {
+			log.Println("Failed to get congregation:", err)
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		}
+		if congregation == nil {
+			return util.ErrorResponse(http.StatusNotFound, "Congregation not found")
+		}
+		calendar.Name = congregation.Name
+	}
+
+	// Get the events every member of the congregation sees
+	notices, err := util.FeedNotices(ctx, congregationId, request.QueryStringParameters["category"], calendar.Now)
+	if err != nil {
+		log.Println("Failed to list notices:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	var lastModified time.Time
+	for _, notice := range notices {
+		if notice.IsEvent() {
+			calendar.Notices = append(calendar.Notices, notice)
+			if notice.UpdatedAt.After(lastModified) {
+				lastModified = notice.UpdatedAt
+			}
+		}
+	}
+
+	// Keep the events in a fixed order, so an unchanged feed keeps its ETag
+	sort.Slice(calendar.Notices, func(i, j int) bool {
+		return calendar.Notices[i].ID < calendar.Notices[j].ID
+	})
+
+	body, err := calendar.Render()
+	if err != nil {
+		log.Println("Failed to render calendar:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	return util.CachedResponse(request, "text/calendar; charset=utf-8", body, lastModified)
+}
+
+func main() {
+	lambda.Start(Handler)
+}
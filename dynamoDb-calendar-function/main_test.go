@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the notices and congregations in memory for the tests
+	os.Setenv("SESSION_TOKEN_SECRET", "test-secret")
+	util.Notices = util.NewMemoryNoticeStore()
+	util.Congregations = util.NewMemoryCongregationStore()
+
+	os.Exit(m.Run())
+}
+
+func setup(t *testing.T) {
+	err := util.Congregations.CreateCongregation(context.Background(), &util.Congregation{Id: "north", Name: "North", TimeZone: "Europe/Amsterdam"})
+	assert.NoError(t, err)
+
+	updated := time.Date(2024, 4, 20, 12, 0, 0, 0, time.UTC)
+	for _, notice := range []*util.Notice{
+		{ID: 1, Title: "Midweek meeting", Content: "Bring your Bible; all welcome", Category: "meetings", Type: util.NoticeTypeEvent,
+			CongregationId: "north", Status: util.StatusPublished, Version: 3, CreatedAt: updated, UpdatedAt: updated,
+			Event: &util.EventDetails{
+				Start:          time.Date(2024, 5, 2, 17, 30, 0, 0, time.UTC),
+				End:            time.Date(2024, 5, 2, 19, 15, 0, 0, time.UTC),
+				TimeZone:       "Europe/Amsterdam",
+				Location:       "Kingdom Hall",
+				RRule:          "FREQ=WEEKLY;BYDAY=TH",
+				ExDates:        []string{"2024-05-16"},
+				CancelledDates: []string{"2024-05-23"},
+			}},
+		{ID: 2, Title: "Draft meeting", Type: util.NoticeTypeEvent, CongregationId: "north", Status: util.StatusDraft,
+			Event: &util.EventDetails{Start: updated, End: updated}},
+		{ID: 3, Title: "Hall cleaning", CongregationId: "north", Status: util.StatusPublished},
+		{ID: 4, Title: "South meeting", Type: util.NoticeTypeEvent, CongregationId: "south", Status: util.StatusPublished,
+			Event: &util.EventDetails{Start: updated, End: updated}},
+	} {
+		err := util.Notices.PutNotice(context.Background(), notice)
+		assert.NoError(t, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	setup(t)
+
+	// A member creates the token for their feed
+	session, err := util.GenerateSessionToken(util.SessionClaims{Subject: "member", CongregationId: "north", Scopes: []string{util.ScopeRead}}, time.Hour)
+	assert.NoError(t, err)
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Headers:    map[string]string{"Authorization": "Bearer " + session},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 201, response.StatusCode)
+
+	var token util.FeedToken
+	err = json.Unmarshal([]byte(response.Body), &token)
+	assert.NoError(t, err)
+
+	// Calendar apps fetch the feed with it
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"token": token.Token},
+	}
+	response, err = Handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "text/calendar; charset=utf-8", response.Headers["Content-Type"])
+
+	feed := response.Body
+	assert.True(t, strings.HasPrefix(feed, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(feed, "END:VCALENDAR\r\n"))
+	for _, line := range []string{
+		"X-WR-CALNAME:North",
+		"TZID:Europe/Amsterdam",
+		"BEGIN:DAYLIGHT",
+		"DTSTART:19700329T020000",
+		"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU",
+		"UID:notice-1@noticeboard",
+		"SEQUENCE:3",
+		"DTSTART;TZID=Europe/Amsterdam:20240502T193000",
+		"DTEND;TZID=Europe/Amsterdam:20240502T211500",
+		"RRULE:FREQ=WEEKLY;BYDAY=TH",
+		"EXDATE;TZID=Europe/Amsterdam:20240516T193000",
+		"RECURRENCE-ID;TZID=Europe/Amsterdam:20240523T193000",
+		"STATUS:CANCELLED",
+		`DESCRIPTION:Bring your Bible\; all welcome`,
+		"LOCATION:Kingdom Hall",
+	} {
+		assert.Contains(t, feed, line+"\r\n")
+	}
+
+	// Drafts, plain notices and other congregations are left out
+	assert.Equal(t, 2, strings.Count(feed, "BEGIN:VEVENT"))
+	assert.NotContains(t, feed, "Draft meeting")
+	assert.NotContains(t, feed, "South meeting")
+
+	// An unchanged feed is not sent again
+	etag := response.Headers["ETag"]
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "Sat, 20 Apr 2024 12:00:00 GMT", response.Headers["Last-Modified"])
+	request.Headers = map[string]string{"If-None-Match": etag}
+	response, err = Handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 304, response.StatusCode)
+	assert.Empty(t, response.Body)
+}
+
+func TestHandler_Unauthorized(t *testing.T) {
+	// Session tokens do not open the feed, nor do calendar tokens open the API
+	session, err := util.GenerateSessionToken(util.SessionClaims{Subject: "member", Scopes: []string{util.ScopeRead}}, time.Hour)
+	assert.NoError(t, err)
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"token": session},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 401, response.StatusCode)
+
+	calendar, err := util.GenerateSessionToken(util.SessionClaims{Subject: "member", Scopes: []string{util.ScopeCalendar}}, time.Hour)
+	assert.NoError(t, err)
+	response, err = Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Headers:    map[string]string{"Authorization": "Bearer " + calendar},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+
+	// Without a token the feed is only served when it is public
+	response, err = Handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Equal(t, 401, response.StatusCode)
+
+	os.Setenv("CALENDAR_PUBLIC", "true")
+	defer os.Unsetenv("CALENDAR_PUBLIC")
+	response, err = Handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	response, err = Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"congregation": "nowhere"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+}
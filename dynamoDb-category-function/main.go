@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler lists the allowed categories (GET) and lets platform admins add (POST, PUT) or
+// remove (DELETE) them. The list is shared by every congregation, so congregation admins
+// cannot change it.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod == http.MethodGet || request.HTTPMethod == "" {
+		return listCategories(ctx)
+	}
+
+	// Changing the category list is reserved for platform admins
+	claims, ok := util.SessionFromContext(ctx)
+	if !ok || !claims.HasScope(util.ScopeWrite) || !util.IsPlatformAdmin(claims) {
+		return util.ErrorResponse(http.StatusForbidden, "Insufficient permissions")
+	}
+
+	switch request.HTTPMethod {
+	case http.MethodPost, http.MethodPut:
+		return putCategory(ctx, request)
+	case http.MethodDelete:
+		return deleteCategory(ctx, request)
+	}
+	return util.ErrorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+func listCategories(ctx context.Context) (events.APIGatewayProxyResponse, error) {
+	categories, err := util.AllowedCategories(ctx, util.Categories)
+	if err != nil {
+		log.Println("Failed to list categories:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusOK, map[string]interface{}{
+		"categories": categories,
+	})
+}
+
+func putCategory(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Parse the request body
+	var category util.Category
+	err := json.Unmarshal([]byte(request.Body), &category)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+	}
+	if !util.ValidCategoryName(category.Name) {
+		return util.ErrorResponse(http.StatusBadRequest, "name must be a lower-case slug such as field-service")
+	}
+	if category.Label == "" {
+		category.Label = category.Name
+	}
+
+	// The first change replaces the built-in defaults, so carry them over
+	configured, err := util.Categories.ListCategories(ctx)
+	if err != nil {
+		log.Println("Failed to list categories:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if len(configured) == 0 {
+		err = seedDefaults(ctx)
+		if err != nil {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		}
+	}
+
+	category.CreatedAt = time.Now().UTC()
+	err = util.Categories.PutCategory(ctx, &category)
+	if err != nil {
+		log.Println("Failed to store category:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusOK, category)
+}
+
+func deleteCategory(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	name := request.PathParameters["name"]
+	if name == "" {
+		name = request.QueryStringParameters["name"]
+	}
+	if name == "" {
+		return util.ErrorResponse(http.StatusBadRequest, "category name is required")
+	}
+
+	configured, err := util.Categories.ListCategories(ctx)
+	if err != nil {
+		log.Println("Failed to list categories:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if len(configured) == 0 {
+		err = seedDefaults(ctx)
+		if err != nil {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		}
+	}
+
+	err = util.Categories.DeleteCategory(ctx, name)
+	if err != nil {
+		log.Println("Failed to delete category:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusOK, map[string]string{
+		"message": "Category deleted",
+	})
+}
+
+// seedDefaults stores the built-in categories so they survive the first change to the list
+func seedDefaults(ctx context.Context) error {
+	for _, category := range util.DefaultCategories {
+		category.CreatedAt = time.Now().UTC()
+		err := util.Categories.PutCategory(ctx, &category)
+		if err != nil {
+			log.Println("Failed to store default category:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	// Any session may read the list; changes are checked in the handler
+	lambda.Start(util.RequireSession(util.ScopeRead, Handler))
+}
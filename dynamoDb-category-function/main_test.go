@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the categories in memory for the tests
+	util.Categories = util.NewMemoryCategoryStore()
+
+	os.Exit(m.Run())
+}
+
+func sessionContext(groups ...string) context.Context {
+	return util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject: "test-sub",
+		Groups:  groups,
+		Scopes:  []string{util.ScopeRead, util.ScopeWrite},
+	})
+}
+
+func listNames(t *testing.T) []string {
+	response, err := Handler(sessionContext(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var body struct {
+		Categories []util.Category `json:"categories"`
+	}
+	err = json.Unmarshal([]byte(response.Body), &body)
+	assert.NoError(t, err)
+
+	var names []string
+	for _, category := range body.Categories {
+		names = append(names, category.Name)
+	}
+	return names
+}
+
+func TestHandler(t *testing.T) {
+	// The defaults apply until the list is changed
+	assert.Equal(t, []string{"announcements", "cleaning", "field-service", "maintenance", "meetings"}, listNames(t))
+
+	// Platform admins can add a category
+	response, err := Handler(sessionContext(util.GroupPlatformAdmins), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"name": "literature", "label": "Literature"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	// And remove one
+	response, err = Handler(sessionContext(util.GroupPlatformAdmins), events.APIGatewayProxyRequest{
+		HTTPMethod:     "DELETE",
+		PathParameters: map[string]string{"name": "maintenance"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	assert.Equal(t, []string{"announcements", "cleaning", "field-service", "literature", "meetings"}, listNames(t))
+
+	allowed, err := util.IsAllowedCategory(context.Background(), util.Categories, "literature")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestHandler_Validation(t *testing.T) {
+	// Members and congregation admins cannot change the list every congregation shares
+	for _, ctx := range []context.Context{sessionContext(), sessionContext(util.GroupAdmins)} {
+		response, err := Handler(ctx, events.APIGatewayProxyRequest{
+			HTTPMethod: "POST",
+			Body:       `{"name": "gardening"}`,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 403, response.StatusCode)
+	}
+
+	// Names must be slugs
+	response, err := Handler(sessionContext(util.GroupPlatformAdmins), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"name": "Field Service"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 400, response.StatusCode)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	cognito "github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider/cognitoidentityprovideriface"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// congregationAttribute is the Cognito custom attribute copied into session tokens at login
+const congregationAttribute = "custom:congregation_id"
+
+// users is the Cognito user pool members are assigned in
+var users cognitoidentityprovideriface.CognitoIdentityProviderAPI
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler lists (GET), creates (POST) and configures (PUT /congregations/{id}) congregations,
+// and assigns users to them (PUT /congregations/{id}/members/{username}).
+// Any session may read its own congregation, and admins configure it and assign new users
+// to it. Listing and creating congregations, and moving users between them, is reserved
+// for platform admins, so that one congregation never changes another.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	id := request.PathParameters["id"]
+	claims, _ := util.SessionFromContext(ctx)
+	writer := claims != nil && claims.HasScope(util.ScopeWrite)
+	platform := writer && util.IsPlatformAdmin(claims)
+	manages := writer && id != "" && util.ManagesCongregation(claims, id)
+
+	switch {
+	case request.HTTPMethod == http.MethodGet || request.HTTPMethod == "":
+		if id != "" && (manages || (claims != nil && claims.CongregationId == id)) {
+			return getCongregation(ctx, id)
+		}
+		if id == "" && platform {
+			return listCongregations(ctx)
+		}
+	case request.HTTPMethod == http.MethodPost && id == "":
+		if platform {
+			return createCongregation(ctx, request.Body)
+		}
+	case request.HTTPMethod == http.MethodPut && request.PathParameters["username"] != "":
+		if manages {
+			return assignMember(ctx, id, request.PathParameters["username"], platform)
+		}
+	case request.HTTPMethod == http.MethodPut && id != "":
+		if manages {
+			return updateCongregation(ctx, id, request.Body)
+		}
+	default:
+		return util.ErrorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+	return util.ErrorResponse(http.StatusForbidden, "Insufficient permissions")
+}
+
+func listCongregations(ctx context.Context) (events.APIGatewayProxyResponse, error) {
+	congregations, err := util.Congregations.ListCongregations(ctx)
+	if err != nil {
+		log.Println("Failed to list congregations:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if congregations == nil {
+		congregations = []util.Congregation{}
+	}
+
+	return util.JSONResponse(http.StatusOK, map[string]interface{}{
+		"congregations": congregations,
+	})
+}
+
+func getCongregation(ctx context.Context, id string) (events.APIGatewayProxyResponse, error) {
+	congregation, err := util.Congregations.GetCongregation(ctx, id)
+	if err != nil {
+		log.Println("Failed to get congregation:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if congregation == nil {
+		return util.ErrorResponse(http.StatusNotFound, "Congregation not found")
+	}
+
+	return util.JSONResponse(http.StatusOK, congregation)
+}
+
+func createCongregation(ctx context.Context, body string) (events.APIGatewayProxyResponse, error) {
+	// Parse the request body
+	var congregation util.Congregation
+	err := json.Unmarshal([]byte(body), &congregation)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+	}
+	response, err := validate(&congregation)
+	if response != nil {
+		return *response, err
+	}
+
+	now := time.Now().UTC()
+	congregation.CreatedAt = now
+	congregation.UpdatedAt = now
+	err = util.Congregations.CreateCongregation(ctx, &congregation)
+	if errors.Is(err, util.ErrCongregationExists) {
+		return util.ErrorResponse(http.StatusConflict, "Congregation already exists")
+	}
+	if err != nil {
+		log.Println("Failed to create congregation:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusCreated, congregation)
+}
+
+func updateCongregation(ctx context.Context, id, body string) (events.APIGatewayProxyResponse, error) {
+	existing, err := util.Congregations.GetCongregation(ctx, id)
+	if err != nil {
+		log.Println("Failed to get congregation:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if existing == nil {
+		return util.ErrorResponse(http.StatusNotFound, "Congregation not found")
+	}
+
+	// Parse the request body; the ID and creation time never change
+	var congregation util.Congregation
+	err = json.Unmarshal([]byte(body), &congregation)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+	}
+	congregation.Id = id
+	congregation.CreatedAt = existing.CreatedAt
+	congregation.UpdatedAt = time.Now().UTC()
+	response, err := validate(&congregation)
+	if response != nil {
+		return *response, err
+	}
+
+	err = util.Congregations.UpdateCongregation(ctx, &congregation)
+	if errors.Is(err, util.ErrCongregationNotFound) {
+		return util.ErrorResponse(http.StatusNotFound, "Congregation not found")
+	}
+	if err != nil {
+		log.Println("Failed to update congregation:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusOK, congregation)
+}
+
+// assignMember sets the user's congregation attribute. It is picked up at the user's next login.
+// Only platform admins may move a user who already belongs to another congregation.
+func assignMember(ctx context.Context, id, username string, platform bool) (events.APIGatewayProxyResponse, error) {
+	congregation, err := util.Congregations.GetCongregation(ctx, id)
+	if err != nil {
+		log.Println("Failed to get congregation:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if congregation == nil {
+		return util.ErrorResponse(http.StatusNotFound, "Congregation not found")
+	}
+
+	user, err := users.AdminGetUserWithContext(ctx, &cognito.AdminGetUserInput{
+		UserPoolId: aws.String(os.Getenv("AWS_USER_POOL_ID")),
+		Username:   aws.String(username),
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == cognito.ErrCodeUserNotFoundException {
+		return util.ErrorResponse(http.StatusNotFound, "User not found")
+	}
+	if err != nil {
+		log.Println("Failed to get user:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	attributes := make(map[string]string, len(user.UserAttributes))
+	for _, attribute := range user.UserAttributes {
+		attributes[aws.StringValue(attribute.Name)] = aws.StringValue(attribute.Value)
+	}
+	if current := attributes[congregationAttribute]; !platform && current != "" && current != id {
+		// Users of another congregation are not revealed
+		return util.ErrorResponse(http.StatusNotFound, "User not found")
+	}
+
+	_, err = users.AdminUpdateUserAttributesWithContext(ctx, &cognito.AdminUpdateUserAttributesInput{
+		UserPoolId: aws.String(os.Getenv("AWS_USER_POOL_ID")),
+		Username:   aws.String(username),
+		UserAttributes: []*cognito.AttributeType{
+			{Name: aws.String(congregationAttribute), Value: aws.String(id)},
+		},
+	})
+	if errors.As(err, &aerr) && aerr.Code() == cognito.ErrCodeUserNotFoundException {
+		return util.ErrorResponse(http.StatusNotFound, "User not found")
+	}
+	if err != nil {
+		log.Println("Failed to assign user:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	err = movePreferences(ctx, attributes["sub"], username, id)
+	if err != nil {
+		log.Println("Failed to update preferences:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusOK, map[string]string{
+		"message": "User assigned to congregation",
+	})
+}
+
+// movePreferences moves the user's notification preferences to the congregation, so that
+// they are notified of its notices straight away rather than after saving them again.
+// The groups are brought up to date too, as they decide which notices the user sees.
+func movePreferences(ctx context.Context, userId, username, congregationId string) error {
+	preferences, err := util.Preferences.GetPreferences(ctx, userId)
+	if err != nil || preferences == nil {
+		return err
+	}
+
+	var groups []string
+	err = users.AdminListGroupsForUserPagesWithContext(ctx, &cognito.AdminListGroupsForUserInput{
+		UserPoolId: aws.String(os.Getenv("AWS_USER_POOL_ID")),
+		Username:   aws.String(username),
+	}, func(page *cognito.AdminListGroupsForUserOutput, lastPage bool) bool {
+		for _, group := range page.Groups {
+			groups = append(groups, aws.StringValue(group.GroupName))
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list groups of %s: %w", username, err)
+	}
+
+	preferences.CongregationId = congregationId
+	preferences.Groups = groups
+	preferences.UpdatedAt = time.Now().UTC()
+	return util.Preferences.PutPreferences(ctx, preferences)
+}
+
+// validate returns a response if the congregation cannot be stored
+func validate(congregation *util.Congregation) (*events.APIGatewayProxyResponse, error) {
+	err := util.ValidateCongregation(congregation)
+	var invalid *util.ValidationError
+	if errors.As(err, &invalid) {
+		response, err := util.ErrorResponse(http.StatusBadRequest, invalid.Message)
+		return &response, err
+	}
+	if err != nil {
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	return nil, nil
+}
+
+func main() {
+	users = cognito.New(session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	})))
+
+	// Any session may read its own congregation; changes are checked in the handler, and
+	// the function needs permission for cognito-idp:AdminGetUser, AdminListGroupsForUser
+	// and AdminUpdateUserAttributes
+	lambda.Start(util.RequireSession(util.ScopeRead, Handler))
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	cognito "github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider/cognitoidentityprovideriface"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// userPool records attribute changes instead of calling Cognito
+type userPool struct {
+	cognitoidentityprovideriface.CognitoIdentityProviderAPI
+	attributes map[string]string
+	groups     map[string][]string
+}
+
+func (p *userPool) AdminUpdateUserAttributesWithContext(ctx aws.Context, input *cognito.AdminUpdateUserAttributesInput, opts ...request.Option) (*cognito.AdminUpdateUserAttributesOutput, error) {
+	username := aws.StringValue(input.Username)
+	if _, ok := p.attributes[username]; !ok {
+		return nil, awserr.New(cognito.ErrCodeUserNotFoundException, "User does not exist.", nil)
+	}
+	for _, attribute := range input.UserAttributes {
+		p.attributes[username] = aws.StringValue(attribute.Name) + "=" + aws.StringValue(attribute.Value)
+	}
+	return &cognito.AdminUpdateUserAttributesOutput{}, nil
+}
+
+func (p *userPool) AdminGetUserWithContext(ctx aws.Context, input *cognito.AdminGetUserInput, opts ...request.Option) (*cognito.AdminGetUserOutput, error) {
+	attribute, ok := p.attributes[aws.StringValue(input.Username)]
+	if !ok {
+		return nil, awserr.New(cognito.ErrCodeUserNotFoundException, "User does not exist.", nil)
+	}
+	// The users' subs are their usernames
+	output := &cognito.AdminGetUserOutput{Username: input.Username, UserAttributes: []*cognito.AttributeType{
+		{Name: aws.String("sub"), Value: input.Username},
+	}}
+	if name, value, ok := strings.Cut(attribute, "="); ok {
+		output.UserAttributes = append(output.UserAttributes, &cognito.AttributeType{Name: aws.String(name), Value: aws.String(value)})
+	}
+	return output, nil
+}
+
+func (p *userPool) AdminListGroupsForUserPagesWithContext(ctx aws.Context, input *cognito.AdminListGroupsForUserInput, fn func(*cognito.AdminListGroupsForUserOutput, bool) bool, opts ...request.Option) error {
+	var groups []*cognito.GroupType
+	for _, group := range p.groups[aws.StringValue(input.Username)] {
+		groups = append(groups, &cognito.GroupType{GroupName: aws.String(group)})
+	}
+	fn(&cognito.AdminListGroupsForUserOutput{Groups: groups}, true)
+	return nil
+}
+
+var pool = &userPool{attributes: map[string]string{
+	"jane": "",
+	"piet": "",
+	"kees": "custom:congregation_id=south",
+}, groups: map[string][]string{
+	"piet": {util.GroupCoordinators},
+}}
+
+func TestMain(m *testing.M) {
+	// Keep the congregations and users in memory for the tests
+	util.Congregations = util.NewMemoryCongregationStore()
+	util.Preferences = util.NewMemoryPreferencesStore()
+	users = pool
+
+	os.Exit(m.Run())
+}
+
+func sessionContext(congregationId string, groups ...string) context.Context {
+	return util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject:        "test-sub",
+		Groups:         groups,
+		CongregationId: congregationId,
+		Scopes:         []string{util.ScopeRead, util.ScopeWrite},
+	})
+}
+
+func TestHandler(t *testing.T) {
+	admin := sessionContext("", util.GroupPlatformAdmins)
+
+	// Platform admins create congregations
+	response, err := Handler(admin, events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"id": "north", "name": "North", "time_zone": "Europe/Amsterdam"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 201, response.StatusCode)
+
+	response, err = Handler(admin, events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"id": "north", "name": "North again"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 409, response.StatusCode)
+
+	// And configure them
+	response, err = Handler(admin, events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"id": "north"},
+		Body:           `{"name": "North Hall", "time_zone": "Europe/Berlin"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var congregation util.Congregation
+	err = json.Unmarshal([]byte(response.Body), &congregation)
+	assert.NoError(t, err)
+	assert.Equal(t, "north", congregation.Id)
+	assert.Equal(t, "North Hall", congregation.Name)
+	assert.Equal(t, "Europe/Berlin", congregation.Location().String())
+	assert.False(t, congregation.CreatedAt.IsZero())
+
+	// Members read their own congregation, but not another
+	response, err = Handler(sessionContext("north"), events.APIGatewayProxyRequest{
+		HTTPMethod:     "GET",
+		PathParameters: map[string]string{"id": "north"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	response, err = Handler(sessionContext("south"), events.APIGatewayProxyRequest{
+		HTTPMethod:     "GET",
+		PathParameters: map[string]string{"id": "north"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+
+	response, err = Handler(admin, events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Contains(t, response.Body, `"id":"north"`)
+}
+
+func TestHandler_Invalid(t *testing.T) {
+	admin := sessionContext("", util.GroupPlatformAdmins)
+
+	for _, body := range []string{
+		`{"id": "North Hall", "name": "North"}`,
+		`{"id": "west", "name": ""}`,
+		`{"id": "west", "name": "West", "time_zone": "Europe/Nowhere"}`,
+	} {
+		response, err := Handler(admin, events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: body})
+		assert.NoError(t, err)
+		assert.Equal(t, 400, response.StatusCode, body)
+	}
+
+	response, err := Handler(admin, events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"id": "west"},
+		Body:           `{"name": "West"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+
+	// Coordinators cannot create congregations
+	response, err = Handler(sessionContext("north", util.GroupCoordinators), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"id": "west", "name": "West"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+}
+
+func TestHandler_AssignMember(t *testing.T) {
+	admin := sessionContext("", util.GroupPlatformAdmins)
+	err := util.Congregations.CreateCongregation(context.Background(), &util.Congregation{Id: "east", Name: "East"})
+	assert.NoError(t, err)
+
+	response, err := Handler(admin, events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"id": "east", "username": "jane"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "custom:congregation_id=east", pool.attributes["jane"])
+
+	// Unknown users and congregations are reported
+	response, err = Handler(admin, events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"id": "east", "username": "nobody"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+
+	response, err = Handler(admin, events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"id": "nowhere", "username": "jane"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+}
+
+func TestHandler_CongregationAdmin(t *testing.T) {
+	admin := sessionContext("central", util.GroupAdmins)
+	for _, id := range []string{"central", "outskirts"} {
+		err := util.Congregations.CreateCongregation(context.Background(), &util.Congregation{Id: id, Name: id})
+		assert.NoError(t, err)
+	}
+
+	// Congregation admins configure their own congregation
+	response, err := Handler(admin, events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"id": "central"},
+		Body:           `{"name": "Central", "time_zone": "Europe/Amsterdam"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	// But not another, nor list or create congregations
+	for _, request := range []events.APIGatewayProxyRequest{
+		{HTTPMethod: "GET", PathParameters: map[string]string{"id": "outskirts"}},
+		{HTTPMethod: "PUT", PathParameters: map[string]string{"id": "outskirts"}, Body: `{"name": "Taken"}`},
+		{HTTPMethod: "GET"},
+		{HTTPMethod: "POST", Body: `{"id": "elsewhere", "name": "Elsewhere"}`},
+		{HTTPMethod: "PUT", PathParameters: map[string]string{"id": "outskirts", "username": "piet"}},
+	} {
+		response, err = Handler(admin, request)
+		assert.NoError(t, err)
+		assert.Equal(t, 403, response.StatusCode, request)
+	}
+
+	// They assign users without a congregation to their own, whose preferences follow
+	err = util.Preferences.PutPreferences(context.Background(), util.DefaultPreferences("piet", "piet@example.org", ""))
+	assert.NoError(t, err)
+	response, err = Handler(admin, events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"id": "central", "username": "piet"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "custom:congregation_id=central", pool.attributes["piet"])
+
+	members, err := util.Preferences.ListPreferences(context.Background(), "central")
+	assert.NoError(t, err)
+	if assert.Len(t, members, 1) {
+		assert.Equal(t, "piet", members[0].UserId)
+		assert.Equal(t, []string{util.GroupCoordinators}, members[0].Groups)
+		assert.Equal(t, []string{util.ChannelEmail}, members[0].Channels)
+	}
+
+	// But cannot take users from another congregation
+	response, err = Handler(admin, events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"id": "central", "username": "kees"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+	assert.Equal(t, "custom:congregation_id=south", pool.attributes["kees"])
+
+	// Which platform admins can
+	response, err = Handler(sessionContext("", util.GroupPlatformAdmins), events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"id": "central", "username": "kees"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "custom:congregation_id=central", pool.attributes["kees"])
+}
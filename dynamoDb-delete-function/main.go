@@ -2,70 +2,59 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
 	"github.com/joho/godotenv"
 )
 
-// Define a generic interface for your item
-type Item interface{}
-
 func init() {
 	// Load environment variables from .env file
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file:", err)
+		fmt.Println("Error loading .env file:", err)
 	}
 }
 
 func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Create a new DynamoDB session
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(os.Getenv("AWS_REGION")),
-	})
+	// Read the notice ID from the path or the request body
+	id, err := util.NoticeIdFromRequest(event)
 	if err != nil {
-		log.Fatal(err)
+		return util.ErrorResponse(http.StatusBadRequest, err.Error())
 	}
 
-	// Create a new DynamoDB client
-	db := dynamodb.New(sess)
-
-	// Unmarshal the request body into an Item interface
-	var item Item
-	err = json.Unmarshal([]byte(event.Body), &item)
+	// Get the notice from DynamoDB
+	notice, err := util.Notices.GetNotice(ctx, id)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 400}, err
+		log.Println("Failed to get notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
 	}
-
-	// Convert the item to a map[string]*dynamodb.AttributeValue
-	av, err := dynamodbattribute.MarshalMap(item)
-	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 500}, err
+	claims, _ := util.SessionFromContext(ctx)
+	if notice == nil || !util.NoticeVisibleTo(claims, notice, time.Now()) {
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found")
 	}
-
-	// Create an input for the DeleteItem operation
-	input := &dynamodb.DeleteItemInput{
-		TableName: aws.String(os.Getenv("AWS_DYNAMO_TABLE_NAME")),
-		Key:       av,
+	if !util.CanEditNotice(claims, notice) {
+		return util.ErrorResponse(http.StatusForbidden, "Notice is under review or published")
 	}
 
-	// Delete the item from DynamoDB
-	_, err = db.DeleteItem(input)
+	// Move the notice to the trash; it is purged for good after the retention period
+	err = util.Notices.TrashNotice(ctx, id, claims.Username, time.Now().UTC())
+	if errors.Is(err, util.ErrNotFound) {
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found")
+	}
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 500}, err
+		log.Println("Failed to delete notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
 	}
 
 	// Return a success response
-	response := fmt.Sprintf("Item deleted successfully: %+v", item)
+	response := fmt.Sprintf("Item deleted successfully: map[Id:%d]", id)
 	return events.APIGatewayProxyResponse{
 		StatusCode: 200,
 		Body:       response,
@@ -73,5 +62,6 @@ func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.A
 }
 
 func main() {
-	lambda.Start(handler)
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeWrite, handler))
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"os"
 	"testing"
 	"time"
 
@@ -28,6 +29,15 @@ func (m *mockDynamoDBClient) PutItemWithContext(ctx context.Context, event event
 	}, nil
 }
 
+func TestMain(m *testing.M) {
+	// Fall back to an in-memory store when no DynamoDB table is configured
+	if os.Getenv("AWS_DYNAMO_TABLE_NAME") == "" {
+		util.Notices = util.NewMemoryNoticeStore()
+	}
+
+	os.Exit(m.Run())
+}
+
 func TestHandler(t *testing.T) {
 	// Test setup
 	id := setup(t)
@@ -38,18 +48,42 @@ func TestHandler(t *testing.T) {
 		Body: requestBody,
 	}
 
-	// Invoke the handler function
-	response, err := handler(context.Background(), request)
+	// Invoke the handler function as a coordinator
+	ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "coordinator-sub", Username: "coordinator", Groups: []string{util.GroupCoordinators}})
+	response, err := handler(ctx, request)
 	assert.NoError(t, err)
 	assert.Equal(t, 200, response.StatusCode)
 
 	// Assert the expected response body
 	assert.Equal(t, fmt.Sprintf("Item deleted successfully: map[Id:%d]", id), response.Body)
 
-	// Retrieve the item using the getItem function
-	item, err := util.GetItem(id)
+	// The item is kept in the trash
+	item, err := util.Notices.GetNotice(context.Background(), id)
+	assert.NoError(t, err)
+	assert.NotNil(t, item.DeletedAt)
+	assert.Equal(t, "coordinator", item.DeletedBy)
+
+	// Deleting it again finds nothing
+	response, err = handler(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+
+	// Test teardown
+	err = util.Notices.DeleteNotice(context.Background(), id)
 	assert.NoError(t, err)
-	assert.Nil(t, item)
+}
+
+func TestHandler_Forbidden(t *testing.T) {
+	// Test setup
+	id := setup(t)
+	defer util.Notices.DeleteNotice(context.Background(), id)
+
+	// Members cannot delete published notices
+	member := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "member"})
+	request := events.APIGatewayProxyRequest{Body: fmt.Sprintf(`{ "Id": %d }`, id)}
+	response, err := handler(member, request)
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
 }
 
 
@@ -57,21 +91,18 @@ func TestHandler(t *testing.T) {
 func setup(t *testing.T) int {
 	seed := time.Now().UnixNano()
 	r := rand.New(rand.NewSource(seed))
-	id := r.Intn(1000)
+	id := r.Intn(1000) + 1
 
-	testItem := map[string]interface{}{
-		"Id":   id,
-		"name": "Test Item",
+	testItem := &util.Notice{
+		ID:    id,
+		Title: "Test Item",
+		Tags:  []string{"test"},
 	}
 	// Store the testing entry
-	storedId, err := util.StoreItem(id, testItem )
+	err := util.Notices.PutNotice(context.Background(), testItem)
 	if err != nil {
 		t.Errorf("Error storing item: %s", err)
 		return 0
 	}
-	if storedId != id {
-		t.Errorf("Expected id %d, got %d", id, storedId)
-		return 0
-	}
 	return id
 }
\ No newline at end of file
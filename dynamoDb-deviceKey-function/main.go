@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// MintRequest describes a new display device key
+type MintRequest struct {
+	Name           string     `json:"name"`
+	CongregationId string     `json:"congregation_id"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// MintResponse returns the plain key once, together with its stored record
+type MintResponse struct {
+	Key       string          `json:"key"`
+	DeviceKey *util.DeviceKey `json:"device_key"`
+}
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler mints (POST), lists (GET) and revokes (DELETE) display device keys. Admins
+// manage the keys of their own congregation; platform admins those of every congregation.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, _ := util.SessionFromContext(ctx)
+	switch request.HTTPMethod {
+	case http.MethodPost:
+		return mintKey(ctx, claims, request)
+	case http.MethodGet:
+		return listKeys(ctx, claims)
+	case http.MethodDelete:
+		return revokeKey(ctx, claims, request)
+	}
+	return util.ErrorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+func mintKey(ctx context.Context, claims *util.SessionClaims, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Parse the request body
+	var mintReq MintRequest
+	err := json.Unmarshal([]byte(request.Body), &mintReq)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	// Keys are for the admin's own congregation unless they say otherwise
+	if mintReq.CongregationId == "" && claims != nil {
+		mintReq.CongregationId = claims.CongregationId
+	}
+	if mintReq.CongregationId != "" && !util.ManagesCongregation(claims, mintReq.CongregationId) {
+		return util.ErrorResponse(http.StatusForbidden, "Keys can only be minted for your own congregation")
+	}
+	if mintReq.Name == "" || mintReq.CongregationId == "" {
+		return util.ErrorResponse(http.StatusBadRequest, "name and congregation_id are required")
+	}
+	if mintReq.ExpiresAt != nil && !mintReq.ExpiresAt.After(time.Now()) {
+		return util.ErrorResponse(http.StatusBadRequest, "expires_at must be in the future")
+	}
+
+	// Devices can only be bound to a configured congregation
+	congregation, err := util.Congregations.GetCongregation(ctx, mintReq.CongregationId)
+	if err != nil {
+		log.Println("Failed to get congregation:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if congregation == nil {
+		return util.ErrorResponse(http.StatusBadRequest, "Unknown congregation: "+mintReq.CongregationId)
+	}
+
+	key, plainKey, err := util.NewDeviceKey(mintReq.Name, mintReq.CongregationId, claims.Subject, mintReq.ExpiresAt)
+	if err != nil {
+		log.Println("Failed to mint device key:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	err = util.DeviceKeys.PutDeviceKey(ctx, key)
+	if err != nil {
+		log.Println("Failed to store device key:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusCreated, MintResponse{
+		Key:       plainKey,
+		DeviceKey: key,
+	})
+}
+
+func listKeys(ctx context.Context, claims *util.SessionClaims) (events.APIGatewayProxyResponse, error) {
+	all, err := util.DeviceKeys.ListDeviceKeys(ctx)
+	if err != nil {
+		log.Println("Failed to list device keys:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// Admins only see the keys of their own congregation
+	keys := make([]util.DeviceKey, 0, len(all))
+	for _, key := range all {
+		if util.ManagesCongregation(claims, key.CongregationId) {
+			keys = append(keys, key)
+		}
+	}
+
+	return util.JSONResponse(http.StatusOK, map[string]interface{}{
+		"device_keys": keys,
+	})
+}
+
+func revokeKey(ctx context.Context, claims *util.SessionClaims, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	keyId := request.PathParameters["id"]
+	if keyId == "" {
+		keyId = request.QueryStringParameters["key_id"]
+	}
+	if keyId == "" {
+		return util.ErrorResponse(http.StatusBadRequest, "key id is required")
+	}
+
+	key, err := util.DeviceKeys.GetDeviceKey(ctx, keyId)
+	if err != nil {
+		log.Println("Failed to get device key:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if key == nil || !util.ManagesCongregation(claims, key.CongregationId) {
+		// Keys of other congregations are not revealed
+		return util.ErrorResponse(http.StatusNotFound, "Device key not found")
+	}
+
+	err = util.DeviceKeys.RevokeDeviceKey(ctx, keyId, time.Now().UTC())
+	if err != nil {
+		log.Println("Failed to revoke device key:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusOK, map[string]string{
+		"message": "Device key revoked",
+	})
+}
+
+func main() {
+	// Device keys are managed by admins only
+	lambda.Start(util.RequireSession(util.ScopeWrite, util.RequireGroup(Handler, util.GroupAdmins, util.GroupPlatformAdmins)))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the device keys and congregations in memory for the tests
+	util.DeviceKeys = util.NewMemoryDeviceKeyStore()
+	util.Congregations = util.NewMemoryCongregationStore()
+	_ = util.Congregations.CreateCongregation(context.Background(), &util.Congregation{Id: "north", Name: "North"})
+	_ = util.Congregations.CreateCongregation(context.Background(), &util.Congregation{Id: "south", Name: "South"})
+
+	os.Exit(m.Run())
+}
+
+func adminContext() context.Context {
+	return util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject:        "admin-sub",
+		Groups:         []string{util.GroupAdmins},
+		CongregationId: "north",
+		Scopes:         []string{util.ScopeRead, util.ScopeWrite},
+	})
+}
+
+func platformAdminContext() context.Context {
+	return util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject: "platform-sub",
+		Groups:  []string{util.GroupPlatformAdmins},
+		Scopes:  []string{util.ScopeRead, util.ScopeWrite},
+	})
+}
+
+func TestHandler(t *testing.T) {
+	// Mint a key for the Kingdom Hall display
+	response, err := Handler(adminContext(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"name": "Kingdom Hall display"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 201, response.StatusCode)
+
+	var minted MintResponse
+	err = json.Unmarshal([]byte(response.Body), &minted)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, minted.Key)
+	assert.Equal(t, "north", minted.DeviceKey.CongregationId)
+	assert.Equal(t, "admin-sub", minted.DeviceKey.CreatedBy)
+	assert.NotContains(t, response.Body, "secret_hash", "The secret hash must not be returned")
+
+	// The key authenticates read requests only
+	var seen *util.SessionClaims
+	readHandler := util.RequireSession(util.ScopeRead, func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		seen, _ = util.SessionFromContext(ctx)
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	})
+	writeHandler := util.RequireSession(util.ScopeWrite, readHandler)
+	keyRequest := events.APIGatewayProxyRequest{Headers: map[string]string{"X-Api-Key": minted.Key}}
+
+	response, err = readHandler(context.Background(), keyRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.True(t, seen.Device)
+	assert.Equal(t, "north", seen.CongregationId)
+
+	response, err = writeHandler(context.Background(), keyRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+
+	// Listing shows the last use
+	response, err = Handler(adminContext(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var listed struct {
+		DeviceKeys []util.DeviceKey `json:"device_keys"`
+	}
+	err = json.Unmarshal([]byte(response.Body), &listed)
+	assert.NoError(t, err)
+	assert.Len(t, listed.DeviceKeys, 1)
+	assert.NotNil(t, listed.DeviceKeys[0].LastUsedAt)
+
+	// Revoked keys stop working
+	response, err = Handler(adminContext(), events.APIGatewayProxyRequest{
+		HTTPMethod:     "DELETE",
+		PathParameters: map[string]string{"id": minted.DeviceKey.KeyId},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	response, err = readHandler(context.Background(), keyRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, 401, response.StatusCode)
+}
+
+func TestHandler_InvalidRequest(t *testing.T) {
+	response, err := Handler(platformAdminContext(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"name": "No congregation"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 400, response.StatusCode)
+
+	response, err = Handler(platformAdminContext(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"name": "Unknown congregation", "congregation_id": "nowhere"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 400, response.StatusCode)
+	assert.Equal(t, "Unknown congregation: nowhere", response.Body)
+
+	response, err = Handler(adminContext(), events.APIGatewayProxyRequest{
+		HTTPMethod:     "DELETE",
+		PathParameters: map[string]string{"id": "unknown"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+}
+
+func TestHandler_RequiresAdmin(t *testing.T) {
+	handler := util.RequireGroup(Handler, util.GroupAdmins)
+	ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject: "member-sub",
+		Scopes:  []string{util.ScopeRead, util.ScopeWrite},
+	})
+
+	response, err := handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+}
+
+func TestHandler_OtherCongregation(t *testing.T) {
+	// A platform admin mints a key for the south congregation
+	response, err := Handler(platformAdminContext(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"name": "South display", "congregation_id": "south"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 201, response.StatusCode)
+	var minted MintResponse
+	err = json.Unmarshal([]byte(response.Body), &minted)
+	assert.NoError(t, err)
+
+	// The north admin can neither mint, see nor revoke keys of the south congregation
+	response, err = Handler(adminContext(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"name": "Sneaky display", "congregation_id": "south"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+
+	response, err = Handler(adminContext(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.NotContains(t, response.Body, minted.DeviceKey.KeyId)
+
+	response, err = Handler(adminContext(), events.APIGatewayProxyRequest{
+		HTTPMethod:     "DELETE",
+		PathParameters: map[string]string{"id": minted.DeviceKey.KeyId},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+
+	key, err := util.DeviceKeys.GetDeviceKey(context.Background(), minted.DeviceKey.KeyId)
+	assert.NoError(t, err)
+	assert.Nil(t, key.RevokedAt)
+
+	// The platform admin sees it
+	response, err = Handler(platformAdminContext(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Contains(t, response.Body, minted.DeviceKey.KeyId)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// DigestResult summarises one run of the digest
+type DigestResult struct {
+	Congregations int `json:"congregations"`
+	Sent          int `json:"sent"`
+	Skipped       int `json:"skipped"`
+	Failed        int `json:"failed"`
+}
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler runs on a daily EventBridge schedule and emails every member who asked for a
+// digest a summary of their congregation's coming day or week. Each digest is recorded
+// before it is sent, so members get one daily digest a day and one weekly digest a week,
+// however often the handler runs.
+func Handler(ctx context.Context, event events.CloudWatchEvent) (DigestResult, error) {
+	var result DigestResult
+	now := time.Now()
+
+	// Members without a congregation get the digest of the notices without one
+	congregations, err := util.Congregations.ListCongregations(ctx)
+	if err != nil {
+		return result, err
+	}
+	congregations = append(congregations, util.Congregation{Name: "Noticeboard"})
+
+	for i := range congregations {
+		err = sendDigests(ctx, &congregations[i], now, &result)
+		if err != nil {
+			return result, err
+		}
+		result.Congregations++
+	}
+
+	log.Printf("Sent %d digests for %d congregations: %d skipped, %d failed", result.Sent, result.Congregations, result.Skipped, result.Failed)
+	return result, nil
+}
+
+// sendDigests sends the digests of the members of one congregation
+func sendDigests(ctx context.Context, congregation *util.Congregation, now time.Time, result *DigestResult) error {
+	// Get the members who asked for a digest
+	preferences, err := util.Preferences.ListPreferences(ctx, congregation.Id)
+	if err != nil {
+		return err
+	}
+	var members []util.UserPreferences
+	for _, p := range preferences {
+		if p.DigestFrequency() != util.DigestNone && p.Email != "" {
+			members = append(members, p)
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	// Get the notices every member of the congregation sees
+	notices, err := util.FeedNotices(ctx, congregation.Id, "", now)
+	if err != nil {
+		return err
+	}
+
+	for i := range members {
+		member := &members[i]
+		digest, err := util.BuildDigest(member, notices, now, congregation.Location())
+		if err != nil {
+			return err
+		}
+		digest.Board = congregation.Name
+		if digest.Empty() {
+			result.Skipped++
+			continue
+		}
+
+		// Record the digest first, so a rerun skips it
+		err = util.Digests.ClaimDigest(ctx, &util.DigestRecord{
+			UserId:    member.UserId,
+			Period:    digest.Period,
+			Email:     member.Email,
+			NoticeIds: digest.NoticeIds(),
+			SentAt:    now.UTC(),
+		})
+		if errors.Is(err, util.ErrDigestSent) {
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		message, err := digest.Email(member.Email)
+		if err == nil {
+			err = util.Mail.Send(ctx, message)
+		}
+		if err != nil {
+			// Forget the digest again, so the next run retries it
+			log.Printf("Failed to send digest to %s: %v", member.UserId, err)
+			result.Failed++
+			if err := util.Digests.ReleaseDigest(ctx, member.UserId, digest.Period); err != nil {
+				log.Printf("Failed to release digest of %s: %v", member.UserId, err)
+			}
+			continue
+		}
+		result.Sent++
+	}
+	return nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+var mail *util.MemoryMailer
+
+func TestMain(m *testing.M) {
+	// Keep everything in memory and capture the emails for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+	util.Congregations = util.NewMemoryCongregationStore()
+	util.Preferences = util.NewMemoryPreferencesStore()
+	util.Digests = util.NewMemoryDigestStore()
+	mail = &util.MemoryMailer{}
+	util.Mail = mail
+
+	os.Exit(m.Run())
+}
+
+// failingMailer stands in for SES refusing to send
+type failingMailer struct{}
+
+func (failingMailer) Send(ctx context.Context, message util.EmailMessage) error {
+	return errors.New("throttled")
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	err := util.Congregations.CreateCongregation(ctx, &util.Congregation{Id: "north", Name: "North", TimeZone: "Europe/Amsterdam"})
+	assert.NoError(t, err)
+
+	expires := now.Add(48 * time.Hour)
+	amsterdam, _ := time.LoadLocation("Europe/Amsterdam")
+	local := now.In(amsterdam)
+	yesterday := time.Date(local.Year(), local.Month(), local.Day()-1, 12, 0, 0, 0, amsterdam)
+	for _, notice := range []*util.Notice{
+		{ID: 1, Title: "Cleaning rota", Category: "cleaning", CongregationId: "north", Status: util.StatusPublished, CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: 2, Title: "Garden day", Category: "cleaning", CongregationId: "north", Status: util.StatusPublished, CreatedAt: now.Add(-30 * 24 * time.Hour), ExpireAt: &expires},
+		{ID: 3, Title: "Midweek meeting", Category: "meetings", CongregationId: "north", Status: util.StatusPublished, CreatedAt: now.Add(-30 * 24 * time.Hour),
+			Type: util.NoticeTypeEvent, Event: &util.EventDetails{Start: now.Add(24 * time.Hour), End: now.Add(26 * time.Hour), TimeZone: "Europe/Amsterdam", Location: "Hall"}},
+		{ID: 4, Title: "Draft", CongregationId: "north", Status: util.StatusDraft, CreatedAt: now.Add(-time.Hour)},
+		{ID: 5, Title: "Old news", CongregationId: "north", Status: util.StatusPublished, CreatedAt: now.Add(-30 * 24 * time.Hour)},
+		{ID: 6, Title: "Lunch after the meeting", Category: "food", CongregationId: "north", Status: util.StatusPublished, CreatedAt: yesterday},
+	} {
+		err := util.Notices.PutNotice(ctx, notice)
+		assert.NoError(t, err)
+	}
+	for _, preferences := range []*util.UserPreferences{
+		{UserId: "all", Email: "all@example.org", CongregationId: "north", Digest: util.DigestWeekly},
+		{UserId: "cleaning", Email: "cleaning@example.org", CongregationId: "north", Digest: util.DigestWeekly, Categories: []string{"cleaning"}},
+		{UserId: "songs", Email: "songs@example.org", CongregationId: "north", Digest: util.DigestWeekly, Categories: []string{"songs"}},
+		{UserId: "daily", Email: "daily@example.org", CongregationId: "north", Digest: util.DigestDaily, Categories: []string{"food", "meetings"}},
+		{UserId: "none", Email: "none@example.org", CongregationId: "north", Channels: []string{util.ChannelEmail}, Digest: util.DigestNone},
+	} {
+		err := util.Preferences.PutPreferences(ctx, preferences)
+		assert.NoError(t, err)
+	}
+
+	result, err := Handler(ctx, events.CloudWatchEvent{})
+	assert.NoError(t, err)
+	assert.Equal(t, DigestResult{Congregations: 2, Sent: 3, Skipped: 1}, result)
+
+	// Members get the events, new and expiring notices they subscribed to
+	sent := mail.Sent()
+	assert.Len(t, sent, 3)
+	assert.Equal(t, "all@example.org", sent[0].To)
+	assert.Contains(t, sent[0].Subject, "[North] Your week from ")
+	assert.Contains(t, sent[0].Text, "Midweek meeting, Hall")
+	assert.Contains(t, sent[0].Text, "- Cleaning rota (cleaning)")
+	assert.Contains(t, sent[0].Text, "- Garden day, until ")
+	assert.NotContains(t, sent[0].Text, "Draft")
+	assert.NotContains(t, sent[0].Text, "Old news")
+	assert.Contains(t, sent[0].HTML, "<h2>Coming up</h2>")
+
+	assert.Equal(t, "cleaning@example.org", sent[1].To)
+	assert.NotContains(t, sent[1].Text, "Midweek meeting")
+
+	// Daily digests cover the coming day
+	assert.Equal(t, "daily@example.org", sent[2].To)
+	assert.Contains(t, sent[2].Subject, "[North] Your day, ")
+	assert.Contains(t, sent[2].Text, "- Lunch after the meeting (food)")
+	assert.NotContains(t, sent[2].Text, "Midweek meeting")
+
+	records := util.Digests.(*util.MemoryDigestStore).Records()
+	assert.Len(t, records, 3)
+	assert.Equal(t, []int{1, 2, 3, 6}, records[0].NoticeIds)
+	assert.Regexp(t, `^\d{4}-\d{2}-\d{2}$`, records[2].Period)
+
+	// Running again in the same week sends nothing twice
+	result, err = Handler(ctx, events.CloudWatchEvent{})
+	assert.NoError(t, err)
+	assert.Equal(t, DigestResult{Congregations: 2, Skipped: 4}, result)
+	assert.Len(t, mail.Sent(), 3)
+
+	// Digests that could not be sent are retried on the next run
+	err = util.Preferences.PutPreferences(ctx, &util.UserPreferences{UserId: "late", Email: "late@example.org", CongregationId: "north", Digest: util.DigestWeekly})
+	assert.NoError(t, err)
+	util.Mail = failingMailer{}
+	result, err = Handler(ctx, events.CloudWatchEvent{})
+	util.Mail = mail
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Failed)
+
+	result, err = Handler(ctx, events.CloudWatchEvent{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Sent)
+	assert.Equal(t, "late@example.org", mail.Sent()[3].To)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler serves the published notices as news feeds, GET /feed.rss and GET /feed.atom.
+// Feed readers cannot log in, so the feed URL carries a feed token in the token query
+// parameter, created with POST /feed/token. With FEED_PUBLIC set to true, a
+// congregation's feeds are also served without one (?congregation=north).
+// Attachments are linked through GET /feed/attachments/{id}/{attachment_id}, which
+// redirects to a fresh download link, so the feed itself does not change.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod == http.MethodPost {
+		// Signed-in users create the token for their own feed
+		return util.RequireSession(util.ScopeRead, util.FeedTokenHandler(util.ScopeFeed))(ctx, request)
+	}
+
+	// Find the congregation whose notices to show
+	congregationId, err := util.FeedCongregation(request, util.ScopeFeed, os.Getenv("FEED_PUBLIC") == "true")
+	if errors.Is(err, util.ErrMissingFeedToken) {
+		return util.ErrorResponse(http.StatusUnauthorized, "Missing feed token")
+	}
+	if err != nil {
+		log.Println("Failed to verify feed token:", err)
+		return util.ErrorResponse(http.StatusUnauthorized, "Invalid feed token")
+	}
+
+	if request.PathParameters["attachment_id"] != "" {
+		return attachment(ctx, request, congregationId)
+	}
+
+	feed := util.Syndication{Id: "urn:noticeboard:feed", Title: "Noticeboard"}
+	if congregationId != "" {
+		congregation, err := util.Congregations.GetCongregation(ctx, congregationId)
+		if err != nil {
+			log.Println("Failed to get congregation:", err)
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		}
+		if congregation == nil {
+			return util.ErrorResponse(http.StatusNotFound, "Congregation not found")
+		}
+		feed.Id += ":" + congregation.Id
+		feed.Title = congregation.Name + " noticeboard"
+	}
+	category := request.QueryStringParameters["category"]
+	if category != "" {
+		feed.Id += ":" + category
+		feed.Title += " - " + category
+	}
+
+	// Get the latest notices every member of the congregation sees
+	notices, err := util.FeedNotices(ctx, congregationId, category, time.Now())
+	if err != nil {
+		log.Println("Failed to list notices:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	feed.Notices = util.SortForFeed(notices)
+
+	// Links repeat the token or congregation, so readers can follow them
+	base := baseURL(request)
+	access := accessQuery(request)
+	feed.SelfURL = base + request.Path + access
+	feed.EnclosureURL = func(n *util.Notice, a *util.Attachment) string {
+		return fmt.Sprintf("%s/feed/attachments/%d/%s%s", base, n.ID, url.PathEscape(a.Id), access)
+	}
+
+	contentType := "application/rss+xml; charset=utf-8"
+	render := feed.RSS
+	if strings.HasSuffix(request.Path, ".atom") {
+		contentType = "application/atom+xml; charset=utf-8"
+		render = feed.Atom
+	}
+	body, err := render()
+	if err != nil {
+		log.Println("Failed to render feed:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	return util.CachedResponse(request, contentType, body, feed.Updated())
+}
+
+// attachment redirects to a download link for an attachment of a notice in the feed
+func attachment(ctx context.Context, request events.APIGatewayProxyRequest, congregationId string) (events.APIGatewayProxyResponse, error) {
+	id, err := util.NoticeIdFromRequest(request)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, err.Error())
+	}
+	notice, err := util.Notices.GetNotice(ctx, id)
+	if err != nil {
+		log.Println("Failed to get notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// Only notices in the feed can be followed
+	member := &util.SessionClaims{CongregationId: congregationId}
+	if notice == nil || !util.NoticeVisibleTo(member, notice, time.Now()) {
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found")
+	}
+	attachment := notice.Attachment(request.PathParameters["attachment_id"])
+	if attachment == nil {
+		return util.ErrorResponse(http.StatusNotFound, "Attachment not found")
+	}
+
+	link, err := util.Objects.PresignGet(ctx, attachment.Key, util.AttachmentURLTTL)
+	if err != nil {
+		log.Println("Failed to presign attachment:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusFound,
+		Headers:    map[string]string{"Location": link},
+	}, nil
+}
+
+// baseURL returns the address the API is served from, FEED_BASE_URL or else the request's host
+func baseURL(request events.APIGatewayProxyRequest) string {
+	if base := os.Getenv("FEED_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	return "https://" + util.HeaderValue(request, "Host")
+}
+
+// accessQuery returns the query string that gives access to the feed, if any
+func accessQuery(request events.APIGatewayProxyRequest) string {
+	query := url.Values{}
+	for _, name := range []string{"token", "congregation", "category"} {
+		if value := request.QueryStringParameters[name]; value != "" {
+			query.Set(name, value)
+		}
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+func main() {
+	lambda.Start(Handler)
+}
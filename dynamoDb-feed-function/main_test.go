@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the notices, congregations and files in memory for the tests
+	os.Setenv("SESSION_TOKEN_SECRET", "test-secret")
+	os.Setenv("FEED_BASE_URL", "https://api.example.org/")
+	util.Notices = util.NewMemoryNoticeStore()
+	util.Congregations = util.NewMemoryCongregationStore()
+	util.Objects = util.NewMemoryObjectStore()
+
+	os.Exit(m.Run())
+}
+
+var (
+	created   = time.Date(2024, 4, 20, 12, 0, 0, 0, time.UTC)
+	published = time.Date(2024, 4, 21, 9, 30, 0, 0, time.UTC)
+	edited    = time.Date(2024, 4, 22, 18, 0, 0, 0, time.UTC)
+)
+
+func setup(t *testing.T) {
+	err := util.Congregations.CreateCongregation(context.Background(), &util.Congregation{Id: "north", Name: "North"})
+	assert.NoError(t, err)
+
+	rota := util.Notice{ID: 1, Title: "Cleaning rota", Content: "Group **2** this week", Category: "cleaning", Author: "Jane",
+		CongregationId: "north", Status: util.StatusPublished, CreatedAt: created, UpdatedAt: edited,
+		ReviewComments: []util.ReviewComment{{Action: "publish", CreatedAt: published}},
+		Attachments:    []util.Attachment{{Id: "a1", Key: "notices/1/a1/rota.pdf", FileName: "rota.pdf", ContentType: "application/pdf", Size: 52000}},
+	}
+	rota.RenderContent()
+
+	for _, notice := range []*util.Notice{
+		&rota,
+		{ID: 2, Title: "Meeting times", Category: "meetings", CongregationId: "north", Status: util.StatusPublished, CreatedAt: created, UpdatedAt: created},
+		{ID: 3, Title: "Draft notice", CongregationId: "north", Status: util.StatusDraft, CreatedAt: created, UpdatedAt: created},
+		{ID: 4, Title: "South notice", CongregationId: "south", Status: util.StatusPublished, CreatedAt: created, UpdatedAt: created},
+	} {
+		err := util.Notices.PutNotice(context.Background(), notice)
+		assert.NoError(t, err)
+	}
+}
+
+func feedToken(t *testing.T) string {
+	session, err := util.GenerateSessionToken(util.SessionClaims{Subject: "member", CongregationId: "north", Scopes: []string{util.ScopeRead}}, time.Hour)
+	assert.NoError(t, err)
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Headers:    map[string]string{"Authorization": "Bearer " + session},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 201, response.StatusCode)
+
+	var token util.FeedToken
+	err = json.Unmarshal([]byte(response.Body), &token)
+	assert.NoError(t, err)
+	return token.Token
+}
+
+func TestHandler(t *testing.T) {
+	setup(t)
+	token := feedToken(t)
+
+	// RSS
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/feed.rss",
+		QueryStringParameters: map[string]string{"token": token},
+	}
+	response, err := Handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "application/rss+xml; charset=utf-8", response.Headers["Content-Type"])
+	assert.Equal(t, "Mon, 22 Apr 2024 18:00:00 GMT", response.Headers["Last-Modified"])
+
+	var rss struct {
+		Title string `xml:"channel>title"`
+		Items []struct {
+			Title       string `xml:"title"`
+			Description string `xml:"description"`
+			Guid        string `xml:"guid"`
+			PubDate     string `xml:"pubDate"`
+			Enclosure   struct {
+				URL    string `xml:"url,attr"`
+				Length int64  `xml:"length,attr"`
+				Type   string `xml:"type,attr"`
+			} `xml:"enclosure"`
+		} `xml:"channel>item"`
+	}
+	err = xml.Unmarshal([]byte(response.Body), &rss)
+	assert.NoError(t, err)
+	assert.Equal(t, "North noticeboard", rss.Title)
+
+	// Drafts and other congregations are left out; the latest published notice comes first
+	assert.Len(t, rss.Items, 2)
+	item := rss.Items[0]
+	assert.Equal(t, "Cleaning rota", item.Title)
+	assert.Equal(t, "urn:noticeboard:notice:1", item.Guid)
+	assert.Equal(t, "Sun, 21 Apr 2024 09:30:00 +0000", item.PubDate)
+	assert.Equal(t, "<p>Group <strong>2</strong> this week</p>\n", item.Description)
+	assert.Equal(t, "https://api.example.org/feed/attachments/1/a1?token="+token, item.Enclosure.URL)
+	assert.Equal(t, int64(52000), item.Enclosure.Length)
+	assert.Equal(t, "application/pdf", item.Enclosure.Type)
+
+	// Unchanged feeds are not sent again
+	request.Headers = map[string]string{"If-None-Match": response.Headers["ETag"]}
+	response, err = Handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 304, response.StatusCode)
+
+	request.Headers = map[string]string{"If-Modified-Since": "Mon, 22 Apr 2024 18:00:00 GMT"}
+	response, err = Handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 304, response.StatusCode)
+
+	// Atom, filtered by category
+	response, err = Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/feed.atom",
+		QueryStringParameters: map[string]string{"token": token, "category": "cleaning"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", response.Headers["Content-Type"])
+
+	var atom struct {
+		Id      string `xml:"id"`
+		Updated string `xml:"updated"`
+		Entries []struct {
+			Id        string `xml:"id"`
+			Published string `xml:"published"`
+			Updated   string `xml:"updated"`
+			Author    string `xml:"author>name"`
+			Content   struct {
+				Type  string `xml:"type,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"content"`
+			Link struct {
+				Href string `xml:"href,attr"`
+				Rel  string `xml:"rel,attr"`
+			} `xml:"link"`
+		} `xml:"entry"`
+	}
+	err = xml.Unmarshal([]byte(response.Body), &atom)
+	assert.NoError(t, err)
+	assert.Equal(t, "urn:noticeboard:feed:north:cleaning", atom.Id)
+	assert.Equal(t, "2024-04-22T18:00:00Z", atom.Updated)
+	assert.Len(t, atom.Entries, 1)
+	entry := atom.Entries[0]
+	assert.Equal(t, "urn:noticeboard:notice:1", entry.Id)
+	assert.Equal(t, "2024-04-21T09:30:00Z", entry.Published)
+	assert.Equal(t, "2024-04-22T18:00:00Z", entry.Updated)
+	assert.Equal(t, "Jane", entry.Author)
+	assert.Equal(t, "html", entry.Content.Type)
+	assert.Contains(t, entry.Content.Value, "<strong>2</strong>")
+	assert.Equal(t, "enclosure", entry.Link.Rel)
+}
+
+func TestHandler_Attachment(t *testing.T) {
+	token := feedToken(t)
+
+	// Enclosures redirect to a fresh download link
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		PathParameters:        map[string]string{"id": "1", "attachment_id": "a1"},
+		QueryStringParameters: map[string]string{"token": token},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 302, response.StatusCode)
+	assert.Contains(t, response.Headers["Location"], "notices/1/a1/rota.pdf")
+
+	response, err = Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		PathParameters:        map[string]string{"id": "1", "attachment_id": "a2"},
+		QueryStringParameters: map[string]string{"token": token},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+}
+
+func TestHandler_Unauthorized(t *testing.T) {
+	// Feeds need a feed token, unless they are public
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/feed.rss"})
+	assert.NoError(t, err)
+	assert.Equal(t, 401, response.StatusCode)
+
+	calendar, err := util.GenerateSessionToken(util.SessionClaims{Subject: "member", Scopes: []string{util.ScopeCalendar}}, time.Hour)
+	assert.NoError(t, err)
+	response, err = Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/feed.rss",
+		QueryStringParameters: map[string]string{"token": calendar},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 401, response.StatusCode)
+
+	os.Setenv("FEED_PUBLIC", "true")
+	defer os.Unsetenv("FEED_PUBLIC")
+	response, err = Handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/feed.atom",
+		QueryStringParameters: map[string]string{"congregation": "north"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+}
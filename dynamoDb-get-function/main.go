@@ -2,84 +2,73 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
 	"github.com/joho/godotenv"
 )
 
-// Define a generic interface for your item
-type Item interface{}
-
 func init() {
 	// Load environment variables from .env file
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file:", err)
+		fmt.Println("Error loading .env file:", err)
 	}
 }
 
 func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Create a new DynamoDB session
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(os.Getenv("AWS_REGION")),
-	})
+	// Read the notice ID from the path or the request body
+	id, err := util.NoticeIdFromRequest(event)
 	if err != nil {
-		log.Fatal(err)
+		return util.ErrorResponse(http.StatusBadRequest, err.Error())
 	}
 
-	// Create a new DynamoDB client
-	db := dynamodb.New(sess)
-
-	// Unmarshal the request body into an Item interface
-	var item Item
-	err = json.Unmarshal([]byte(event.Body), &item)
+	// Get the notice from DynamoDB
+	notice, err := util.Notices.GetNotice(ctx, id)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 400}, err
+		log.Println("Failed to get notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
 	}
 
-	// Convert the item to a map[string]*dynamodb.AttributeValue
-	av, err := dynamodbattribute.MarshalMap(item)
-	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 500}, err
+	// Unpublished notices and those outside their publishing window are hidden from members
+	claims, _ := util.SessionFromContext(ctx)
+	if notice == nil || !util.NoticeVisibleTo(claims, notice, time.Now()) {
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found")
 	}
 
-	// Get an input for the GetItem operation
-	input := &dynamodb.GetItemInput{
-		TableName: aws.String(os.Getenv("AWS_DYNAMO_TABLE_NAME")),
-		Key:       av,
-	}
-
-	// Get the item in DynamoDB
-	result, err := db.GetItem(input)
+	// Attachments are downloaded through short-lived links
+	notices := []util.Notice{*notice}
+	err = util.PresignAttachments(ctx, util.Objects, notices)
 	if err != nil {
-	    return events.APIGatewayProxyResponse{StatusCode: 500}, err
+		log.Println("Failed to presign attachments:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
 	}
 
-	// Unmarshal the DynamoDB result into an Item interface
-	var receivedItem Item
-	err = dynamodbattribute.UnmarshalMap(result.Item, &receivedItem)
+	// The title and content are given in the caller's language when there is a translation
+	languages, err := util.CongregationLanguages(ctx, notice.CongregationId)
 	if err != nil {
-	    return events.APIGatewayProxyResponse{StatusCode: 500}, err
+		log.Println("Failed to get congregation languages:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
 	}
+	notices[0].Localize(util.RequestLanguages(event), languages)
 
-	// Return the received item in the response body
-	response := fmt.Sprintf("Item: %+v", receivedItem)
-	return events.APIGatewayProxyResponse{
-	    StatusCode: 200,
-	    Body:       response,
-	}, nil
-
+	// Return the notice in the response body
+	response, err := util.JSONResponse(http.StatusOK, notices[0])
+	if err == nil {
+		response.Headers["Vary"] = "Accept-Language"
+		if notices[0].DisplayLanguage != "" {
+			response.Headers["Content-Language"] = notices[0].DisplayLanguage
+		}
+	}
+	return response, err
 }
 
 func main() {
-	lambda.Start(handler)
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeRead, handler))
 }
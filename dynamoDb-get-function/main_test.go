@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -12,6 +15,17 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestMain(m *testing.M) {
+	// Fall back to an in-memory store when no DynamoDB table is configured
+	if os.Getenv("AWS_DYNAMO_TABLE_NAME") == "" {
+		util.Notices = util.NewMemoryNoticeStore()
+	}
+	util.Objects = util.NewMemoryObjectStore()
+	util.Congregations = util.NewMemoryCongregationStore()
+
+	os.Exit(m.Run())
+}
+
 func TestHandler(t *testing.T) {
 	// Test setup
 	id := setup(t)
@@ -28,42 +42,177 @@ func TestHandler(t *testing.T) {
 	assert.Equal(t, 200, response.StatusCode)
 
 	// Assert the expected response body
-	assert.Equal(t, fmt.Sprintf("Item: map[Id:%d name:Test Item]", id), response.Body)
+	var notice util.Notice
+	err = json.Unmarshal([]byte(response.Body), &notice)
+	assert.NoError(t, err)
+	assert.Equal(t, id, notice.ID)
+	assert.Equal(t, "Test Item", notice.Title)
+	assert.Equal(t, "meetings", notice.Category)
+
+	// The ID can also be given as a path parameter
+	request = events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"id": strconv.Itoa(id)},
+	}
+	response, err = handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
 
 	// Test teardown
 	teardown(t, id)
+
+	// Missing notices are reported as such
+	response, err = handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
 }
 
 func setup(t *testing.T) int {
 	seed := time.Now().UnixNano()
 	r := rand.New(rand.NewSource(seed))
-	id := r.Intn(1000)
+	id := r.Intn(1000) + 1
 
-	testItem := map[string]interface{}{
-		"Id":   id,
-		"name": "Test Item",
+	testItem := &util.Notice{
+		ID:        id,
+		Title:     "Test Item",
+		Category:  "meetings",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
 	}
 	// Store the testing entry
-	storedId, err := util.StoreItem(id, testItem )
+	err := util.Notices.PutNotice(context.Background(), testItem)
 	if err != nil {
 		t.Errorf("Error storing item: %s", err)
 		return 0
 	}
-	if storedId != id {
-		t.Errorf("Expected id %d, got %d", id, storedId)
-		return 0
-	}
 	return id
 }
 
 func teardown(t *testing.T, id int) {
 	// Delete the testing entry
-	err := util.DeleteItem(id)
+	err := util.Notices.DeleteNotice(context.Background(), id)
 	assert.NoError(t, err)
 
 	// Verify the deletion
-	item, err := util.GetItem(id)
+	item, err := util.Notices.GetNotice(context.Background(), id)
 	assert.NoError(t, err)
 	assert.Nil(t, item)
 }
 
+func TestHandler_Scheduled(t *testing.T) {
+	// A notice that is not published yet
+	publishAt := time.Now().Add(24 * time.Hour)
+	notice := &util.Notice{ID: 3001, Title: "Circuit assembly", PublishAt: &publishAt}
+	notice.ApplySchedule(time.Now())
+	err := util.Notices.PutNotice(context.Background(), notice)
+	assert.NoError(t, err)
+	defer teardown(t, notice.ID)
+
+	request := events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"id": "3001"},
+	}
+
+	// Members cannot see it yet
+	member := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "member"})
+	response, err := handler(member, request)
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+
+	// Coordinators can
+	coordinator := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "coordinator", Groups: []string{util.GroupCoordinators}})
+	response, err = handler(coordinator, request)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Contains(t, response.Body, `"schedule_state":"scheduled"`)
+}
+
+func TestHandler_Attachments(t *testing.T) {
+	notice := &util.Notice{
+		ID:          3002,
+		Title:       "Assembly hall",
+		Attachments: []util.Attachment{{Id: "a1", Key: "notices/3002/a1/map.png", FileName: "map.png", ContentType: "image/png", Size: 2048}},
+	}
+	err := util.Notices.PutNotice(context.Background(), notice)
+	assert.NoError(t, err)
+	defer teardown(t, notice.ID)
+
+	response, err := handler(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"id": "3002"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	// Attachments come with a download link
+	var received util.Notice
+	err = json.Unmarshal([]byte(response.Body), &received)
+	assert.NoError(t, err)
+	assert.Len(t, received.Attachments, 1)
+	assert.Contains(t, received.Attachments[0].URL, "notices/3002/a1/map.png")
+}
+
+func TestHandler_Congregation(t *testing.T) {
+	notice := &util.Notice{ID: 5001, Title: "North notice", CongregationId: "north", CreatedAt: time.Now().UTC()}
+	err := util.Notices.PutNotice(context.Background(), notice)
+	assert.NoError(t, err)
+	defer teardown(t, 5001)
+
+	request := events.APIGatewayProxyRequest{PathParameters: map[string]string{"id": "5001"}}
+	for congregationId, status := range map[string]int{"north": 200, "south": 404, "": 404} {
+		ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{
+			Subject:        "coordinator",
+			CongregationId: congregationId,
+			Groups:         []string{util.GroupCoordinators},
+		})
+		response, err := handler(ctx, request)
+		assert.NoError(t, err)
+		assert.Equal(t, status, response.StatusCode, congregationId)
+	}
+}
+
+func TestHandler_Translations(t *testing.T) {
+	err := util.Congregations.CreateCongregation(context.Background(), &util.Congregation{Id: "west", Name: "West", Languages: []string{"en", "nl", "fr"}})
+	assert.NoError(t, err)
+	notice := &util.Notice{
+		ID:             5002,
+		Title:          "Cleaning",
+		Content:        "The hall is cleaned on **Saturday**.",
+		Language:       "en",
+		Translations:   map[string]util.Translation{"nl": {Title: "Schoonmaak", Content: "De zaal wordt op **zaterdag** schoongemaakt."}},
+		CongregationId: "west",
+		CreatedAt:      time.Now().UTC(),
+	}
+	notice.RenderContent()
+	err = util.Notices.PutNotice(context.Background(), notice)
+	assert.NoError(t, err)
+	defer teardown(t, 5002)
+
+	ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject:        "coordinator",
+		CongregationId: "west",
+		Groups:         []string{util.GroupCoordinators},
+	})
+	for _, test := range []struct {
+		lang, acceptLanguage, title, language string
+	}{
+		{"", "nl-BE,nl;q=0.9,en;q=0.8", "Schoonmaak", "nl"},
+		{"", "fr, en;q=0.5", "Cleaning", "en"},
+		{"en", "nl", "Cleaning", "en"},
+		{"", "", "Cleaning", "en"},
+	} {
+		response, err := handler(ctx, events.APIGatewayProxyRequest{
+			PathParameters:        map[string]string{"id": "5002"},
+			QueryStringParameters: map[string]string{"lang": test.lang},
+			Headers:               map[string]string{"accept-language": test.acceptLanguage},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 200, response.StatusCode)
+		assert.Equal(t, test.language, response.Headers["Content-Language"])
+		assert.Equal(t, "Accept-Language", response.Headers["Vary"])
+
+		var received util.Notice
+		err = json.Unmarshal([]byte(response.Body), &received)
+		assert.NoError(t, err)
+		assert.Equal(t, test.title, received.Title, test.acceptLanguage)
+		assert.Equal(t, test.language, received.DisplayLanguage)
+		assert.Equal(t, []string{"fr"}, received.MissingTranslations)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler follows the short links in text messages (GET /n/{code}) to the notice in the
+// web app at NOTICEBOARD_URL. The app asks the member to sign in, so no session is needed.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	id, err := util.ParseShortCode(request.PathParameters["code"])
+	if err != nil || id <= 0 {
+		return util.ErrorResponse(http.StatusNotFound, "Link not found")
+	}
+
+	location := fmt.Sprintf("%s/notices/%d", strings.TrimSuffix(os.Getenv("NOTICEBOARD_URL"), "/"), id)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusFound,
+		Headers: map[string]string{
+			"Location":      location,
+			"Cache-Control": "public, max-age=86400",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
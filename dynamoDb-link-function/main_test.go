@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	t.Setenv("NOTICEBOARD_URL", "https://board.example.org/")
+
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"code": "1C"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusFound, response.StatusCode)
+	assert.Equal(t, "https://board.example.org/notices/100", response.Headers["Location"])
+
+	for _, code := range []string{"", "0", "no-such"} {
+		response, err = Handler(context.Background(), events.APIGatewayProxyRequest{
+			PathParameters: map[string]string{"code": code},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	}
+}
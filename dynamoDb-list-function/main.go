@@ -5,113 +5,89 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
+	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
 	"github.com/joho/godotenv"
 )
 
-// Define a generic interface for your item
-type Item interface{}
-
 func init() {
 	// Load environment variables from .env file
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file:", err)
+		fmt.Println("Error loading .env file:", err)
 	}
 }
 
 func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Create a new DynamoDB session
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(os.Getenv("AWS_REGION")),
-	})
-	if err != nil {
-		log.Fatal(err)
+	// Filter by category and tag from the query string, within the caller's congregation
+	claims, _ := util.SessionFromContext(ctx)
+	filter := util.NoticeFilter{
+		Category: event.QueryStringParameters["category"],
+		Tag:      strings.ToLower(strings.TrimSpace(event.QueryStringParameters["tag"])),
 	}
-
-	// Create a new DynamoDB client
-	db := dynamodb.New(sess)
-
-	// Unmarshal the request body into a struct containing the IDs
-	var request struct {
-		Ids []int `json:"ids"`
+	if claims != nil {
+		filter.CongregationId = claims.CongregationId
 	}
-	err = json.Unmarshal([]byte(event.Body), &request)
-	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 400}, err
-	}
-
-	// Create a slice to hold the BatchGetItem request items
-	var batchGetItems []*dynamodb.KeysAndAttributes
 
-	// Iterate over the IDs and create BatchGetItem request items for each ID
-	for _, id := range request.Ids {
-		// Convert the ID to a DynamoDB attribute value
-		idAttributeValue, err := dynamodbattribute.Marshal(id)
-		if err != nil {
-			return events.APIGatewayProxyResponse{StatusCode: 500}, err
+	// Older clients send the IDs to fetch in the request body
+	if event.Body != "" {
+		var request struct {
+			Ids []int `json:"ids"`
 		}
-
-		// Create a BatchGetItem request item for the ID
-		item := map[string]*dynamodb.AttributeValue{
-			"id": idAttributeValue,
-		}
-
-		// Create a KeysAndAttributes struct for the request item
-		keysAndAttributes := &dynamodb.KeysAndAttributes{
-			Keys: []map[string]*dynamodb.AttributeValue{item},
+		err := json.Unmarshal([]byte(event.Body), &request)
+		if err != nil {
+			return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
 		}
-
-		// Add the KeysAndAttributes struct to the slice
-		batchGetItems = append(batchGetItems, keysAndAttributes)
+		filter.Ids = request.Ids
 	}
 
-	// Create the BatchGetItem input
-	input := &dynamodb.BatchGetItemInput{
-		RequestItems: map[string]*dynamodb.KeysAndAttributes{
-			os.Getenv("AWS_DYNAMO_TABLE_NAME"): {
-				Keys:             nil,
-				ConsistentRead:   aws.Bool(true), // Set to true if you want consistent reads
-			},
-		},
+	// Get the notices from DynamoDB
+	notices, err := util.Notices.ListNotices(ctx, filter)
+	if err != nil {
+		log.Println("Failed to list notices:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
 	}
 
-	// Set the batchGetItems slice as the value of the table in the input
-	keys := make([]map[string]*dynamodb.AttributeValue, len(batchGetItems))
-	for i, keysAndAttributes := range batchGetItems {
-		keys[i] = keysAndAttributes.Keys[0]
+	// Unpublished notices and those outside their publishing window are hidden from members
+	notices = util.VisibleNotices(claims, notices, time.Now())
+
+	// Pinned and urgent notices come first, unless the caller asked for specific IDs
+	if len(filter.Ids) == 0 {
+		util.SortForBoard(notices)
 	}
-	input.RequestItems[os.Getenv("AWS_DYNAMO_TABLE_NAME")].Keys = keys
-	
 
-	// Get the items from DynamoDB
-	result, err := db.BatchGetItem(input)
+	// Attachments are downloaded through short-lived links
+	err = util.PresignAttachments(ctx, util.Objects, notices)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 500}, err
+		log.Println("Failed to presign attachments:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
 	}
 
-	// Unmarshal the DynamoDB result into a slice of Item interface
-	var receivedItems []Item
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Responses[os.Getenv("AWS_DYNAMO_TABLE_NAME")], &receivedItems)
+	// Titles and content are given in the caller's language where there is a translation
+	languages, err := util.CongregationLanguages(ctx, filter.CongregationId)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 500}, err
+		log.Println("Failed to get congregation languages:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	preferred := util.RequestLanguages(event)
+	for i := range notices {
+		notices[i].Localize(preferred, languages)
 	}
 
-	// Return the received items in the response body
-	response := fmt.Sprintf("Received Items: %+v", receivedItems)
-	return events.APIGatewayProxyResponse{
-		StatusCode: 200,
-		Body:       response,
-	}, nil
+	// Return the notices in the response body
+	response, err := util.JSONResponse(http.StatusOK, notices)
+	if err == nil {
+		response.Headers["Vary"] = "Accept-Language"
+	}
+	return response, err
 }
 
 func main() {
-	lambda.Start(handler)
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeRead, handler))
 }
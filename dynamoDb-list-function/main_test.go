@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"math/rand"
+	"os"
 	"testing"
 	"time"
 
@@ -53,6 +54,17 @@ func (ti *TestItem) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func TestMain(m *testing.M) {
+	// Fall back to an in-memory store when no DynamoDB table is configured
+	if os.Getenv("AWS_DYNAMO_TABLE_NAME") == "" {
+		util.Notices = util.NewMemoryNoticeStore()
+	}
+	util.Objects = util.NewMemoryObjectStore()
+	util.Congregations = util.NewMemoryCongregationStore()
+
+	os.Exit(m.Run())
+}
+
 func TestHandler(t *testing.T) {
 	// Create N sample items
 	numItems := 3
@@ -102,36 +114,126 @@ func TestHandler(t *testing.T) {
 func setup(t *testing.T) int {
 	seed := time.Now().UnixNano()
 	r := rand.New(rand.NewSource(seed))
-	id := r.Intn(1000)
-
-	testItem, _ := json.Marshal(map[string]interface{}{
-		"Id": id,
-		"Title": "Test Title",
-		"Content": "Test Content",
-		"Author": "Gopher Test",
-		"CreatedAt": time.Now(),
-		"UpdatedAt": time.Now(),
-		"DeletedAt": nil,
-		},
-	)
+	id := r.Intn(1000) + 1
+
+	testItem := &util.Notice{
+		ID:        id,
+		Title:     "Test Title",
+		Content:   "Test Content",
+		Author:    "Gopher Test",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		DeletedAt: nil,
+	}
 
 	// Create the testing entry
-	storedId, err := util.StoreItem(id, testItem)
+	err := util.Notices.PutNotice(context.Background(), testItem)
 	assert.NoError(t, err)
-	assert.Equal(t, id, storedId)
 
 	return id
 }
 
 func teardown(t *testing.T, id int) {
 	// Delete the testing entry
-	// Adjust this code based on your implementation
-	err := util.DeleteItem(id)
+	err := util.Notices.DeleteNotice(context.Background(), id)
 	assert.NoError(t, err)
 
 	// Verify the deletion
-	// Adjust this code based on your implementation
-	item, err := util.GetItem(id)
+	item, err := util.Notices.GetNotice(context.Background(), id)
 	assert.NoError(t, err)
 	assert.Nil(t, item)
 }
+
+func TestHandler_Filtered(t *testing.T) {
+	// Expired notices are left out of every listing
+	expired := time.Now().Add(-time.Minute)
+	notices := []*util.Notice{
+		{ID: 2001, Title: "Cleaning rota", Category: "cleaning", Tags: []string{"rota"}, CreatedAt: time.Now().Add(-time.Hour)},
+		{ID: 2002, Title: "Garden cleaning", Category: "cleaning", Tags: []string{"garden"}, CreatedAt: time.Now()},
+		{ID: 2003, Title: "Speaker rota", Category: "meetings", Tags: []string{"rota"}, CreatedAt: time.Now()},
+		{ID: 2004, Title: "Old rota", Category: "cleaning", Tags: []string{"rota"}, CreatedAt: time.Now(), ExpireAt: &expired},
+	}
+	for _, notice := range notices {
+		err := util.Notices.PutNotice(context.Background(), notice)
+		assert.NoError(t, err)
+		defer teardown(t, notice.ID)
+	}
+
+	list := func(query map[string]string) []int {
+		response, err := handler(context.Background(), events.APIGatewayProxyRequest{QueryStringParameters: query})
+		assert.NoError(t, err)
+		assert.Equal(t, 200, response.StatusCode)
+
+		var received []util.Notice
+		err = json.Unmarshal([]byte(response.Body), &received)
+		assert.NoError(t, err)
+
+		var ids []int
+		for _, notice := range received {
+			ids = append(ids, notice.ID)
+		}
+		return ids
+	}
+
+	// Category listings are newest first
+	assert.Equal(t, []int{2002, 2001}, list(map[string]string{"category": "cleaning"}))
+	assert.ElementsMatch(t, []int{2001, 2003}, list(map[string]string{"tag": "Rota"}))
+	assert.Equal(t, []int{2001}, list(map[string]string{"category": "cleaning", "tag": "rota"}))
+	assert.Empty(t, list(map[string]string{"category": "maintenance"}))
+}
+
+func TestHandler_Congregation(t *testing.T) {
+	notices := []*util.Notice{
+		{ID: 2101, Title: "North rota", CongregationId: "north", Category: "cleaning", CreatedAt: time.Now()},
+		{ID: 2102, Title: "South rota", CongregationId: "south", Category: "cleaning", CreatedAt: time.Now()},
+	}
+	for _, notice := range notices {
+		err := util.Notices.PutNotice(context.Background(), notice)
+		assert.NoError(t, err)
+		defer teardown(t, notice.ID)
+	}
+
+	// Each congregation only lists its own notices, whatever the filter
+	for _, query := range []map[string]string{nil, {"category": "cleaning"}} {
+		ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "member", CongregationId: "north"})
+		response, err := handler(ctx, events.APIGatewayProxyRequest{QueryStringParameters: query})
+		assert.NoError(t, err)
+		assert.Equal(t, 200, response.StatusCode)
+		assert.Contains(t, response.Body, "North rota")
+		assert.NotContains(t, response.Body, "South rota")
+	}
+
+	// Explicit IDs are no way around it
+	ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "member", CongregationId: "north"})
+	response, err := handler(ctx, events.APIGatewayProxyRequest{Body: `{"ids": [2102]}`})
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", response.Body)
+}
+
+func TestHandler_Priority(t *testing.T) {
+	ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "member", CongregationId: "east"})
+	now := time.Now()
+	notices := []*util.Notice{
+		{ID: 2201, Title: "Newest", CongregationId: "east", CreatedAt: now},
+		{ID: 2202, Title: "Meeting moved", CongregationId: "east", Priority: util.PriorityUrgent, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: 2203, Title: "House rules", CongregationId: "east", Pinned: true, CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: 2204, Title: "Placed first", CongregationId: "east", Position: 1, CreatedAt: now.Add(-time.Hour)},
+	}
+	for _, notice := range notices {
+		err := util.Notices.PutNotice(context.Background(), notice)
+		assert.NoError(t, err)
+		defer teardown(t, notice.ID)
+	}
+
+	// Pinned, then urgent, then the manual order, then newest first
+	response, err := handler(ctx, events.APIGatewayProxyRequest{})
+	assert.NoError(t, err)
+	var received []util.Notice
+	err = json.Unmarshal([]byte(response.Body), &received)
+	assert.NoError(t, err)
+	var ids []int
+	for _, notice := range received {
+		ids = append(ids, notice.ID)
+	}
+	assert.Equal(t, []int{2203, 2202, 2204, 2201}, ids)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// maxRange is the longest period one request may expand events over
+const maxRange = 366 * 24 * time.Hour
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler lists the occurrences of event notices within a date range
+// (GET /events/occurrences?from=2024-05-06&to=2024-05-13). Without a range it
+// returns the coming week, starting today in the congregation's time zone.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, _ := util.SessionFromContext(ctx)
+	filter := util.NoticeFilter{Category: request.QueryStringParameters["category"]}
+	location := time.UTC
+	if claims != nil && claims.CongregationId != "" {
+		filter.CongregationId = claims.CongregationId
+
+		// Dates are days in the congregation's time zone
+		congregation, err := util.Congregations.GetCongregation(ctx, claims.CongregationId)
+		if err != nil {
+			log.Println("Failed to get congregation:", err)
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		}
+		if congregation != nil {
+			location = congregation.Location()
+		}
+	}
+
+	// Parse the range from the query string
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	from, err := parseTime(request.QueryStringParameters["from"], today, location)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, "from must be a date or an RFC 3339 time")
+	}
+	to, err := parseTime(request.QueryStringParameters["to"], from.AddDate(0, 0, 7), location)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, "to must be a date or an RFC 3339 time")
+	}
+	if !to.After(from) {
+		return util.ErrorResponse(http.StatusBadRequest, "to must be after from")
+	}
+	if to.Sub(from) > maxRange {
+		return util.ErrorResponse(http.StatusBadRequest, "The range cannot be longer than a year")
+	}
+
+	// Get the notices the caller may see
+	notices, err := util.Notices.ListNotices(ctx, filter)
+	if err != nil {
+		log.Println("Failed to list notices:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	notices = util.VisibleNotices(claims, notices, time.Now())
+
+	// Expand the events into their occurrences
+	occurrences := []util.Occurrence{}
+	for i := range notices {
+		expanded, err := notices[i].Occurrences(from, to)
+		if err != nil {
+			// Rules are checked when notices are saved, so one failing here is skipped
+			log.Printf("Skipping events of notice %d: %v", notices[i].ID, err)
+			continue
+		}
+		occurrences = append(occurrences, expanded...)
+	}
+	util.SortOccurrences(occurrences)
+
+	return util.JSONResponse(http.StatusOK, occurrences)
+}
+
+// parseTime reads a date, as midnight in location, or an RFC 3339 time. Empty values give fallback.
+func parseTime(value string, fallback time.Time, location *time.Location) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.ParseInLocation(util.DateLayout, value, location); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func main() {
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeRead, Handler))
+}
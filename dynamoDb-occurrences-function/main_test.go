@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the notices and congregations in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+	util.Congregations = util.NewMemoryCongregationStore()
+
+	os.Exit(m.Run())
+}
+
+func member(congregationId string) context.Context {
+	return util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject:        "member",
+		CongregationId: congregationId,
+		Scopes:         []string{util.ScopeRead},
+	})
+}
+
+func occurrences(t *testing.T, ctx context.Context, query map[string]string) ([]util.Occurrence, int) {
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: query,
+	})
+	assert.NoError(t, err)
+
+	var result []util.Occurrence
+	if response.StatusCode == 200 {
+		err = json.Unmarshal([]byte(response.Body), &result)
+		assert.NoError(t, err)
+	}
+	return result, response.StatusCode
+}
+
+func TestHandler(t *testing.T) {
+	err := util.Congregations.CreateCongregation(context.Background(), &util.Congregation{Id: "north", Name: "North", TimeZone: "Europe/Amsterdam"})
+	assert.NoError(t, err)
+
+	for _, notice := range []*util.Notice{
+		{ID: 1, Title: "Midweek meeting", Type: util.NoticeTypeEvent, CongregationId: "north", Status: util.StatusPublished, Event: &util.EventDetails{
+			Start:    time.Date(2024, 5, 2, 17, 30, 0, 0, time.UTC),
+			End:      time.Date(2024, 5, 2, 19, 15, 0, 0, time.UTC),
+			TimeZone: "Europe/Amsterdam",
+			RRule:    "FREQ=WEEKLY",
+		}},
+		{ID: 2, Title: "Weekend meeting", Type: util.NoticeTypeEvent, CongregationId: "north", Status: util.StatusPublished, Event: &util.EventDetails{
+			Start:    time.Date(2024, 5, 5, 8, 0, 0, 0, time.UTC),
+			End:      time.Date(2024, 5, 5, 9, 45, 0, 0, time.UTC),
+			TimeZone: "Europe/Amsterdam",
+			RRule:    "FREQ=WEEKLY;BYDAY=SU",
+			ExDates:  []string{"2024-05-12"},
+		}},
+		{ID: 3, Title: "Hall cleaning", CongregationId: "north", Status: util.StatusPublished},
+		{ID: 4, Title: "Other meeting", Type: util.NoticeTypeEvent, CongregationId: "south", Status: util.StatusPublished, Event: &util.EventDetails{
+			Start: time.Date(2024, 5, 6, 18, 0, 0, 0, time.UTC),
+			End:   time.Date(2024, 5, 6, 19, 0, 0, 0, time.UTC),
+		}},
+	} {
+		err := util.Notices.PutNotice(context.Background(), notice)
+		assert.NoError(t, err)
+	}
+
+	// Dates are read in the congregation's time zone
+	result, status := occurrences(t, member("north"), map[string]string{"from": "2024-05-06", "to": "2024-05-20"})
+	assert.Equal(t, 200, status)
+	var got []string
+	for _, occurrence := range result {
+		got = append(got, occurrence.Title+" "+occurrence.Start.Format(time.RFC3339))
+	}
+	assert.Equal(t, []string{
+		"Midweek meeting 2024-05-09T19:30:00+02:00",
+		"Midweek meeting 2024-05-16T19:30:00+02:00",
+		"Weekend meeting 2024-05-19T10:00:00+02:00",
+	}, got)
+
+	// The coming week is shown by default
+	_, status = occurrences(t, member("north"), nil)
+	assert.Equal(t, 200, status)
+}
+
+func TestHandler_InvalidRange(t *testing.T) {
+	for _, query := range []map[string]string{
+		{"from": "next week"},
+		{"from": "2024-05-06", "to": "2024-05-06"},
+		{"from": "2024-01-01", "to": "2025-06-01"},
+	} {
+		_, status := occurrences(t, member(""), query)
+		assert.Equal(t, 400, status, query)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler runs on an EventBridge schedule and delivers the domain events left in the
+// outbox because the event bus did not take them when they were published
+func Handler(ctx context.Context, event events.CloudWatchEvent) (util.OutboxResult, error) {
+	result, err := util.RelayOutbox(ctx, time.Now())
+	if err != nil {
+		return result, err
+	}
+
+	log.Printf("Relayed %d of %d domain events, %d failed", result.Sent, result.Pending, result.Failed)
+	if result.DeadLetters > 0 {
+		log.Printf("%d domain events were given up on and are left in the outbox", result.DeadLetters)
+	}
+	return result, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
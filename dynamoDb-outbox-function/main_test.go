@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the outbox and the event bus in memory for the tests
+	util.Outbox = util.NewMemoryOutboxStore()
+	util.Bus = &util.MemoryDomainEventBus{}
+
+	os.Exit(m.Run())
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	event, err := util.NewDomainEvent(util.DomainNoticeDeleted, util.NoticeDeletedData{NoticeId: 7})
+	assert.NoError(t, err)
+	failedAt := time.Now().Add(-time.Hour)
+	err = util.Outbox.AddToOutbox(ctx, []util.OutboxRecord{
+		{Id: event.Id, Event: *event, CreatedAt: time.Now().Add(-time.Hour), Attempts: 1},
+		{Id: "new", Event: util.DomainEvent{Id: "new", Type: util.DomainNoticeDeleted}, CreatedAt: time.Now()},
+		{Id: "dead", Event: util.DomainEvent{Id: "dead", Type: util.DomainNoticeDeleted}, CreatedAt: time.Now().Add(-2 * time.Hour), Attempts: 10, FailedAt: &failedAt},
+	})
+	assert.NoError(t, err)
+
+	result, err := Handler(ctx, events.CloudWatchEvent{})
+	assert.NoError(t, err)
+	assert.Equal(t, util.OutboxResult{Pending: 1, Sent: 1, DeadLetters: 1}, result)
+
+	published := util.Bus.(*util.MemoryDomainEventBus).Published()
+	assert.Len(t, published, 1)
+	assert.Equal(t, event.Id, published[0].Id)
+
+	// Events just stored are left to their publisher, and dead letters stay put
+	records, err := util.Outbox.ListOutbox(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "dead", records[0].Id)
+	assert.Equal(t, "new", records[1].Id)
+}
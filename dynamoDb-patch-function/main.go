@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler applies a JSON merge patch to a notice, e.g. PATCH /notices/{id} with {"title": "New title"}
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Read the notice ID from the path parameter
+	id, err := util.NoticeIdFromRequest(events.APIGatewayProxyRequest{PathParameters: request.PathParameters})
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	// Get the notice from DynamoDB
+	existing, err := util.Notices.GetNotice(ctx, id)
+	if err != nil {
+		log.Println("Failed to get notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	claims, _ := util.SessionFromContext(ctx)
+	if existing == nil || !util.NoticeVisibleTo(claims, existing, time.Now()) {
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found")
+	}
+	if !util.CanEditNotice(claims, existing) {
+		return util.ErrorResponse(http.StatusForbidden, "Notice is under review or published")
+	}
+
+	// A version in the patch must match the stored one
+	body := []byte(request.Body)
+	if version := util.PatchVersion(body); version != 0 && version != existing.Version {
+		return util.ErrorResponse(http.StatusConflict, fmt.Sprintf("Notice was changed by someone else, the latest version is %d", existing.Version))
+	}
+
+	// Apply the patch and check the result
+	notice, attributes, err := util.ApplyNoticePatch(existing, body)
+	if err == nil {
+		err = util.ValidateNotice(ctx, notice)
+	}
+	var invalid *util.ValidationError
+	if errors.As(err, &invalid) {
+		return util.ErrorResponse(http.StatusBadRequest, invalid.Message)
+	}
+	if err != nil {
+		log.Println("Failed to apply patch:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	now := time.Now().UTC()
+	notice.UpdatedAt = now
+	notice.ApplySchedule(now)
+
+	// Write the changed fields to DynamoDB, unless someone else saved a newer version meanwhile
+	updated, err := util.Notices.UpdateNotice(ctx, notice, attributes, existing.Version, claims.Username)
+	if errors.Is(err, util.ErrConflict) {
+		return util.ErrorResponse(http.StatusConflict, "Notice was changed by someone else, please reload")
+	}
+	if err != nil {
+		log.Println("Failed to update notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	err = util.Events.Publish(ctx, util.NewNoticeEvent(util.EventNoticeUpdated, updated))
+	if err != nil {
+		log.Printf("Failed to publish event for notice %d: %v", updated.ID, err)
+	}
+
+	// Return the updated notice
+	return util.JSONResponse(http.StatusOK, updated)
+}
+
+func main() {
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeWrite, Handler))
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep notices and categories in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+	util.Categories = util.NewMemoryCategoryStore()
+
+	os.Exit(m.Run())
+}
+
+func coordinatorContext() context.Context {
+	return util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject:  "coordinator-sub",
+		Username: "coordinator",
+		Groups:   []string{util.GroupCoordinators},
+		Scopes:   []string{util.ScopeRead, util.ScopeWrite},
+	})
+}
+
+func patch(t *testing.T, id string, body string) events.APIGatewayProxyResponse {
+	response, err := Handler(coordinatorContext(), events.APIGatewayProxyRequest{
+		HTTPMethod:     "PATCH",
+		PathParameters: map[string]string{"id": id},
+		Body:           body,
+	})
+	assert.NoError(t, err)
+	return response
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	notice := &util.Notice{
+		ID:        1,
+		Title:     "Cleaning rota",
+		Content:   "Group 2",
+		Author:    "Jane",
+		Category:  "cleaning",
+		Tags:      []string{"rota"},
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	err := util.Notices.SaveNotice(ctx, notice, 0, "coordinator")
+	assert.NoError(t, err)
+
+	// Change the title, replace the tags and drop the category
+	response := patch(t, "1", `{"version": 1, "title": "Cleaning rota for June", "tags": ["Hall"], "category": null}`)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var updated util.Notice
+	err = json.Unmarshal([]byte(response.Body), &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, "Cleaning rota for June", updated.Title)
+	assert.Equal(t, "Group 2", updated.Content)
+	assert.Equal(t, "Jane", updated.Author)
+	assert.Equal(t, []string{"hall"}, updated.Tags)
+	assert.Empty(t, updated.Category)
+	assert.Equal(t, 2, updated.Version)
+	assert.Equal(t, createdAt, updated.CreatedAt)
+	assert.True(t, updated.UpdatedAt.After(createdAt))
+
+	// The patch was saved as a revision
+	revisions, err := util.Notices.ListRevisions(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+
+	// A patch based on the old version is rejected
+	response = patch(t, "1", `{"version": 1, "title": "Stale"}`)
+	assert.Equal(t, 409, response.StatusCode)
+}
+
+func TestHandler_Invalid(t *testing.T) {
+	notice := &util.Notice{ID: 2, Title: "Cleaning rota"}
+	err := util.Notices.SaveNotice(context.Background(), notice, 0, "coordinator")
+	assert.NoError(t, err)
+
+	for body, status := range map[string]int{
+		`{"author": "Someone else"}`: 400,
+		`{"created_at": 0}`:          400,
+		`{"status": "published"}`:    400,
+		`{"title": null}`:            400,
+		`{"title": 5}`:               400,
+		`{"category": "gardening"}`:  400,
+		`[]`:                         400,
+	} {
+		response := patch(t, "2", body)
+		assert.Equal(t, status, response.StatusCode, body)
+	}
+
+	// Missing notices are reported as such
+	response := patch(t, "3", `{"title": "Hello"}`)
+	assert.Equal(t, 404, response.StatusCode)
+}
+
+func TestHandler_Translations(t *testing.T) {
+	notice := &util.Notice{ID: 3, Title: "Cleaning", Content: "Saturday", Language: "en"}
+	err := util.Notices.SaveNotice(context.Background(), notice, 0, "coordinator")
+	assert.NoError(t, err)
+
+	// Translations are rendered and keyed by their conventional tags
+	response := patch(t, "3", `{"translations": {"nl-be": {"title": "Schoonmaak", "content": "**Zaterdag**"}}}`)
+	assert.Equal(t, 200, response.StatusCode)
+	stored, err := util.Notices.GetNotice(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "Cleaning", stored.Title)
+	assert.Equal(t, "<p><strong>Zaterdag</strong></p>\n", stored.Translations["nl-BE"].ContentHTML)
+
+	response = patch(t, "3", `{"translations": {"en": {"title": "Cleaning"}}}`)
+	assert.Equal(t, 400, response.StatusCode)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler reads (GET) and replaces (PUT) the notification preferences of the signed-in
+// user at /me/preferences. New members get DefaultPreferences when they confirm their
+// sign-up; users from before then have none and get no notifications until they save some.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, _ := util.SessionFromContext(ctx)
+	if claims == nil || claims.Device {
+		return util.ErrorResponse(http.StatusForbidden, "Display devices have no preferences")
+	}
+
+	switch request.HTTPMethod {
+	case http.MethodGet, "":
+		return getPreferences(ctx, claims)
+	case http.MethodPut:
+		// Reading needs a session; saving needs one that grants writes
+		if !claims.HasScope(util.ScopeWrite) {
+			return util.ErrorResponse(http.StatusForbidden, "Session token does not grant "+util.ScopeWrite+" access")
+		}
+		return putPreferences(ctx, claims, request.Body)
+	}
+	return util.ErrorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+func getPreferences(ctx context.Context, claims *util.SessionClaims) (events.APIGatewayProxyResponse, error) {
+	preferences, err := util.Preferences.GetPreferences(ctx, claims.Subject)
+	if err != nil {
+		log.Println("Failed to get preferences:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if preferences == nil {
+		preferences = &util.UserPreferences{UserId: claims.Subject, Email: claims.Email, PhoneNumber: claims.PhoneNumber, CongregationId: claims.CongregationId, Digest: util.DigestNone}
+	}
+	return preferencesResponse(preferences)
+}
+
+func putPreferences(ctx context.Context, claims *util.SessionClaims, body string) (events.APIGatewayProxyResponse, error) {
+	// Parse the request body
+	var preferences util.UserPreferences
+	err := json.Unmarshal([]byte(body), &preferences)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	// Who the user is and where notifications go come from the session, not the request
+	preferences.UserId = claims.Subject
+	preferences.Email = claims.Email
+	preferences.PhoneNumber = claims.PhoneNumber
+	preferences.CongregationId = claims.CongregationId
+	preferences.Groups = claims.Groups
+	preferences.UpdatedAt = time.Now().UTC()
+
+	err = util.ValidatePreferences(ctx, &preferences)
+	var invalid *util.ValidationError
+	if errors.As(err, &invalid) {
+		return util.ErrorResponse(http.StatusBadRequest, invalid.Message)
+	}
+	if err != nil {
+		log.Println("Failed to validate preferences:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	err = util.Preferences.PutPreferences(ctx, &preferences)
+	if err != nil {
+		log.Println("Failed to store preferences:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return preferencesResponse(&preferences)
+}
+
+// preferencesResponse returns the preferences, with empty lists rather than null
+func preferencesResponse(preferences *util.UserPreferences) (events.APIGatewayProxyResponse, error) {
+	if preferences.Channels == nil {
+		preferences.Channels = []string{}
+	}
+	if preferences.Categories == nil {
+		preferences.Categories = []string{}
+	}
+	return util.JSONResponse(http.StatusOK, preferences)
+}
+
+func main() {
+	// Only callers holding a valid session token may reach the handler; saving also needs the write scope
+	lambda.Start(util.RequireSession(util.ScopeRead, Handler))
+}
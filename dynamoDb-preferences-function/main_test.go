@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the preferences and congregations in memory for the tests
+	util.Preferences = util.NewMemoryPreferencesStore()
+	util.Congregations = util.NewMemoryCongregationStore()
+
+	os.Exit(m.Run())
+}
+
+func TestHandler(t *testing.T) {
+	err := util.Congregations.CreateCongregation(context.Background(), &util.Congregation{Id: "north", Name: "North", TimeZone: "Europe/Amsterdam"})
+	assert.NoError(t, err)
+	ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject:        "member",
+		Email:          "member@example.org",
+		PhoneNumber:    "+31612345678",
+		Groups:         []string{"publishers"},
+		CongregationId: "north",
+		Scopes:         []string{util.ScopeRead, util.ScopeWrite},
+	})
+
+	// Nothing is sent until the user saves preferences
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.JSONEq(t, `{"user_id": "member", "email": "member@example.org", "phone_number": "+31612345678", "congregation_id": "north", "channels": [], "categories": [], "digest": "none", "updated_at": "0001-01-01T00:00:00Z"}`, response.Body)
+
+	// The address and congregation come from the session
+	response, err = Handler(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod: "PUT",
+		Body: `{"channels": ["email", "sms", "push"], "categories": ["cleaning"], "digest": "weekly", "quiet_hours": {"start": "22:00", "end": "07:00"},
+			"email": "someone@example.org", "congregation_id": "south"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	preferences, err := util.Preferences.GetPreferences(context.Background(), "member")
+	assert.NoError(t, err)
+	assert.Equal(t, "member@example.org", preferences.Email)
+	assert.Equal(t, "north", preferences.CongregationId)
+	assert.Equal(t, []string{"publishers"}, preferences.Groups)
+	assert.Equal(t, "+31612345678", preferences.PhoneNumber)
+	assert.Equal(t, []string{"email", "sms", "push"}, preferences.Channels)
+	assert.Equal(t, util.DigestWeekly, preferences.Digest)
+
+	// Quiet hours are in the congregation's time zone, unless the user gives another
+	assert.Equal(t, &util.QuietHours{Start: "22:00", End: "07:00", TimeZone: "Europe/Amsterdam"}, preferences.QuietHours)
+
+	response, err = Handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	var result util.UserPreferences
+	err = json.Unmarshal([]byte(response.Body), &result)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cleaning"}, result.Categories)
+}
+
+func TestHandler_Invalid(t *testing.T) {
+	ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "member", Scopes: []string{util.ScopeRead, util.ScopeWrite}})
+
+	for _, body := range []string{`{"channels": ["pigeon"]}`, `{"channels": ["sms"]}`, `{"categories": ["Not a category"]}`, `{"digest": "hourly"}`,
+		`{"quiet_hours": {"start": "10pm", "end": "07:00"}}`, `{"quiet_hours": {"start": "22:00", "end": "22:00"}}`,
+		`{"quiet_hours": {"start": "22:00", "end": "07:00", "time_zone": "Mars/Olympus"}}`, `{`} {
+		response, err := Handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: "PUT", Body: body})
+		assert.NoError(t, err)
+		assert.Equal(t, 400, response.StatusCode, body)
+	}
+
+	device := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "device", Device: true, Scopes: []string{util.ScopeRead}})
+	response, err := Handler(device, events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+
+	// Read-only sessions cannot change preferences
+	reader := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "reader", Scopes: []string{util.ScopeRead}})
+	response, err = Handler(reader, events.APIGatewayProxyRequest{HTTPMethod: "PUT", Body: `{"channels": ["email"]}`})
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+	preferences, err := util.Preferences.GetPreferences(context.Background(), "reader")
+	assert.NoError(t, err)
+	assert.Nil(t, preferences)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// PurgeResult summarises one run of the purge
+type PurgeResult struct {
+	Checked int `json:"checked"`
+	Purged  int `json:"purged"`
+}
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler runs on an EventBridge schedule and permanently deletes notices that
+// have been in the trash for longer than TRASH_RETENTION_DAYS
+func Handler(ctx context.Context, event events.CloudWatchEvent) (PurgeResult, error) {
+	var result PurgeResult
+	now := time.Now().UTC()
+	retention := util.TrashRetention()
+
+	// Get the notices in the trash from DynamoDB
+	notices, err := util.Notices.ListNotices(ctx, util.NoticeFilter{Deleted: true})
+	if err != nil {
+		return result, err
+	}
+
+	for i := range notices {
+		notice := &notices[i]
+		result.Checked++
+		if !notice.PurgeDueAt(now, retention) {
+			continue
+		}
+
+		// Remove the attached files first so none are left behind without a notice
+		err = util.Objects.DeleteObjects(ctx, notice.AttachmentKeys())
+		if err != nil {
+			return result, err
+		}
+		err = util.Notices.DeleteNotice(ctx, notice.ID)
+		if err != nil {
+			return result, err
+		}
+		result.Purged++
+	}
+
+	log.Printf("Checked %d notices in the trash: %d purged", result.Checked, result.Purged)
+	return result, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
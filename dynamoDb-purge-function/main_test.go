@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+var objects *util.MemoryObjectStore
+
+func TestMain(m *testing.M) {
+	// Keep the notices in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+	objects = util.NewMemoryObjectStore()
+	util.Objects = objects
+	os.Setenv("TRASH_RETENTION_DAYS", "7")
+
+	os.Exit(m.Run())
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	for _, notice := range []*util.Notice{
+		{ID: 1, Title: "Live"},
+		{ID: 2, Title: "Deleted yesterday"},
+		{ID: 3, Title: "Deleted last month", Attachments: []util.Attachment{{Id: "a1", Key: "notices/3/a1/rota.pdf"}}},
+	} {
+		err := util.Notices.PutNotice(ctx, notice)
+		assert.NoError(t, err)
+	}
+	objects.Put("notices/3/a1/rota.pdf", []byte("%PDF"))
+	err := util.Notices.TrashNotice(ctx, 2, "coordinator", now.Add(-24*time.Hour))
+	assert.NoError(t, err)
+	err = util.Notices.TrashNotice(ctx, 3, "coordinator", now.Add(-30*24*time.Hour))
+	assert.NoError(t, err)
+
+	result, err := Handler(ctx, events.CloudWatchEvent{})
+	assert.NoError(t, err)
+	assert.Equal(t, PurgeResult{Checked: 2, Purged: 1}, result)
+
+	// Only the notice past the retention period is gone
+	for id, exists := range map[int]bool{1: true, 2: true, 3: false} {
+		notice, err := util.Notices.GetNotice(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, exists, notice != nil, "notice %d", id)
+	}
+
+	// And its attachment with it
+	assert.Empty(t, objects.Keys())
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler manages Web Push for the web app. GET /push/key returns the VAPID public key
+// the browser subscribes with. The signed-in user's devices are listed with
+// GET /me/push-subscriptions, registered by POSTing the subscription from
+// PushManager.subscribe() and unregistered with DELETE and the subscription's endpoint.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if strings.HasSuffix(request.Path, "/key") {
+		return getKey()
+	}
+
+	claims, _ := util.SessionFromContext(ctx)
+	if claims == nil || claims.Device {
+		return util.ErrorResponse(http.StatusForbidden, "Display devices have no push subscriptions")
+	}
+
+	switch request.HTTPMethod {
+	case http.MethodGet, "":
+		return listSubscriptions(ctx, claims)
+	case http.MethodPost:
+		return subscribe(ctx, claims, request)
+	case http.MethodDelete:
+		return unsubscribe(ctx, claims, request)
+	}
+	return util.ErrorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+func getKey() (events.APIGatewayProxyResponse, error) {
+	keys, err := util.LoadVAPIDKeys()
+	if err != nil {
+		log.Println("Failed to load VAPID keys:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	return util.JSONResponse(http.StatusOK, map[string]string{"public_key": keys.PublicKeyString()})
+}
+
+func listSubscriptions(ctx context.Context, claims *util.SessionClaims) (events.APIGatewayProxyResponse, error) {
+	subscriptions, err := util.PushSubscriptions.ListPushSubscriptions(ctx, claims.Subject)
+	if err != nil {
+		log.Println("Failed to list push subscriptions:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// The keys are only needed to send messages
+	devices := make([]map[string]interface{}, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		devices = append(devices, map[string]interface{}{
+			"endpoint":   subscription.Endpoint,
+			"user_agent": subscription.UserAgent,
+			"created_at": subscription.CreatedAt,
+		})
+	}
+	return util.JSONResponse(http.StatusOK, devices)
+}
+
+func subscribe(ctx context.Context, claims *util.SessionClaims, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Parse the request body
+	var subscription util.PushSubscription
+	err := json.Unmarshal([]byte(request.Body), &subscription)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	err = util.ValidatePushSubscription(&subscription)
+	var invalid *util.ValidationError
+	if errors.As(err, &invalid) {
+		return util.ErrorResponse(http.StatusBadRequest, invalid.Message)
+	}
+
+	subscription.UserId = claims.Subject
+	subscription.UserAgent = request.Headers["User-Agent"]
+	subscription.CreatedAt = time.Now().UTC()
+	err = util.PushSubscriptions.PutPushSubscription(ctx, &subscription)
+	if err != nil {
+		log.Println("Failed to store push subscription:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusCreated, map[string]string{"endpoint": subscription.Endpoint})
+}
+
+func unsubscribe(ctx context.Context, claims *util.SessionClaims, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var subscription util.PushSubscription
+	if request.Body != "" {
+		err := json.Unmarshal([]byte(request.Body), &subscription)
+		if err != nil {
+			return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		}
+	} else {
+		subscription.Endpoint = request.QueryStringParameters["endpoint"]
+	}
+	if subscription.Endpoint == "" {
+		return util.ErrorResponse(http.StatusBadRequest, "Missing endpoint")
+	}
+
+	err := util.PushSubscriptions.DeletePushSubscription(ctx, claims.Subject, subscription.Endpoint)
+	if err != nil {
+		log.Println("Failed to delete push subscription:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	return util.JSONResponse(http.StatusOK, map[string]string{
+		"message": "Push subscription removed",
+	})
+}
+
+func main() {
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeRead, Handler))
+}
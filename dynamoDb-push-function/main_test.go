@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the subscriptions in memory for the tests
+	util.PushSubscriptions = util.NewMemoryPushSubscriptionStore()
+
+	os.Exit(m.Run())
+}
+
+func TestHandler(t *testing.T) {
+	ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject: "member",
+		Scopes:  []string{util.ScopeRead},
+	})
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	p256dh := base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes())
+
+	// The browser subscribes with the server's public key
+	t.Setenv("VAPID_PRIVATE_KEY", base64.RawURLEncoding.EncodeToString(key.Bytes()))
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/push/key"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.JSONEq(t, `{"public_key": "`+p256dh+`"}`, response.Body)
+
+	// and registers the subscription it got
+	response, err = Handler(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/me/push-subscriptions",
+		Headers:    map[string]string{"User-Agent": "Firefox"},
+		Body:       `{"endpoint": "https://push.example.org/send/1", "expirationTime": null, "keys": {"p256dh": "` + p256dh + `", "auth": "AAAAAAAAAAAAAAAAAAAAAA"}}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 201, response.StatusCode)
+
+	subscriptions, err := util.PushSubscriptions.ListPushSubscriptions(context.Background(), "member")
+	assert.NoError(t, err)
+	assert.Len(t, subscriptions, 1)
+	assert.Equal(t, "Firefox", subscriptions[0].UserAgent)
+
+	response, err = Handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/me/push-subscriptions"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	var devices []map[string]interface{}
+	err = json.Unmarshal([]byte(response.Body), &devices)
+	assert.NoError(t, err)
+	assert.Len(t, devices, 1)
+	assert.Equal(t, "https://push.example.org/send/1", devices[0]["endpoint"])
+	assert.NotContains(t, devices[0], "keys")
+
+	response, err = Handler(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod: "DELETE",
+		Path:       "/me/push-subscriptions",
+		Body:       `{"endpoint": "https://push.example.org/send/1"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	subscriptions, err = util.PushSubscriptions.ListPushSubscriptions(context.Background(), "member")
+	assert.NoError(t, err)
+	assert.Empty(t, subscriptions)
+}
+
+func TestHandler_Invalid(t *testing.T) {
+	ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject: "member",
+		Scopes:  []string{util.ScopeRead},
+	})
+
+	for _, body := range []string{
+		`not json`,
+		`{"endpoint": "http://push.example.org/send/1", "keys": {"p256dh": "BAAA", "auth": "AAAAAAAAAAAAAAAAAAAAAA"}}`,
+		`{"endpoint": "https://push.example.org/send/1", "keys": {"p256dh": "BAAA", "auth": "AAAAAAAAAAAAAAAAAAAAAA"}}`,
+	} {
+		response, err := Handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/me/push-subscriptions", Body: body})
+		assert.NoError(t, err)
+		assert.Equal(t, 400, response.StatusCode, body)
+	}
+
+	// Display devices cannot subscribe
+	device := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "device", Device: true})
+	response, err := Handler(device, events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/me/push-subscriptions"})
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+}
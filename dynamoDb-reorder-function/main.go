@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// ReorderRequest lists notice IDs in the order they should be shown
+type ReorderRequest struct {
+	Ids []int `json:"ids"`
+}
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler sets the manual order of a category's notices (PUT /categories/{name}/order).
+// The given notices come first; the rest of the category follows in its current order.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	category := request.PathParameters["name"]
+	if category == "" {
+		return util.ErrorResponse(http.StatusBadRequest, "category name is required")
+	}
+
+	// Parse the request body
+	var reorder ReorderRequest
+	err := json.Unmarshal([]byte(request.Body), &reorder)
+	if err != nil || len(reorder.Ids) == 0 {
+		return util.ErrorResponse(http.StatusBadRequest, "ids must list the notices in their new order")
+	}
+
+	// Get the category's notices in the caller's congregation
+	claims, _ := util.SessionFromContext(ctx)
+	filter := util.NoticeFilter{Category: category}
+	if claims != nil {
+		filter.CongregationId = claims.CongregationId
+	}
+	listed, err := util.Notices.ListNotices(ctx, filter)
+	if err != nil {
+		log.Println("Failed to list notices:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	notices := make(map[int]util.Notice)
+	var current []util.Notice
+	for _, notice := range listed {
+		if util.InCongregation(claims, &notice) {
+			notices[notice.ID] = notice
+			current = append(current, notice)
+		}
+	}
+
+	// Put the requested notices first
+	var ordered []util.Notice
+	seen := make(map[int]bool)
+	for _, id := range reorder.Ids {
+		notice, ok := notices[id]
+		if !ok {
+			return util.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("Notice %d is not in category %s", id, category))
+		}
+		if seen[id] {
+			return util.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("Notice %d is listed twice", id))
+		}
+		seen[id] = true
+		ordered = append(ordered, notice)
+	}
+
+	// And keep the others in the order they are shown now
+	util.SortForBoard(current)
+	for _, notice := range current {
+		if !seen[notice.ID] {
+			ordered = append(ordered, notice)
+		}
+	}
+	if len(ordered) > util.MaxReorder {
+		return util.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("A category with more than %d notices cannot be reordered", util.MaxReorder))
+	}
+
+	// Number them all at once, so readers never see a half-applied order
+	ids := make([]int, len(ordered))
+	for i := range ordered {
+		ids[i] = ordered[i].ID
+		ordered[i].Position = i + 1
+	}
+	err = util.Notices.ReorderNotices(ctx, filter.CongregationId, category, ids)
+	if errors.Is(err, util.ErrConflict) {
+		return util.ErrorResponse(http.StatusConflict, "Notices were changed by someone else, please reload")
+	}
+	if err != nil {
+		log.Println("Failed to reorder notices:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// Return the notices as the board now shows them
+	util.SortForBoard(ordered)
+	return util.JSONResponse(http.StatusOK, ordered)
+}
+
+func main() {
+	// The order of notices is managed by coordinators and admins
+	lambda.Start(util.RequireSession(util.ScopeWrite, util.RequireGroup(Handler, util.GroupCoordinators, util.GroupAdmins)))
+}
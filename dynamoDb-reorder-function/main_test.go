@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the notices in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+
+	os.Exit(m.Run())
+}
+
+func coordinator(congregationId string) context.Context {
+	return util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject:        "coordinator",
+		CongregationId: congregationId,
+		Groups:         []string{util.GroupCoordinators},
+		Scopes:         []string{util.ScopeRead, util.ScopeWrite},
+	})
+}
+
+func reorder(t *testing.T, ctx context.Context, body string) events.APIGatewayProxyResponse {
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod:     "PUT",
+		PathParameters: map[string]string{"name": "cleaning"},
+		Body:           body,
+	})
+	assert.NoError(t, err)
+	return response
+}
+
+func TestHandler(t *testing.T) {
+	now := time.Now().UTC()
+	for _, notice := range []*util.Notice{
+		{ID: 1, Title: "Hall", Category: "cleaning", CongregationId: "north", CreatedAt: now.Add(-3 * time.Hour)},
+		{ID: 2, Title: "Garden", Category: "cleaning", CongregationId: "north", CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: 3, Title: "Windows", Category: "cleaning", CongregationId: "north", CreatedAt: now.Add(-time.Hour)},
+		{ID: 4, Title: "Storm damage", Category: "cleaning", CongregationId: "north", Priority: util.PriorityUrgent, CreatedAt: now.Add(-4 * time.Hour)},
+		{ID: 5, Title: "Other hall", Category: "cleaning", CongregationId: "south", CreatedAt: now},
+	} {
+		err := util.Notices.PutNotice(context.Background(), notice)
+		assert.NoError(t, err)
+	}
+
+	// Move the oldest notice to the top; the others keep their order behind it
+	response := reorder(t, coordinator("north"), `{"ids": [1]}`)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var notices []util.Notice
+	err := json.Unmarshal([]byte(response.Body), &notices)
+	assert.NoError(t, err)
+	var ids []int
+	for _, notice := range notices {
+		ids = append(ids, notice.ID)
+	}
+	// Urgent notices stay ahead of the manual order
+	assert.Equal(t, []int{4, 1, 3, 2}, ids)
+
+	stored, err := util.Notices.GetNotice(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stored.Position)
+	assert.Equal(t, 1, stored.Version)
+
+	// Pinning moves a notice above the urgent one
+	stored.Pinned = true
+	err = util.Notices.PutNotice(context.Background(), stored)
+	assert.NoError(t, err)
+	listed, err := util.Notices.ListNotices(context.Background(), util.NoticeFilter{CongregationId: "north"})
+	assert.NoError(t, err)
+	util.SortForBoard(listed)
+	assert.Equal(t, 3, listed[0].ID)
+	assert.Equal(t, 4, listed[1].ID)
+}
+
+func TestHandler_Invalid(t *testing.T) {
+	err := util.Notices.PutNotice(context.Background(), &util.Notice{ID: 11, Title: "Hall", Category: "cleaning", CongregationId: "east"})
+	assert.NoError(t, err)
+	err = util.Notices.PutNotice(context.Background(), &util.Notice{ID: 12, Title: "Talk", Category: "meetings", CongregationId: "east"})
+	assert.NoError(t, err)
+
+	// Notices of another category or congregation cannot be placed
+	assert.Equal(t, 400, reorder(t, coordinator("east"), `{"ids": [12]}`).StatusCode)
+	assert.Equal(t, 400, reorder(t, coordinator("west"), `{"ids": [11]}`).StatusCode)
+	assert.Equal(t, 400, reorder(t, coordinator("east"), `{"ids": [11, 11]}`).StatusCode)
+	assert.Equal(t, 400, reorder(t, coordinator("east"), `{"ids": []}`).StatusCode)
+
+	// A store refuses the whole order if one notice moved meanwhile
+	err = util.Notices.ReorderNotices(context.Background(), "east", "cleaning", []int{11, 12})
+	assert.ErrorIs(t, err, util.ErrConflict)
+	stored, err := util.Notices.GetNotice(context.Background(), 11)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stored.Position)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// DiffResponse lists the changes between two versions of a notice
+type DiffResponse struct {
+	NoticeId int                `json:"notice_id"`
+	From     int                `json:"from"`
+	To       int                `json:"to"`
+	Changes  []util.FieldChange `json:"changes"`
+}
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler serves the revision history of a notice:
+//   - GET /notices/{id}/revisions lists the saved versions
+//   - GET /notices/{id}/revisions?from=1&to=3 compares two versions
+//   - GET /notices/{id}/revisions/{version} returns one version
+//   - POST /notices/{id}/revisions/{version} restores a version as the newest one
+//
+// Revisions hold earlier drafts and review comments, so only editors and the notice's
+// author may read them.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Read the notice ID from the path or the request body
+	id, err := util.NoticeIdFromRequest(request)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	// Get the notice from DynamoDB
+	notice, err := util.Notices.GetNotice(ctx, id)
+	if err != nil {
+		log.Println("Failed to get notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	claims, _ := util.SessionFromContext(ctx)
+	if notice == nil || !util.NoticeVisibleTo(claims, notice, time.Now()) {
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found")
+	}
+
+	version := 0
+	if value := request.PathParameters["version"]; value != "" {
+		version, err = strconv.Atoi(value)
+		if err != nil || version <= 0 {
+			return util.ErrorResponse(http.StatusBadRequest, "version must be a positive integer")
+		}
+	}
+
+	switch request.HTTPMethod {
+	case http.MethodGet, "":
+		if !util.CanViewHistory(claims, notice) {
+			return util.ErrorResponse(http.StatusForbidden, "Only editors and the author can see the history of a notice")
+		}
+		if version > 0 {
+			return getRevision(ctx, id, version)
+		}
+		if request.QueryStringParameters["from"] != "" || request.QueryStringParameters["to"] != "" {
+			return diffRevisions(ctx, id, request.QueryStringParameters)
+		}
+		return listRevisions(ctx, id)
+	case http.MethodPost:
+		if version == 0 {
+			return util.ErrorResponse(http.StatusBadRequest, "version is required")
+		}
+		if !util.CanEditNotice(claims, notice) {
+			return util.ErrorResponse(http.StatusForbidden, "Notice is under review or published")
+		}
+		return restoreRevision(ctx, claims, notice, version)
+	}
+	return util.ErrorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+func listRevisions(ctx context.Context, id int) (events.APIGatewayProxyResponse, error) {
+	revisions, err := util.Notices.ListRevisions(ctx, id)
+	if err != nil {
+		log.Println("Failed to list revisions:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return util.JSONResponse(http.StatusOK, map[string]interface{}{
+		"revisions": revisions,
+	})
+}
+
+func getRevision(ctx context.Context, id, version int) (events.APIGatewayProxyResponse, error) {
+	revision, err := util.Notices.GetRevision(ctx, id, version)
+	if err != nil {
+		log.Println("Failed to get revision:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if revision == nil {
+		return util.ErrorResponse(http.StatusNotFound, "Revision not found")
+	}
+
+	return util.JSONResponse(http.StatusOK, revision)
+}
+
+func diffRevisions(ctx context.Context, id int, query map[string]string) (events.APIGatewayProxyResponse, error) {
+	// Parse the versions to compare
+	from, err := strconv.Atoi(query["from"])
+	if err != nil || from <= 0 {
+		return util.ErrorResponse(http.StatusBadRequest, "from must be a positive integer")
+	}
+	to, err := strconv.Atoi(query["to"])
+	if err != nil || to <= 0 {
+		return util.ErrorResponse(http.StatusBadRequest, "to must be a positive integer")
+	}
+
+	revisions := make([]*util.Revision, 0, 2)
+	for _, version := range []int{from, to} {
+		revision, err := util.Notices.GetRevision(ctx, id, version)
+		if err != nil {
+			log.Println("Failed to get revision:", err)
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		}
+		if revision == nil {
+			return util.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Revision %d not found", version))
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return util.JSONResponse(http.StatusOK, DiffResponse{
+		NoticeId: id,
+		From:     from,
+		To:       to,
+		Changes:  util.DiffNotices(&revisions[0].Notice, &revisions[1].Notice),
+	})
+}
+
+func restoreRevision(ctx context.Context, claims *util.SessionClaims, notice *util.Notice, version int) (events.APIGatewayProxyResponse, error) {
+	revision, err := util.Notices.GetRevision(ctx, notice.ID, version)
+	if err != nil {
+		log.Println("Failed to get revision:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	if revision == nil {
+		return util.ErrorResponse(http.StatusNotFound, "Revision not found")
+	}
+
+	// Restoring saves the old content as a new version
+	now := time.Now().UTC()
+	expectedVersion := notice.Version
+	notice.RestoreRevision(revision)
+	notice.UpdatedAt = now
+	notice.ApplySchedule(now)
+
+	err = util.Notices.SaveNotice(ctx, notice, expectedVersion, claims.Username)
+	if errors.Is(err, util.ErrConflict) {
+		return util.ErrorResponse(http.StatusConflict, "Notice was changed by someone else, please reload")
+	}
+	if err != nil {
+		log.Println("Failed to restore notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// Return the restored notice
+	return util.JSONResponse(http.StatusOK, notice)
+}
+
+func main() {
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeWrite, Handler))
+}
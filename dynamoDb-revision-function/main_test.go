@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the notices in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+
+	os.Exit(m.Run())
+}
+
+func coordinatorContext() context.Context {
+	return util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject:  "coordinator-sub",
+		Username: "coordinator",
+		Groups:   []string{util.GroupCoordinators},
+		Scopes:   []string{util.ScopeRead, util.ScopeWrite},
+	})
+}
+
+// saveVersions stores one version of notice 1 per title
+func saveVersions(t *testing.T, titles ...string) {
+	for i, title := range titles {
+		notice := &util.Notice{ID: 1, Title: title, Content: "Group 2", UpdatedAt: time.Now().UTC()}
+		err := util.Notices.SaveNotice(context.Background(), notice, i, "coordinator")
+		assert.NoError(t, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	util.Notices = util.NewMemoryNoticeStore()
+	saveVersions(t, "Cleaning rota", "Cleaning rota for May", "Cleaning rota for June")
+	ctx := coordinatorContext()
+
+	// List the revisions, newest first
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod:     "GET",
+		PathParameters: map[string]string{"id": "1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var list struct {
+		Revisions []util.Revision `json:"revisions"`
+	}
+	err = json.Unmarshal([]byte(response.Body), &list)
+	assert.NoError(t, err)
+	assert.Len(t, list.Revisions, 3)
+	assert.Equal(t, 3, list.Revisions[0].Version)
+
+	// Compare the first and the last version
+	response, err = Handler(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		PathParameters:        map[string]string{"id": "1"},
+		QueryStringParameters: map[string]string{"from": "1", "to": "3"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var diff DiffResponse
+	err = json.Unmarshal([]byte(response.Body), &diff)
+	assert.NoError(t, err)
+	assert.Equal(t, []util.FieldChange{{Field: "title", From: "Cleaning rota", To: "Cleaning rota for June"}}, diff.Changes)
+
+	// Restore the first version as version 4
+	response, err = Handler(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod:     "POST",
+		PathParameters: map[string]string{"id": "1", "version": "1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	notice, err := util.Notices.GetNotice(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, notice.Version)
+	assert.Equal(t, "Cleaning rota", notice.Title)
+}
+
+func TestHandler_Missing(t *testing.T) {
+	util.Notices = util.NewMemoryNoticeStore()
+	saveVersions(t, "Cleaning rota")
+
+	response, err := Handler(coordinatorContext(), events.APIGatewayProxyRequest{
+		HTTPMethod:     "GET",
+		PathParameters: map[string]string{"id": "1", "version": "7"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+
+	// Members cannot restore published notices
+	member := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "member"})
+	response, err = Handler(member, events.APIGatewayProxyRequest{
+		HTTPMethod:     "POST",
+		PathParameters: map[string]string{"id": "1", "version": "1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+}
+
+func TestHandler_History(t *testing.T) {
+	util.Notices = util.NewMemoryNoticeStore()
+	draft := &util.Notice{ID: 1, Title: "Draft title", AuthorId: "author", Status: util.StatusDraft}
+	err := util.Notices.SaveNotice(context.Background(), draft, 0, "author")
+	assert.NoError(t, err)
+	published := &util.Notice{ID: 1, Title: "Cleaning", AuthorId: "author", Status: util.StatusPublished,
+		ReviewComments: []util.ReviewComment{{Action: "approve", Comment: "Mention group 2"}}}
+	err = util.Notices.SaveNotice(context.Background(), published, 1, "coordinator")
+	assert.NoError(t, err)
+
+	// Other members see the published notice, but not its drafts and review comments
+	member := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "member"})
+	for _, request := range []events.APIGatewayProxyRequest{
+		{HTTPMethod: "GET", PathParameters: map[string]string{"id": "1"}},
+		{HTTPMethod: "GET", PathParameters: map[string]string{"id": "1", "version": "1"}},
+		{HTTPMethod: "GET", PathParameters: map[string]string{"id": "1"}, QueryStringParameters: map[string]string{"from": "1", "to": "2"}},
+	} {
+		response, err := Handler(member, request)
+		assert.NoError(t, err)
+		assert.Equal(t, 403, response.StatusCode)
+	}
+
+	// The author and editors do
+	author := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "author"})
+	for _, ctx := range []context.Context{author, coordinatorContext()} {
+		response, err := Handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: "GET", PathParameters: map[string]string{"id": "1", "version": "1"}})
+		assert.NoError(t, err)
+		assert.Equal(t, 200, response.StatusCode)
+		assert.Contains(t, response.Body, "Draft title")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// ScheduleResult summarises one run of the scheduler
+type ScheduleResult struct {
+	Checked   int `json:"checked"`
+	Scheduled int `json:"scheduled"`
+	Live      int `json:"live"`
+	Expired   int `json:"expired"`
+}
+
+// stateEvents maps each schedule state to the event emitted when a notice enters it
+var stateEvents = map[string]string{
+	util.ScheduleScheduled: util.EventNoticeScheduled,
+	util.ScheduleLive:      util.EventNoticeLive,
+	util.ScheduleExpired:   util.EventNoticeExpired,
+}
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler runs on an EventBridge schedule and moves notices between the
+// scheduled, live and expired states, emitting an event for every change
+func Handler(ctx context.Context, event events.CloudWatchEvent) (ScheduleResult, error) {
+	var result ScheduleResult
+	now := time.Now().UTC()
+
+	// Get all notices from DynamoDB
+	notices, err := util.Notices.ListNotices(ctx, util.NoticeFilter{})
+	if err != nil {
+		return result, err
+	}
+
+	for i := range notices {
+		notice := &notices[i]
+		result.Checked++
+
+		state := notice.ScheduleStateAt(now)
+		if state == notice.ScheduleState {
+			continue
+		}
+
+		// Only flip the state if nobody else changed it in the meantime
+		err = util.Notices.UpdateScheduleState(ctx, notice.ID, notice.ScheduleState, state)
+		if errors.Is(err, util.ErrConflict) {
+			log.Printf("Notice %d changed while scheduling, skipping", notice.ID)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		notice.ScheduleState = state
+
+		switch state {
+		case util.ScheduleScheduled:
+			result.Scheduled++
+		case util.ScheduleLive:
+			result.Live++
+		case util.ScheduleExpired:
+			result.Expired++
+		}
+
+		err = util.Events.Publish(ctx, util.NewNoticeEvent(stateEvents[state], notice))
+		if err != nil {
+			log.Printf("Failed to publish event for notice %d: %v", notice.ID, err)
+		}
+	}
+
+	log.Printf("Checked %d notices: %d scheduled, %d live, %d expired", result.Checked, result.Scheduled, result.Live, result.Expired)
+	return result, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+var published *util.MemoryEventPublisher
+
+func TestMain(m *testing.M) {
+	// Keep notices and events in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+	published = &util.MemoryEventPublisher{}
+	util.Events = published
+
+	os.Exit(m.Run())
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	// Store notices whose schedule state is out of date
+	notices := []*util.Notice{
+		{ID: 1, Title: "Now live", PublishAt: &past, ScheduleState: util.ScheduleScheduled},
+		{ID: 2, Title: "Now expired", ExpireAt: &past, ScheduleState: util.ScheduleLive},
+		{ID: 3, Title: "Still scheduled", PublishAt: &future, ScheduleState: util.ScheduleScheduled},
+		{ID: 4, Title: "Still live", ExpireAt: &future, ScheduleState: util.ScheduleLive},
+	}
+	for _, notice := range notices {
+		err := util.Notices.PutNotice(ctx, notice)
+		assert.NoError(t, err)
+	}
+
+	result, err := Handler(ctx, events.CloudWatchEvent{})
+	assert.NoError(t, err)
+	assert.Equal(t, ScheduleResult{Checked: 4, Live: 1, Expired: 1}, result)
+
+	// The stored states were flipped
+	notice, err := util.Notices.GetNotice(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, util.ScheduleLive, notice.ScheduleState)
+	notice, err = util.Notices.GetNotice(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, util.ScheduleExpired, notice.ScheduleState)
+
+	// And an event was emitted for each change
+	var types []string
+	for _, event := range published.Published() {
+		types = append(types, event.Type)
+	}
+	assert.ElementsMatch(t, []string{util.EventNoticeLive, util.EventNoticeExpired}, types)
+
+	// A second run has nothing left to do
+	result, err = Handler(ctx, events.CloudWatchEvent{})
+	assert.NoError(t, err)
+	assert.Equal(t, ScheduleResult{Checked: 4}, result)
+	assert.Len(t, published.Published(), 2)
+}
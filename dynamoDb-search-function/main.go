@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// Search results are limited to defaultLimit unless the caller asks for more, up to maxLimit
+const (
+	defaultLimit = 20
+	maxLimit     = 50
+)
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler searches the notices. GET /notices/search?q= returns the notices the caller may
+// see that contain every word of the query, best matches first, with the matching words
+// marked in the title and an extract of the content. The results can be narrowed to a
+// category and limited in number. Admins rebuild the index with POST /notices/search/index.
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	claims, _ := util.SessionFromContext(ctx)
+	if request.HTTPMethod == http.MethodPost && strings.HasSuffix(request.Path, "/index") {
+		return rebuild(ctx, claims)
+	}
+	if request.HTTPMethod != http.MethodGet && request.HTTPMethod != "" {
+		return util.ErrorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+
+	query := util.SearchQuery{
+		Text:     strings.TrimSpace(request.QueryStringParameters["q"]),
+		Category: request.QueryStringParameters["category"],
+		Limit:    defaultLimit,
+	}
+	if query.Text == "" {
+		return util.ErrorResponse(http.StatusBadRequest, "Missing search query")
+	}
+	if limit := request.QueryStringParameters["limit"]; limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxLimit {
+			return util.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("Limit must be between 1 and %d", maxLimit))
+		}
+		query.Limit = n
+	}
+
+	results, err := util.SearchNotices(ctx, claims, query, time.Now())
+	if err != nil {
+		log.Println("Failed to search notices:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	return util.JSONResponse(http.StatusOK, results)
+}
+
+func rebuild(ctx context.Context, claims *util.SessionClaims) (events.APIGatewayProxyResponse, error) {
+	// Rebuilding the index is reserved for admins
+	if claims == nil || !claims.HasScope(util.ScopeWrite) || !claims.InGroup(util.GroupAdmins) {
+		return util.ErrorResponse(http.StatusForbidden, "Insufficient permissions")
+	}
+
+	count, err := util.RebuildSearchIndex(ctx)
+	if err != nil {
+		log.Println("Failed to rebuild search index:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	return util.JSONResponse(http.StatusOK, map[string]int{"indexed": count})
+}
+
+func main() {
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeRead, Handler))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the notices and the index in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+	util.Search = util.NewMemorySearchIndex()
+
+	os.Exit(m.Run())
+}
+
+func TestHandler(t *testing.T) {
+	member := util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject: "member",
+		Scopes:  []string{util.ScopeRead},
+	})
+	admin := util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject: "admin",
+		Groups:  []string{util.GroupAdmins},
+		Scopes:  []string{util.ScopeRead, util.ScopeWrite},
+	})
+	for _, notice := range []*util.Notice{
+		{ID: 1, Title: "Circuit assembly", Content: "Bring your **lunch**.", Category: "events", Status: util.StatusPublished},
+		{ID: 2, Title: "Cleaning", Content: "The hall is cleaned after the assembly.", Category: "cleaning", Status: util.StatusPublished},
+	} {
+		notice.RenderContent()
+		require.NoError(t, util.Notices.PutNotice(context.Background(), notice))
+	}
+
+	// Only admins rebuild the index
+	response, err := Handler(member, events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/notices/search/index"})
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+
+	response, err = Handler(admin, events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/notices/search/index"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.JSONEq(t, `{"indexed": 2}`, response.Body)
+
+	// Title matches come first
+	response, err = Handler(member, events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/notices/search",
+		QueryStringParameters: map[string]string{"q": "assemblies"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	var results []util.SearchResult
+	require.NoError(t, json.Unmarshal([]byte(response.Body), &results))
+	require.Len(t, results, 2)
+	assert.Equal(t, 1, results[0].Notice.ID)
+	assert.Equal(t, "Circuit <mark>assembly</mark>", results[0].Title)
+	assert.Equal(t, "The hall is cleaned after the <mark>assembly</mark>.", results[1].Snippet)
+
+	// The results can be narrowed to a category and limited
+	response, err = Handler(member, events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/notices/search",
+		QueryStringParameters: map[string]string{"q": "assembly", "category": "cleaning", "limit": "1"},
+	})
+	assert.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(response.Body), &results))
+	require.Len(t, results, 1)
+	assert.Equal(t, 2, results[0].Notice.ID)
+
+	for _, parameters := range []map[string]string{
+		{"q": " "},
+		{"q": "assembly", "limit": "500"},
+	} {
+		response, err = Handler(member, events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/notices/search", QueryStringParameters: parameters})
+		assert.NoError(t, err)
+		assert.Equal(t, 400, response.StatusCode)
+	}
+}
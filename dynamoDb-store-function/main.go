@@ -3,75 +3,150 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
 	"github.com/joho/godotenv"
 )
 
-// Define a generic interface for your item
-type Item interface{}
-
 func init() {
 	// Load environment variables from .env file
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file:", err)
+		fmt.Println("Error loading .env file:", err)
 	}
 }
 
 func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Create a new DynamoDB session
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(os.Getenv("AWS_REGION")),
-	})
+	// Unmarshal the request body into a notice
+	var notice util.Notice
+	err := json.Unmarshal([]byte(event.Body), &notice)
 	if err != nil {
-		log.Fatal(err)
+		return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
 	}
 
-	// Create a new DynamoDB client
-	db := dynamodb.New(sess)
-
-	// Unmarshal the request body into an Item interface
-	var item Item
-	err = json.Unmarshal([]byte(event.Body), &item)
+	// Check the fields and normalise the tags
+	err = util.ValidateNotice(ctx, &notice)
+	var invalid *util.ValidationError
+	if errors.As(err, &invalid) {
+		return util.ErrorResponse(http.StatusBadRequest, invalid.Message)
+	}
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 400}, err
+		log.Println("Failed to validate notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// New notices get a fresh ID
+	if notice.ID == 0 {
+		notice.ID, err = util.NewNoticeId()
+		if err != nil {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		}
 	}
 
-	// Convert the item to a map[string]*dynamodb.AttributeValue
-	av, err := dynamodbattribute.MarshalMap(item)
+	// Keep the original creation time when a notice is replaced
+	existing, err := util.Notices.GetNotice(ctx, notice.ID)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 500}, err
+		log.Println("Failed to get notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	claims, _ := util.SessionFromContext(ctx)
+	if existing != nil && !util.InCongregation(claims, existing) {
+		// The ID belongs to another congregation, which must not learn of this request
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found")
+	}
+	if existing != nil && existing.IsDeleted() {
+		return util.ErrorResponse(http.StatusConflict, "Notice is in the trash, restore it first")
+	}
+	if existing != nil && !util.CanEditNotice(claims, existing) {
+		return util.ErrorResponse(http.StatusForbidden, "Notice is under review or published")
+	}
+
+	// Edits must be based on the latest version of the notice
+	expectedVersion := 0
+	if existing != nil {
+		expectedVersion = existing.Version
+		if notice.Version != existing.Version {
+			return conflictResponse(existing)
+		}
 	}
 
-	// Create an input for the PutItem operation
-	input := &dynamodb.PutItemInput{
-		TableName: aws.String(os.Getenv("AWS_DYNAMO_TABLE_NAME")),
-		Item:      av,
+	// The review status, deletion, attachments and position only change through their own
+	// endpoints, and notices stay in the congregation they were created in
+	now := time.Now().UTC()
+	notice.CreatedAt = now
+	notice.Status = util.StatusDraft
+	notice.ReviewComments = nil
+	notice.DeletedAt = nil
+	notice.DeletedBy = ""
+	notice.Attachments = nil
+	notice.Position = 0
+	notice.CongregationId = ""
+	if claims != nil {
+		notice.AuthorId = claims.Subject
+		notice.CongregationId = claims.CongregationId
+	}
+	if existing != nil {
+		notice.CreatedAt = existing.CreatedAt
+		notice.Status = existing.Status
+		notice.ReviewComments = existing.ReviewComments
+		notice.Author = existing.Author
+		notice.AuthorId = existing.AuthorId
+		notice.CongregationId = existing.CongregationId
+		notice.Position = existing.Position
+		notice.Attachments = existing.Attachments
+	}
+	notice.UpdatedAt = now
+	notice.ApplySchedule(now)
+	notice.RenderContent()
+
+	if claims != nil && notice.Author == "" {
+		notice.Author = claims.Username
+	}
+
+	editedBy := ""
+	if claims != nil {
+		editedBy = claims.Username
 	}
 
-	// Store the item in DynamoDB
-	_, err = db.PutItem(input)
+	// Store the notice in DynamoDB, unless someone else saved a newer version meanwhile
+	err = util.Notices.SaveNotice(ctx, &notice, expectedVersion, editedBy)
+	if errors.Is(err, util.ErrConflict) {
+		latest, err := util.Notices.GetNotice(ctx, notice.ID)
+		if err != nil || latest == nil {
+			return util.ErrorResponse(http.StatusConflict, "Notice was changed by someone else, please reload")
+		}
+		return conflictResponse(latest)
+	}
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 500}, err
+		log.Println("Failed to store notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// Changes to existing notices are announced; new ones only once they are published
+	if existing != nil {
+		err = util.Events.Publish(ctx, util.NewNoticeEvent(util.EventNoticeUpdated, &notice))
+		if err != nil {
+			log.Printf("Failed to publish event for notice %d: %v", notice.ID, err)
+		}
 	}
 
-	// Return a success response
-	response := fmt.Sprintf("Item stored successfully: %+v", item)
-	return events.APIGatewayProxyResponse{
-		StatusCode: 200,
-		Body:       response,
-	}, nil
+	// Return the stored notice
+	return util.JSONResponse(http.StatusOK, notice)
+}
+
+// conflictResponse tells the client its edit was based on an outdated version
+func conflictResponse(latest *util.Notice) (events.APIGatewayProxyResponse, error) {
+	return util.ErrorResponse(http.StatusConflict, fmt.Sprintf("Notice was changed by someone else, the latest version is %d", latest.Version))
 }
 
 func main() {
-	lambda.Start(Handler)
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeWrite, Handler))
 }
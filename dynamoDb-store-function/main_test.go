@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"os"
 	"testing"
 	"time"
 
@@ -12,12 +14,23 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestMain(m *testing.M) {
+	// Fall back to in-memory stores when no DynamoDB table is configured
+	if os.Getenv("AWS_DYNAMO_TABLE_NAME") == "" {
+		util.Notices = util.NewMemoryNoticeStore()
+		util.Categories = util.NewMemoryCategoryStore()
+		util.Congregations = util.NewMemoryCongregationStore()
+	}
+
+	os.Exit(m.Run())
+}
+
 func TestHandler(t *testing.T) {
 	// Test setup
 	id := setup(t)
 
 	// Prepare a sample APIGatewayProxyRequest for testing
-	requestBody := fmt.Sprintf(`{"Id": %d, "name": "Test Item"}`, id)
+	requestBody := fmt.Sprintf(`{"Id": %d, "title": "Test Item", "content": "Test Content", "category": "cleaning", "tags": ["Rota", " rota", "hall"]}`, id)
 	request := events.APIGatewayProxyRequest{
 		Body: requestBody,
 	}
@@ -28,27 +41,206 @@ func TestHandler(t *testing.T) {
 	assert.Equal(t, 200, response.StatusCode)
 
 	// Assert the expected response body
-	assert.Equal(t, fmt.Sprintf("Item stored successfully: map[Id:%d name:Test Item]", id), response.Body)
+	var stored util.Notice
+	err = json.Unmarshal([]byte(response.Body), &stored)
+	assert.NoError(t, err)
+	assert.Equal(t, id, stored.ID)
+	assert.Equal(t, "Test Item", stored.Title)
+	assert.Equal(t, "cleaning", stored.Category)
+	assert.Equal(t, []string{"hall", "rota"}, stored.Tags)
+
+	// The notice can be read back
+	notice, err := util.Notices.GetNotice(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Item", notice.Title)
+	assert.Equal(t, []string{"hall", "rota"}, notice.Tags)
 
 	// Test teardown
 	teardown(t, id)
 }
 
+func TestHandler_UnknownCategory(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		Body: `{"title": "Test Item", "category": "gardening"}`,
+	}
+
+	response, err := Handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, response.StatusCode)
+	assert.Equal(t, "Unknown category: gardening", response.Body)
+}
+
 func setup(t *testing.T) int {
 	seed := time.Now().UnixNano()
 	r := rand.New(rand.NewSource(seed))
-	id := r.Intn(1000)
+	id := r.Intn(1000) + 1
 	return id
 }
 
 func teardown(t *testing.T, id int) {
 	// Delete the testing entry
-	err := util.DeleteItem(id)
+	err := util.Notices.DeleteNotice(context.Background(), id)
 	assert.NoError(t, err)
 
 	// Verify the deletion
-	item, err := util.GetItem(id)
+	item, err := util.Notices.GetNotice(context.Background(), id)
 	assert.NoError(t, err)
 	assert.Nil(t, item)
 }
 
+func TestHandler_InvalidSchedule(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		Body: `{"title": "Test Item", "publish_at": "2024-05-02T00:00:00Z", "expire_at": "2024-05-01T00:00:00Z"}`,
+	}
+
+	response, err := Handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, response.StatusCode)
+
+	request.Body = `{"title": "Test Item", "priority": "critical"}`
+	response, err = Handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, response.StatusCode)
+	assert.Equal(t, "priority must be normal, important or urgent", response.Body)
+}
+
+func TestHandler_Workflow(t *testing.T) {
+	member := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "member-sub", Username: "member"})
+
+	// New notices start as drafts owned by their author, whatever the payload says
+	request := events.APIGatewayProxyRequest{
+		Body: `{"Id": 4001, "title": "Hall cleaning", "status": "published"}`,
+	}
+	response, err := Handler(member, request)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	defer teardown(t, 4001)
+
+	notice, err := util.Notices.GetNotice(context.Background(), 4001)
+	assert.NoError(t, err)
+	assert.Equal(t, util.StatusDraft, notice.Status)
+	assert.Equal(t, "member-sub", notice.AuthorId)
+	assert.Equal(t, "member", notice.Author)
+
+	// Once submitted, only editors may change it
+	err = util.Notices.UpdateStatus(context.Background(), 4001, util.StatusDraft, util.StatusSubmitted, util.ReviewComment{Action: "submit"})
+	assert.NoError(t, err)
+	response, err = Handler(member, request)
+	assert.NoError(t, err)
+	assert.Equal(t, 403, response.StatusCode)
+
+	// Submitting changed the version, so saves of the draft as read before are refused
+	coordinator := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "coordinator", Username: "coordinator", Groups: []string{util.GroupCoordinators}})
+	request.Body = `{"Id": 4001, "version": 1, "title": "Hall cleaning"}`
+	response, err = Handler(coordinator, request)
+	assert.NoError(t, err)
+	assert.Equal(t, 409, response.StatusCode)
+
+	// The author stays the same when someone else edits the notice
+	request.Body = `{"Id": 4001, "version": 2, "title": "Hall cleaning", "author": "coordinator"}`
+	response, err = Handler(coordinator, request)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Contains(t, response.Body, `"status":"submitted"`)
+	assert.Contains(t, response.Body, `"author":"member"`)
+}
+
+func TestHandler_Conflict(t *testing.T) {
+	ctx := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "coordinator", Username: "coordinator", Groups: []string{util.GroupCoordinators}})
+	defer teardown(t, 4002)
+
+	// The first save creates version 1
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{Body: `{"Id": 4002, "title": "First"}`})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Contains(t, response.Body, `"version":1`)
+
+	// Two coordinators edit version 1; the second save is rejected
+	response, err = Handler(ctx, events.APIGatewayProxyRequest{Body: `{"Id": 4002, "version": 1, "title": "Second"}`})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Contains(t, response.Body, `"version":2`)
+
+	response, err = Handler(ctx, events.APIGatewayProxyRequest{Body: `{"Id": 4002, "version": 1, "title": "Third"}`})
+	assert.NoError(t, err)
+	assert.Equal(t, 409, response.StatusCode)
+
+	// Both saved versions are kept
+	revisions, err := util.Notices.ListRevisions(context.Background(), 4002)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, "Second", revisions[0].Notice.Title)
+	assert.Equal(t, "coordinator", revisions[0].EditedBy)
+}
+
+func TestHandler_Markdown(t *testing.T) {
+	defer teardown(t, 4003)
+
+	// The HTML is rendered from the Markdown, not taken from the payload
+	request := events.APIGatewayProxyRequest{
+		Body: `{"Id": 4003, "title": "Hall cleaning", "content": "**Group 2** <script>alert(1)</script>", "content_html": "<script></script>"}`,
+	}
+	response, err := Handler(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	notice, err := util.Notices.GetNotice(context.Background(), 4003)
+	assert.NoError(t, err)
+	assert.Equal(t, "**Group 2** <script>alert(1)</script>", notice.Content)
+	assert.Contains(t, notice.ContentHTML, "<strong>Group 2</strong>")
+	assert.NotContains(t, notice.ContentHTML, "<script")
+}
+
+func TestHandler_Congregation(t *testing.T) {
+	north := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "coordinator", CongregationId: "north", Groups: []string{util.GroupCoordinators}})
+	south := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "coordinator", CongregationId: "south", Groups: []string{util.GroupCoordinators}})
+	defer teardown(t, 4004)
+
+	// New notices belong to the author's congregation, whatever the payload says
+	response, err := Handler(north, events.APIGatewayProxyRequest{Body: `{"Id": 4004, "title": "North notice", "congregation_id": "south"}`})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	notice, err := util.Notices.GetNotice(context.Background(), 4004)
+	assert.NoError(t, err)
+	assert.Equal(t, "north", notice.CongregationId)
+
+	// Another congregation cannot overwrite it
+	response, err = Handler(south, events.APIGatewayProxyRequest{Body: `{"Id": 4004, "version": 1, "title": "Taken over"}`})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+
+	notice, err = util.Notices.GetNotice(context.Background(), 4004)
+	assert.NoError(t, err)
+	assert.Equal(t, "North notice", notice.Title)
+}
+
+func TestHandler_Event(t *testing.T) {
+	err := util.Congregations.CreateCongregation(context.Background(), &util.Congregation{Id: "west", Name: "West", TimeZone: "Europe/Amsterdam"})
+	assert.NoError(t, err)
+	west := util.ContextWithSession(context.Background(), &util.SessionClaims{Subject: "coordinator", CongregationId: "west", Groups: []string{util.GroupCoordinators}})
+	defer teardown(t, 4005)
+
+	// Events without a time zone take the congregation's
+	response, err := Handler(west, events.APIGatewayProxyRequest{Body: `{"Id": 4005, "title": "Midweek meeting", "type": "event",
+		"event": {"start": "2024-05-02T17:30:00Z", "end": "2024-05-02T19:15:00Z", "rrule": "FREQ=WEEKLY;BYDAY=TH", "exdates": ["2024-05-16"]}}`})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	notice, err := util.Notices.GetNotice(context.Background(), 4005)
+	assert.NoError(t, err)
+	assert.True(t, notice.IsEvent())
+	assert.Equal(t, "Europe/Amsterdam", notice.Event.TimeZone)
+
+	for _, body := range []string{
+		`{"title": "Meeting", "type": "event"}`,
+		`{"title": "Meeting", "type": "event", "event": {"start": "2024-05-02T17:30:00Z", "rrule": "FREQ=YEARLY"}}`,
+		`{"title": "Meeting", "type": "event", "event": {"start": "2024-05-02T17:30:00Z", "end": "2024-05-02T16:00:00Z"}}`,
+		`{"title": "Meeting", "type": "event", "event": {"start": "2024-05-02T17:30:00Z", "exdates": ["16 May"]}}`,
+		`{"title": "Meeting", "event": {"start": "2024-05-02T17:30:00Z"}}`,
+	} {
+		response, err = Handler(west, events.APIGatewayProxyRequest{Body: body})
+		assert.NoError(t, err)
+		assert.Equal(t, 400, response.StatusCode, body)
+	}
+}
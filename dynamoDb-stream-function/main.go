@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// subscribers react to the changes, in this order. Features that follow notice changes,
+// such as indexes and caches, add their subscriber here.
+var subscribers = []util.NoticeChangeSubscriber{
+	util.LogNoticeChanges{},
+	util.DomainEventSubscriber{},
+	util.SearchIndexSubscriber{},
+	// Last, as members are notified at most once
+	util.NotificationSubscriber{},
+}
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler consumes the notices table's DynamoDB stream, which must include new and old
+// images. Each change is classified as created, updated, published, deleted or restored
+// and handed to the subscribers. A record that fails is reported as a batch item failure,
+// so Lambda retries from there rather than the whole batch.
+func Handler(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	return util.ProcessNoticeStream(ctx, event, subscribers), nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
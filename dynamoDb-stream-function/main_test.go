@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// record returns a stream record changing a notice from one image to the other
+func record(sequence, name string, before, after map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventID:   "event-" + sequence,
+		EventName: name,
+		Change: events.DynamoDBStreamRecord{
+			SequenceNumber: sequence,
+			OldImage:       before,
+			NewImage:       after,
+		},
+	}
+}
+
+// image returns the stream image of a notice
+func image(id, status string, deleted bool) map[string]events.DynamoDBAttributeValue {
+	item := map[string]events.DynamoDBAttributeValue{
+		"Id":         events.NewNumberAttribute(id),
+		"title":      events.NewStringAttribute("Notice " + id),
+		"status":     events.NewStringAttribute(status),
+		"tags":       events.NewStringSetAttribute([]string{"hall"}),
+		"deleted_at": events.NewNullAttribute(),
+	}
+	if deleted {
+		item["deleted_at"] = events.NewStringAttribute("2024-05-01T10:00:00Z")
+	}
+	return item
+}
+
+func TestHandler(t *testing.T) {
+	var changes []*util.NoticeChange
+	subscribers = []util.NoticeChangeSubscriber{util.NoticeChangeFunc(func(ctx context.Context, change *util.NoticeChange) error {
+		changes = append(changes, change)
+		return nil
+	})}
+
+	response, err := Handler(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		record("1", "INSERT", nil, image("1", util.StatusDraft, false)),
+		record("2", "MODIFY", image("1", util.StatusDraft, false), image("1", util.StatusSubmitted, false)),
+		record("3", "MODIFY", image("1", util.StatusApproved, false), image("1", util.StatusPublished, false)),
+		record("4", "MODIFY", image("1", util.StatusPublished, false), image("1", util.StatusPublished, true)),
+		record("5", "MODIFY", image("1", util.StatusPublished, true), image("1", util.StatusPublished, false)),
+		record("6", "REMOVE", image("1", util.StatusPublished, true), nil),
+		record("7", "INSERT", nil, image("2", util.StatusPublished, false)),
+	}})
+	assert.NoError(t, err)
+	assert.Empty(t, response.BatchItemFailures)
+
+	var types []string
+	for _, change := range changes {
+		types = append(types, change.Type)
+	}
+	assert.Equal(t, []string{"created", "updated", "published", "deleted", "restored", "deleted", "published"}, types)
+
+	// The images are decoded into notices
+	assert.Nil(t, changes[0].Old)
+	assert.Equal(t, 1, changes[0].New.ID)
+	assert.Equal(t, "Notice 1", changes[0].New.Title)
+	assert.Equal(t, []string{"hall"}, changes[0].New.Tags)
+	assert.Nil(t, changes[5].New)
+	assert.Equal(t, 1, changes[5].NoticeId)
+	assert.Equal(t, "event-7", changes[6].EventId)
+}
+
+func TestHandler_Failure(t *testing.T) {
+	var seen []int
+	subscribers = []util.NoticeChangeSubscriber{util.NoticeChangeFunc(func(ctx context.Context, change *util.NoticeChange) error {
+		seen = append(seen, change.NoticeId)
+		if change.NoticeId == 2 {
+			return errors.New("index unavailable")
+		}
+		return nil
+	})}
+
+	// Processing stops at the failed record, which Lambda retries the batch from
+	response, err := Handler(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		record("1", "INSERT", nil, image("1", util.StatusDraft, false)),
+		record("2", "INSERT", nil, image("2", util.StatusDraft, false)),
+		record("3", "INSERT", nil, image("3", util.StatusDraft, false)),
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []events.DynamoDBBatchItemFailure{{ItemIdentifier: "2"}}, response.BatchItemFailures)
+	assert.Equal(t, []int{1, 2}, seen)
+
+	// as do records that are not notices
+	response, err = Handler(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		record("4", "INSERT", nil, map[string]events.DynamoDBAttributeValue{"Id": events.NewStringAttribute("not a number")}),
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []events.DynamoDBBatchItemFailure{{ItemIdentifier: "4"}}, response.BatchItemFailures)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// variantWidths are the widths images are resized to; the smallest serves as thumbnail
+var variantWidths = []int{160, 640, 1280}
+
+// jpegQuality balances size and sharpness for photos shown on phones and displays
+const jpegQuality = 80
+
+// maxPixels is the largest image, in width times height, that is decoded. Decoding takes
+// four bytes a pixel or more, so a small but highly compressed file could otherwise use
+// up the function's memory.
+const maxPixels = 50_000_000
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler runs when a file is uploaded to the attachment bucket and stores
+// resized variants of image attachments next to the original
+func Handler(ctx context.Context, event events.S3Event) error {
+	for _, record := range event.Records {
+		// Keys in S3 events are URL-encoded
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			log.Printf("Skipping invalid key %q: %v", record.S3.Object.Key, err)
+			continue
+		}
+
+		err = processUpload(ctx, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func processUpload(ctx context.Context, key string) error {
+	noticeId, attachmentId, ok := util.AttachmentFromKey(key)
+	if !ok {
+		log.Printf("Skipping %s: not an attachment", key)
+		return nil
+	}
+
+	// Find the attachment the file belongs to
+	notice, err := util.Notices.GetNotice(ctx, noticeId)
+	if err != nil {
+		return err
+	}
+	if notice == nil || notice.Attachment(attachmentId) == nil {
+		log.Printf("Skipping %s: attachment no longer exists", key)
+		return nil
+	}
+	attachment := *notice.Attachment(attachmentId)
+	if !attachment.IsImage() || attachment.Key != key {
+		return nil
+	}
+
+	// Decode the uploaded image
+	body, err := util.Objects.GetObject(ctx, key)
+	if err != nil {
+		return err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Skipping %s: cannot decode image: %v", key, err)
+		return nil
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > maxPixels/config.Height {
+		log.Printf("Skipping %s: image of %dx%d pixels is too large", key, config.Width, config.Height)
+		return nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Skipping %s: cannot decode image: %v", key, err)
+		return nil
+	}
+
+	// Store the variants and record them on the attachment
+	variants, err := storeVariants(ctx, noticeId, attachmentId, img)
+	if err != nil {
+		return err
+	}
+	bounds := img.Bounds()
+	attachment.Width = bounds.Dx()
+	attachment.Height = bounds.Dy()
+	attachment.Variants = variants
+
+	err = util.Notices.UpdateAttachment(ctx, noticeId, attachment)
+	if errors.Is(err, util.ErrNotFound) || errors.Is(err, util.ErrConflict) {
+		// The attachment was removed meanwhile, so its variants are not needed
+		log.Printf("Attachment %s of notice %d changed while resizing, discarding variants", attachmentId, noticeId)
+		return util.Objects.DeleteObjects(ctx, variantKeys(variants))
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Stored %d variants of %s", len(variants), key)
+	return nil
+}
+
+// storeVariants resizes the image to each variant width, never scaling it up
+func storeVariants(ctx context.Context, noticeId int, attachmentId string, img image.Image) ([]util.ImageVariant, error) {
+	bounds := img.Bounds()
+	opaque := isOpaque(img)
+
+	var variants []util.ImageVariant
+	for _, width := range variantWidths {
+		if width > bounds.Dx() {
+			// Keep a single variant at the original size for small images
+			if len(variants) > 0 {
+				break
+			}
+			width = bounds.Dx()
+		}
+		height := bounds.Dy() * width / bounds.Dx()
+		if height < 1 {
+			height = 1
+		}
+
+		resized := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Over, nil)
+
+		// Photos become JPEG; images with transparency stay PNG
+		var buf bytes.Buffer
+		contentType, extension := "image/jpeg", "jpg"
+		var err error
+		if opaque {
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			contentType, extension = "image/png", "png"
+			err = png.Encode(&buf, resized)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode variant: %w", err)
+		}
+
+		variant := util.ImageVariant{
+			Key:         fmt.Sprintf("variants/notices/%d/%s/w%d.%s", noticeId, attachmentId, width, extension),
+			Width:       width,
+			Height:      height,
+			ContentType: contentType,
+			Size:        int64(buf.Len()),
+		}
+		err = util.Objects.PutObject(ctx, variant.Key, contentType, buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+
+		if width == bounds.Dx() {
+			break
+		}
+	}
+	return variants, nil
+}
+
+// isOpaque reports whether the image has no transparent pixels
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func variantKeys(variants []util.ImageVariant) []string {
+	var keys []string
+	for _, variant := range variants {
+		keys = append(keys, variant.Key)
+	}
+	return keys
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+var objects *util.MemoryObjectStore
+
+func TestMain(m *testing.M) {
+	// Keep notices and files in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+	objects = util.NewMemoryObjectStore()
+	util.Objects = objects
+
+	os.Exit(m.Run())
+}
+
+// upload stores an image attachment on a new notice, as a client upload would
+func upload(t *testing.T, noticeId int, key, contentType string, body []byte) events.S3Event {
+	notice := &util.Notice{
+		ID:          noticeId,
+		Title:       "Assembly hall",
+		Attachments: []util.Attachment{{Id: "a1", Key: key, ContentType: contentType, Size: int64(len(body))}},
+	}
+	err := util.Notices.PutNotice(context.Background(), notice)
+	assert.NoError(t, err)
+	objects.Put(key, body)
+
+	event := events.S3Event{Records: []events.S3EventRecord{{}}}
+	event.Records[0].S3.Object.Key = key
+	return event
+}
+
+func TestHandler(t *testing.T) {
+	// A 1000x500 photo
+	img := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for y := 0; y < 500; y++ {
+		for x := 0; x < 1000; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, nil)
+	assert.NoError(t, err)
+
+	event := upload(t, 1, "notices/1/a1/hall+photo.jpg", "image/jpeg", buf.Bytes())
+	event.Records[0].S3.Object.Key = "notices/1/a1/hall%2Bphoto.jpg"
+	err = Handler(context.Background(), event)
+	assert.NoError(t, err)
+
+	// Variants are recorded on the attachment, without scaling up
+	notice, err := util.Notices.GetNotice(context.Background(), 1)
+	assert.NoError(t, err)
+	attachment := notice.Attachments[0]
+	assert.Equal(t, 1000, attachment.Width)
+	assert.Equal(t, 500, attachment.Height)
+	assert.Equal(t, []util.ImageVariant{
+		{Key: "variants/notices/1/a1/w160.jpg", Width: 160, Height: 80, ContentType: "image/jpeg", Size: attachment.Variants[0].Size},
+		{Key: "variants/notices/1/a1/w640.jpg", Width: 640, Height: 320, ContentType: "image/jpeg", Size: attachment.Variants[1].Size},
+	}, attachment.Variants)
+
+	// And the files exist
+	body, err := objects.GetObject(context.Background(), "variants/notices/1/a1/w160.jpg")
+	assert.NoError(t, err)
+	thumbnail, err := jpeg.Decode(bytes.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, 160, thumbnail.Bounds().Dx())
+}
+
+func TestHandler_Transparent(t *testing.T) {
+	// A small icon with transparency stays PNG at its own size
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 40))
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	assert.NoError(t, err)
+
+	event := upload(t, 2, "notices/2/a1/icon.png", "image/png", buf.Bytes())
+	err = Handler(context.Background(), event)
+	assert.NoError(t, err)
+
+	notice, err := util.Notices.GetNotice(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Len(t, notice.Attachments[0].Variants, 1)
+	assert.Equal(t, "variants/notices/2/a1/w100.png", notice.Attachments[0].Variants[0].Key)
+}
+
+func TestHandler_NotAnImage(t *testing.T) {
+	event := upload(t, 3, "notices/3/a1/rota.pdf", "application/pdf", []byte("%PDF-1.7"))
+	err := Handler(context.Background(), event)
+	assert.NoError(t, err)
+
+	notice, err := util.Notices.GetNotice(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Empty(t, notice.Attachments[0].Variants)
+}
+
+func TestHandler_TooLarge(t *testing.T) {
+	// A PNG header claiming 100000x100000 pixels, which would need 40 GB to decode
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], 100000)
+	binary.BigEndian.PutUint32(header[4:], 100000)
+	header[8], header[9] = 8, 6 // 8-bit RGBA
+	chunk := append([]byte("IHDR"), header...)
+	body := []byte("\x89PNG\r\n\x1a\n")
+	body = binary.BigEndian.AppendUint32(body, uint32(len(header)))
+	body = append(body, chunk...)
+	body = binary.BigEndian.AppendUint32(body, crc32.ChecksumIEEE(chunk))
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	assert.NoError(t, err)
+	assert.Equal(t, 100000, config.Width)
+
+	// The image is skipped without being decoded
+	event := upload(t, 4, "notices/4/a1/bomb.png", "image/png", body)
+	err = Handler(context.Background(), event)
+	assert.NoError(t, err)
+
+	notice, err := util.Notices.GetNotice(context.Background(), 4)
+	assert.NoError(t, err)
+	assert.Empty(t, notice.Attachments[0].Variants)
+	assert.Zero(t, notice.Attachments[0].Width)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+// actionEvents maps each workflow action to the event emitted once it is applied
+var actionEvents = map[string]string{
+	"submit":  util.EventNoticeSubmitted,
+	"approve": util.EventNoticeApproved,
+	"reject":  util.EventNoticeRejected,
+	"revise":  util.EventNoticeRevised,
+	"publish": util.EventNoticePublished,
+	"archive": util.EventNoticeArchived,
+}
+
+// TransitionRequest is the optional body of a transition
+type TransitionRequest struct {
+	Id      int    `json:"Id"`
+	Action  string `json:"action"`
+	Comment string `json:"comment"`
+}
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler moves a notice through the review workflow, e.g. POST /notices/{id}/approve
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Read the notice ID from the path or the request body
+	id, err := util.NoticeIdFromRequest(request)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	// Parse the request body
+	var body TransitionRequest
+	if request.Body != "" {
+		err = json.Unmarshal([]byte(request.Body), &body)
+		if err != nil {
+			return util.ErrorResponse(http.StatusBadRequest, "Invalid request payload")
+		}
+	}
+	action := request.PathParameters["action"]
+	if action == "" {
+		action = body.Action
+	}
+
+	// Get the notice from DynamoDB
+	notice, err := util.Notices.GetNotice(ctx, id)
+	if err != nil {
+		log.Println("Failed to get notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	claims, _ := util.SessionFromContext(ctx)
+	if notice == nil || !util.NoticeVisibleTo(claims, notice, time.Now()) {
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found")
+	}
+
+	// Check the action applies and the caller may take it
+	from, to, err := util.PlanTransition(notice, action, body.Comment, claims)
+	switch {
+	case errors.Is(err, util.ErrUnknownAction), errors.Is(err, util.ErrCommentRequired):
+		return util.ErrorResponse(http.StatusBadRequest, err.Error())
+	case errors.Is(err, util.ErrNotApprover), errors.Is(err, util.ErrNotAuthor):
+		return util.ErrorResponse(http.StatusForbidden, err.Error())
+	case errors.Is(err, util.ErrTransitionNotAllowed):
+		return util.ErrorResponse(http.StatusConflict, fmt.Sprintf("Cannot %s a notice that is %s", action, notice.EffectiveStatus()))
+	}
+
+	comment := util.ReviewComment{
+		Author:    claims.Username,
+		Action:    action,
+		Comment:   body.Comment,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// Only apply the change if nobody else moved the notice in the meantime
+	err = util.Notices.UpdateStatus(ctx, id, from, to, comment)
+	if errors.Is(err, util.ErrConflict) {
+		return util.ErrorResponse(http.StatusConflict, "Notice was changed by someone else, please reload")
+	}
+	if err != nil {
+		log.Println("Failed to update notice status:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	notice.Status = to
+	notice.UpdatedAt = comment.CreatedAt
+	notice.ReviewComments = append(notice.ReviewComments, comment)
+
+	err = util.Events.Publish(ctx, util.NewNoticeEvent(actionEvents[action], notice))
+	if err != nil {
+		log.Printf("Failed to publish event for notice %d: %v", notice.ID, err)
+	}
+
+	// Return the updated notice
+	return util.JSONResponse(http.StatusOK, notice)
+}
+
+func main() {
+	// Only callers holding a valid session token may reach the handler
+	lambda.Start(util.RequireSession(util.ScopeWrite, Handler))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+var published *util.MemoryEventPublisher
+
+func TestMain(m *testing.M) {
+	// Keep notices and events in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+	published = &util.MemoryEventPublisher{}
+	util.Events = published
+
+	os.Exit(m.Run())
+}
+
+func sessionContext(subject string, groups ...string) context.Context {
+	return util.ContextWithSession(context.Background(), &util.SessionClaims{
+		Subject:  subject,
+		Username: subject,
+		Groups:   groups,
+		Scopes:   []string{util.ScopeRead, util.ScopeWrite},
+	})
+}
+
+func transition(t *testing.T, ctx context.Context, id int, action, body string) events.APIGatewayProxyResponse {
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod:     "POST",
+		PathParameters: map[string]string{"id": strconv.Itoa(id), "action": action},
+		Body:           body,
+	})
+	assert.NoError(t, err)
+	return response
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	author := sessionContext("author")
+	coordinator := sessionContext("coordinator", util.GroupCoordinators)
+
+	notice := &util.Notice{ID: 1, Title: "Hall cleaning", AuthorId: "author", Status: util.StatusDraft, CreatedAt: time.Now().UTC()}
+	err := util.Notices.PutNotice(ctx, notice)
+	assert.NoError(t, err)
+
+	// Drafts cannot be approved before they are submitted
+	response := transition(t, coordinator, 1, "approve", "")
+	assert.Equal(t, 409, response.StatusCode)
+
+	response = transition(t, author, 1, "submit", "")
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Contains(t, response.Body, `"status":"submitted"`)
+
+	// Authors cannot approve their own notices
+	response = transition(t, author, 1, "approve", "")
+	assert.Equal(t, 403, response.StatusCode)
+
+	// Rejecting needs a reason
+	response = transition(t, coordinator, 1, "reject", "")
+	assert.Equal(t, 400, response.StatusCode)
+	response = transition(t, coordinator, 1, "reject", `{"comment": "Please add the date"}`)
+	assert.Equal(t, 200, response.StatusCode)
+
+	// The author revises and resubmits, then it is approved and published
+	assert.Equal(t, 200, transition(t, author, 1, "revise", "").StatusCode)
+	assert.Equal(t, 200, transition(t, author, 1, "submit", "").StatusCode)
+	assert.Equal(t, 200, transition(t, coordinator, 1, "approve", `{"comment": "Thanks"}`).StatusCode)
+	assert.Equal(t, 200, transition(t, coordinator, 1, "publish", "").StatusCode)
+
+	stored, err := util.Notices.GetNotice(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, util.StatusPublished, stored.Status)
+	assert.Len(t, stored.ReviewComments, 6)
+	assert.Equal(t, "Please add the date", stored.ReviewComments[1].Comment)
+	assert.Equal(t, "coordinator", stored.ReviewComments[1].Author)
+
+	// An event was emitted for every step
+	var types []string
+	for _, event := range published.Published() {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{
+		util.EventNoticeSubmitted,
+		util.EventNoticeRejected,
+		util.EventNoticeRevised,
+		util.EventNoticeSubmitted,
+		util.EventNoticeApproved,
+		util.EventNoticePublished,
+	}, types)
+}
+
+func TestHandler_HiddenFromOthers(t *testing.T) {
+	notice := &util.Notice{ID: 2, Title: "Draft", AuthorId: "author", Status: util.StatusDraft}
+	err := util.Notices.PutNotice(context.Background(), notice)
+	assert.NoError(t, err)
+
+	// Other members cannot see, let alone submit, someone else's draft
+	response := transition(t, sessionContext("someone-else"), 2, "submit", "")
+	assert.Equal(t, 404, response.StatusCode)
+
+	response = transition(t, sessionContext("author"), 2, "publish", "")
+	assert.Equal(t, 409, response.StatusCode)
+
+	response = transition(t, sessionContext("author"), 2, "delete", "")
+	assert.Equal(t, 400, response.StatusCode)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		fmt.Println("Error loading .env file:", err)
+	}
+}
+
+// Handler lists the notices in the trash (GET) and restores one of them (POST)
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch request.HTTPMethod {
+	case http.MethodGet, "":
+		return listTrash(ctx)
+	case http.MethodPost:
+		return restoreNotice(ctx, request)
+	}
+	return util.ErrorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+func listTrash(ctx context.Context) (events.APIGatewayProxyResponse, error) {
+	claims, _ := util.SessionFromContext(ctx)
+	filter := util.NoticeFilter{Deleted: true}
+	if claims != nil {
+		filter.CongregationId = claims.CongregationId
+	}
+	deleted, err := util.Notices.ListNotices(ctx, filter)
+	if err != nil {
+		log.Println("Failed to list notices:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// Only the caller's own congregation's trash is shown
+	notices := []util.Notice{}
+	for i := range deleted {
+		if util.InCongregation(claims, &deleted[i]) {
+			notices = append(notices, deleted[i])
+		}
+	}
+
+	return util.JSONResponse(http.StatusOK, map[string]interface{}{
+		"notices":        notices,
+		"retention_days": int(util.TrashRetention().Hours() / 24),
+	})
+}
+
+func restoreNotice(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Read the notice ID from the path or the request body
+	id, err := util.NoticeIdFromRequest(request)
+	if err != nil {
+		return util.ErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	// Only notices of the caller's congregation can be restored
+	notice, err := util.Notices.GetNotice(ctx, id)
+	if err != nil {
+		log.Println("Failed to get notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+	claims, _ := util.SessionFromContext(ctx)
+	if notice == nil || !util.InCongregation(claims, notice) {
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found in the trash")
+	}
+
+	// Take the notice out of the trash
+	err = util.Notices.RestoreNotice(ctx, id)
+	if errors.Is(err, util.ErrNotFound) {
+		return util.ErrorResponse(http.StatusNotFound, "Notice not found in the trash")
+	}
+	if err != nil {
+		log.Println("Failed to restore notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	notice, err = util.Notices.GetNotice(ctx, id)
+	if err != nil {
+		log.Println("Failed to get notice:", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// Return the restored notice
+	return util.JSONResponse(http.StatusOK, notice)
+}
+
+func main() {
+	// The trash is managed by coordinators and admins
+	lambda.Start(util.RequireSession(util.ScopeWrite, util.RequireGroup(Handler, util.GroupCoordinators, util.GroupAdmins)))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	util "github.com/mildnl/congregation-noticeboard-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the notices in memory for the tests
+	util.Notices = util.NewMemoryNoticeStore()
+
+	os.Exit(m.Run())
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	for _, notice := range []*util.Notice{
+		{ID: 1, Title: "Kept"},
+		{ID: 2, Title: "Deleted"},
+	} {
+		err := util.Notices.PutNotice(ctx, notice)
+		assert.NoError(t, err)
+	}
+	err := util.Notices.TrashNotice(ctx, 2, "coordinator", time.Now().UTC())
+	assert.NoError(t, err)
+
+	// Only the deleted notice is in the trash
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	var trash struct {
+		Notices []util.Notice `json:"notices"`
+	}
+	err = json.Unmarshal([]byte(response.Body), &trash)
+	assert.NoError(t, err)
+	assert.Len(t, trash.Notices, 1)
+	assert.Equal(t, "Deleted", trash.Notices[0].Title)
+	assert.Equal(t, "coordinator", trash.Notices[0].DeletedBy)
+
+	// Restore it
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:     "POST",
+		PathParameters: map[string]string{"id": "2"},
+	}
+	response, err = Handler(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	notice, err := util.Notices.GetNotice(ctx, 2)
+	assert.NoError(t, err)
+	assert.Nil(t, notice.DeletedAt)
+	assert.Empty(t, notice.DeletedBy)
+
+	// It is no longer in the trash
+	response, err = Handler(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+}
+
+func TestHandler_Congregation(t *testing.T) {
+	ctx := context.Background()
+	err := util.Notices.PutNotice(ctx, &util.Notice{ID: 3, Title: "South notice", CongregationId: "south"})
+	assert.NoError(t, err)
+	err = util.Notices.TrashNotice(ctx, 3, "coordinator", time.Now().UTC())
+	assert.NoError(t, err)
+
+	// Coordinators of another congregation neither see nor restore it
+	north := util.ContextWithSession(ctx, &util.SessionClaims{Subject: "coordinator", CongregationId: "north", Groups: []string{util.GroupCoordinators}})
+	response, err := Handler(north, events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.NotContains(t, response.Body, "South notice")
+
+	response, err = Handler(north, events.APIGatewayProxyRequest{HTTPMethod: "POST", PathParameters: map[string]string{"id": "3"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 404, response.StatusCode)
+
+	south := util.ContextWithSession(ctx, &util.SessionClaims{Subject: "coordinator", CongregationId: "south", Groups: []string{util.GroupCoordinators}})
+	response, err = Handler(south, events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.NoError(t, err)
+	assert.Contains(t, response.Body, "South notice")
+}
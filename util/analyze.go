@@ -0,0 +1,290 @@
+package util
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Languages the search analyzer can stem
+const (
+	LanguageEnglish = "en"
+	LanguageDutch   = "nl"
+)
+
+// stemmers reduce a folded word to its stem, per language
+var stemmers = map[string]func(string) string{
+	LanguageEnglish: stemEnglish,
+	LanguageDutch:   stemDutch,
+}
+
+// stopwords are too common to search for. Both languages' words are dropped from every
+// text, so a query finds the same words whichever language it is written in.
+var stopwords = map[string]map[string]bool{
+	LanguageEnglish: wordSet("a an and are as at be but by can do for from has have if in into is it its no not of on or our so than that the then there these they this to was we were which who will with you your"),
+	LanguageDutch:   wordSet("aan al als bij dan dat de deze die dit door een en er geen het hij hun ik in is je jij kan maar meer met naar niet nog of om onze ook op over te tot u uit van voor was wat we wel wij worden wordt ze zij zijn zo"),
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+func isStopword(term string) bool {
+	return stopwords[LanguageEnglish][term] || stopwords[LanguageDutch][term]
+}
+
+// token is a word in a text, lower-cased and without diacritics, with its byte offsets
+// in the original text
+type token struct {
+	term       string
+	start, end int
+}
+
+// foldedRunes spell letters with diacritics without them
+var foldedRunes = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae",
+	'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĳ': "ij", 'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'œ': "oe",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'ÿ': "y", 'ß': "ss",
+}
+
+// tokenize splits the text into words at everything that is not a letter or digit.
+// Single letters are left out.
+func tokenize(text string) []token {
+	var tokens []token
+	var term strings.Builder
+	start := -1
+	flush := func(end int) {
+		if start >= 0 && (end-start > 1 || unicode.IsDigit(rune(text[start]))) {
+			tokens = append(tokens, token{term: term.String(), start: start, end: end})
+		}
+		term.Reset()
+		start = -1
+	}
+
+	for i, r := range text {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		r = unicode.ToLower(r)
+		if folded, ok := foldedRunes[r]; ok {
+			term.WriteString(folded)
+		} else {
+			term.WriteRune(r)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+// detectLanguage guesses the language of the words by their stopwords, English unless
+// more of them are Dutch
+func detectLanguage(tokens []token) string {
+	english, dutch := 0, 0
+	for _, token := range tokens {
+		if stopwords[LanguageEnglish][token.term] {
+			english++
+		}
+		if stopwords[LanguageDutch][token.term] {
+			dutch++
+		}
+	}
+	if dutch > english {
+		return LanguageDutch
+	}
+	return LanguageEnglish
+}
+
+// stemTerm stems a folded word in the language; numbers and other words that are not
+// plain ASCII letters are left as they are
+func stemTerm(term, language string) string {
+	for i := 0; i < len(term); i++ {
+		if term[i] < 'a' || term[i] > 'z' {
+			return term
+		}
+	}
+	if stem, ok := stemmers[language]; ok {
+		return stem(term)
+	}
+	return term
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiouy", c) >= 0
+}
+
+func hasVowel(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if isVowel(word[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// undouble drops the last letter of a word ending in a double consonant
+func undouble(word string, keep string) string {
+	n := len(word)
+	if n > 2 && word[n-1] == word[n-2] && !isVowel(word[n-1]) && strings.IndexByte(keep, word[n-1]) < 0 {
+		return word[:n-1]
+	}
+	return word
+}
+
+// stemEnglish is a light English stemmer: it removes plurals and the -ed and -ing
+// endings, and spells a final -y and -e the same way everywhere, so that "meetings" and
+// "meeting", "assemblies" and "assembly" or "planned" and "plans" share a stem
+func stemEnglish(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ies"):
+		word = word[:len(word)-3] + "i"
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+	case strings.HasSuffix(word, "s"):
+		word = word[:len(word)-1]
+	}
+
+	for _, suffix := range []string{"ing", "ed"} {
+		if stem := strings.TrimSuffix(word, suffix); stem != word {
+			if len(stem) >= 3 && hasVowel(stem) {
+				word = undouble(stem, "lsz")
+			}
+			break
+		}
+	}
+
+	n := len(word)
+	switch {
+	case n > 3 && word[n-1] == 'y' && !isVowel(word[n-2]):
+		word = word[:n-1] + "i"
+	case n > 4 && word[n-1] == 'e':
+		word = word[:n-1]
+	}
+	return word
+}
+
+// stemDutch is a light Dutch stemmer: it removes diminutives and the plural and
+// inflection endings -en, -e and -s, and spells long vowels the same way in closed and
+// open syllables, so that "vergaderingen" and "vergadering" or "zaken" and "zaak" share
+// a stem
+func stemDutch(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "heden"):
+		return word[:len(word)-5] + "heid"
+	case strings.HasSuffix(word, "tje") && len(word) > 5:
+		word = word[:len(word)-3]
+	case strings.HasSuffix(word, "je") && len(word) > 4:
+		word = word[:len(word)-2]
+	}
+
+	n := len(word)
+	switch {
+	case n > 4 && strings.HasSuffix(word, "en") && !isVowel(word[n-3]):
+		word = undouble(word[:n-2], "")
+	case n > 3 && word[n-1] == 'e' && !isVowel(word[n-2]):
+		word = undouble(word[:n-1], "")
+	case n > 3 && word[n-1] == 's' && !isVowel(word[n-2]) && word[n-2] != 'j' && word[n-2] != 's':
+		word = word[:n-1]
+	}
+
+	// "zaak" becomes "zak", like "zaken"
+	n = len(word)
+	if n > 3 && !isVowel(word[n-1]) && word[n-2] == word[n-3] && word[n-2] != 'i' && isVowel(word[n-2]) {
+		word = word[:n-2] + word[n-1:]
+	}
+	return word
+}
+
+// plainTextPolicy removes every tag
+var plainTextPolicy = bluemonday.StrictPolicy()
+
+// plainText returns the text of rendered HTML, with the blocks separated by spaces
+func plainText(rendered string) string {
+	text := plainTextPolicy.Sanitize(strings.ReplaceAll(rendered, "<", " <"))
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// highlight escapes the text for HTML and marks the words whose stems are among the terms
+func highlight(text, language string, terms map[string]bool) string {
+	var b strings.Builder
+	last := 0
+	for _, token := range tokenize(text) {
+		if !terms[stemTerm(token.term, language)] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:token.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[token.start:token.end]))
+		b.WriteString("</mark>")
+		last = token.end
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// snippetLength is roughly how many characters a search snippet shows
+const snippetLength = 160
+
+// snippet returns a highlighted extract of the text around the first word matching the
+// terms, or its beginning when none does. Ellipses mark the text left out.
+func snippet(text, language string, terms map[string]bool) string {
+	if utf8.RuneCountInString(text) <= snippetLength {
+		return highlight(text, language, terms)
+	}
+
+	tokens := tokenize(text)
+	first := 0
+	for i, token := range tokens {
+		if terms[stemTerm(token.term, language)] {
+			first = i
+			break
+		}
+	}
+
+	// Show a little of the text before the match, then fill up with what follows
+	start := 0
+	if len(tokens) > 0 {
+		start = tokens[first].start
+		for i := first - 1; i >= 0 && tokens[first].start-tokens[i].start <= snippetLength/4; i-- {
+			start = tokens[i].start
+		}
+	}
+	if first == 0 {
+		start = 0
+	}
+	end := len(text)
+	for _, token := range tokens {
+		if token.start > start && token.end-start > snippetLength {
+			end = token.start
+			break
+		}
+	}
+
+	extract := highlight(strings.TrimSpace(text[start:end]), language, terms)
+	if start > 0 {
+		extract = "…" + extract
+	}
+	if end < len(text) {
+		extract += "…"
+	}
+	return extract
+}
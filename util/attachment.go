@@ -0,0 +1,170 @@
+package util
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attachment is a file stored in S3 alongside a notice, such as a PDF rota or a map
+type Attachment struct {
+	Id          string    `json:"id"`
+	Key         string    `json:"key"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Width, Height and Variants are filled in once an image has been processed
+	Width    int            `json:"width,omitempty"`
+	Height   int            `json:"height,omitempty"`
+	Variants []ImageVariant `json:"variants,omitempty"`
+	// URL is a presigned download link, filled in when the notice is read
+	URL string `json:"url,omitempty" dynamodbav:"-"`
+}
+
+// ImageVariant is a resized copy of an image attachment, such as a thumbnail
+type ImageVariant struct {
+	Key         string `json:"key"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url,omitempty" dynamodbav:"-"`
+}
+
+// AttachmentContentTypes are the file types that may be attached to a notice
+var AttachmentContentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/gif":       true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+}
+
+// AttachmentURLTTL is how long presigned upload and download links stay valid
+const AttachmentURLTTL = 15 * time.Minute
+
+// MaxAttachmentSize returns the largest allowed attachment in bytes, read from
+// ATTACHMENT_MAX_BYTES and defaulting to 10 MiB
+func MaxAttachmentSize() int64 {
+	size, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_BYTES"), 10, 64)
+	if err != nil || size <= 0 {
+		size = 10 << 20
+	}
+	return size
+}
+
+// NewAttachment checks the file against the limits and creates its metadata,
+// including the S3 key it is uploaded to. Rejected files are reported as a *ValidationError.
+func NewAttachment(noticeId int, fileName, contentType string, size int64) (*Attachment, error) {
+	if fileName == "" {
+		return nil, &ValidationError{Message: "file_name is required"}
+	}
+	if !AttachmentContentTypes[contentType] {
+		return nil, &ValidationError{Message: fmt.Sprintf("Unsupported content type: %s", contentType)}
+	}
+	if size <= 0 || size > MaxAttachmentSize() {
+		return nil, &ValidationError{Message: fmt.Sprintf("size must be between 1 and %d bytes", MaxAttachmentSize())}
+	}
+
+	idBytes := make([]byte, 8)
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate attachment id: %w", err)
+	}
+	id := hex.EncodeToString(idBytes)
+
+	return &Attachment{
+		Id:          id,
+		Key:         fmt.Sprintf("notices/%d/%s/%s", noticeId, id, sanitizeFileName(fileName)),
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        size,
+		CreatedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// sanitizeFileName keeps file names safe to use in S3 keys and URLs
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '-'
+	}, name)
+}
+
+// Attachment returns the attachment with the given ID, or nil
+func (n *Notice) Attachment(id string) *Attachment {
+	for i := range n.Attachments {
+		if n.Attachments[i].Id == id {
+			return &n.Attachments[i]
+		}
+	}
+	return nil
+}
+
+// Keys returns the S3 keys of the attachment's file and its variants
+func (a *Attachment) Keys() []string {
+	keys := []string{a.Key}
+	for _, variant := range a.Variants {
+		keys = append(keys, variant.Key)
+	}
+	return keys
+}
+
+// AttachmentKeys returns the S3 keys of all files stored for the notice
+func (n *Notice) AttachmentKeys() []string {
+	var keys []string
+	for i := range n.Attachments {
+		keys = append(keys, n.Attachments[i].Keys()...)
+	}
+	return keys
+}
+
+// PresignAttachments fills in download links for the attachments of the notices
+func PresignAttachments(ctx context.Context, objects ObjectStore, notices []Notice) error {
+	for i := range notices {
+		for j := range notices[i].Attachments {
+			attachment := &notices[i].Attachments[j]
+			link, err := objects.PresignGet(ctx, attachment.Key, AttachmentURLTTL)
+			if err != nil {
+				return err
+			}
+			attachment.URL = link
+
+			for k := range attachment.Variants {
+				variant := &attachment.Variants[k]
+				variant.URL, err = objects.PresignGet(ctx, variant.Key, AttachmentURLTTL)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// IsImage reports whether the attachment is a picture that can be resized
+func (a *Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
+
+// AttachmentFromKey reads the notice and attachment IDs back from an S3 key
+func AttachmentFromKey(key string) (int, string, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[0] != "notices" {
+		return 0, "", false
+	}
+	noticeId, err := strconv.Atoi(parts[1])
+	if err != nil || noticeId <= 0 || parts[2] == "" {
+		return 0, "", false
+	}
+	return noticeId, parts[2], true
+}
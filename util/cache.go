@@ -0,0 +1,61 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ETag returns a strong entity tag for a response body
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified reports whether the client already holds the current response, going by
+// the request's If-None-Match header or, without one, its If-Modified-Since header
+func NotModified(request events.APIGatewayProxyRequest, etag string, lastModified time.Time) bool {
+	if match := HeaderValue(request, "If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := HeaderValue(request, "If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(since)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// CachedResponse returns the body with its ETag and Last-Modified headers, or an empty
+// 304 Not Modified response when the client already holds it
+func CachedResponse(request events.APIGatewayProxyRequest, contentType string, body []byte, lastModified time.Time) (events.APIGatewayProxyResponse, error) {
+	etag := ETag(body)
+	headers := map[string]string{
+		"ETag":          etag,
+		"Cache-Control": "max-age=300",
+	}
+	if !lastModified.IsZero() {
+		headers["Last-Modified"] = lastModified.UTC().Format(http.TimeFormat)
+	}
+
+	if NotModified(request, etag, lastModified) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNotModified, Headers: headers}, nil
+	}
+
+	headers["Content-Type"] = contentType
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       string(body),
+	}, nil
+}
@@ -0,0 +1,194 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Category is an allowed notice category
+type Category struct {
+	Name      string    `json:"name" dynamodbav:"Name"`
+	Label     string    `json:"label"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DefaultCategories are allowed until an admin configures the category list
+var DefaultCategories = []Category{
+	{Name: "announcements", Label: "Announcements"},
+	{Name: "cleaning", Label: "Cleaning"},
+	{Name: "field-service", Label: "Field service"},
+	{Name: "maintenance", Label: "Maintenance"},
+	{Name: "meetings", Label: "Meetings"},
+}
+
+var categoryNamePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidCategoryName reports whether name is a lower-case, hyphenated category slug
+func ValidCategoryName(name string) bool {
+	return len(name) <= 64 && categoryNamePattern.MatchString(name)
+}
+
+// CategoryStore persists the allowed category list
+type CategoryStore interface {
+	PutCategory(ctx context.Context, category *Category) error
+	DeleteCategory(ctx context.Context, name string) error
+	ListCategories(ctx context.Context) ([]Category, error)
+}
+
+// Categories is the store holding the allowed category list
+var Categories CategoryStore
+
+// AllowedCategories returns the configured categories, or DefaultCategories while none are configured
+func AllowedCategories(ctx context.Context, store CategoryStore) ([]Category, error) {
+	categories, err := store.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return DefaultCategories, nil
+	}
+	return categories, nil
+}
+
+// IsAllowedCategory reports whether name is in the allowed category list
+func IsAllowedCategory(ctx context.Context, store CategoryStore, name string) (bool, error) {
+	categories, err := AllowedCategories(ctx, store)
+	if err != nil {
+		return false, err
+	}
+	for _, category := range categories {
+		if category.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DynamoCategoryStore keeps categories in the AWS_DYNAMO_CATEGORY_TABLE_NAME table, keyed by Name
+type DynamoCategoryStore struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+// NewDynamoCategoryStore creates a category store for the configured table
+func NewDynamoCategoryStore() *DynamoCategoryStore {
+	return &DynamoCategoryStore{
+		db:    newDynamoClient(),
+		table: os.Getenv("AWS_DYNAMO_CATEGORY_TABLE_NAME"),
+	}
+}
+
+// PutCategory adds or relabels a category
+func (s *DynamoCategoryStore) PutCategory(ctx context.Context, category *Category) error {
+	av, err := dynamodbattribute.MarshalMap(category)
+	if err != nil {
+		return fmt.Errorf("failed to store category %s: %w", category.Name, err)
+	}
+
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store category %s: %w", category.Name, err)
+	}
+	return nil
+}
+
+// DeleteCategory removes a category from the allowed list
+func (s *DynamoCategoryStore) DeleteCategory(ctx context.Context, name string) error {
+	_, err := s.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name": {S: aws.String(name)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete category %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListCategories returns the configured categories sorted by name
+func (s *DynamoCategoryStore) ListCategories(ctx context.Context) ([]Category, error) {
+	var categories []Category
+	var pageErr error
+	err := s.db.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName: aws.String(s.table),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageCategories []Category
+		pageErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageCategories)
+		if pageErr != nil {
+			return false
+		}
+		categories = append(categories, pageCategories...)
+		return true
+	})
+	if err == nil {
+		err = pageErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+
+	sortCategories(categories)
+	return categories, nil
+}
+
+// MemoryCategoryStore is an in-memory CategoryStore for tests and local runs
+type MemoryCategoryStore struct {
+	mu         sync.Mutex
+	categories map[string]Category
+}
+
+// NewMemoryCategoryStore creates an empty in-memory category store
+func NewMemoryCategoryStore() *MemoryCategoryStore {
+	return &MemoryCategoryStore{categories: make(map[string]Category)}
+}
+
+// PutCategory adds or relabels a category
+func (s *MemoryCategoryStore) PutCategory(ctx context.Context, category *Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.categories[category.Name] = *category
+	return nil
+}
+
+// DeleteCategory removes a category from the allowed list
+func (s *MemoryCategoryStore) DeleteCategory(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.categories, name)
+	return nil
+}
+
+// ListCategories returns the configured categories sorted by name
+func (s *MemoryCategoryStore) ListCategories(ctx context.Context) ([]Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	categories := make([]Category, 0, len(s.categories))
+	for _, category := range s.categories {
+		categories = append(categories, category)
+	}
+	sortCategories(categories)
+	return categories, nil
+}
+
+func sortCategories(categories []Category) {
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+}
@@ -0,0 +1,250 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	// The Lambda runtime has no zoneinfo database, so embed one for congregation time zones
+	_ "time/tzdata"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Congregation is a tenant of the noticeboard. Notices, users and display devices
+// each belong to one congregation and never see those of another.
+type Congregation struct {
+	Id       string `json:"id" dynamodbav:"Id"`
+	Name     string `json:"name"`
+	TimeZone string `json:"time_zone,omitempty"`
+	// Languages are the BCP 47 tags of the languages its notices should be available in,
+	// the first being that of notices which do not say
+	Languages []string  `json:"languages,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var (
+	// ErrCongregationExists is returned when creating a congregation whose ID is taken
+	ErrCongregationExists = errors.New("congregation already exists")
+	// ErrCongregationNotFound is returned when updating a congregation that does not exist
+	ErrCongregationNotFound = errors.New("congregation not found")
+)
+
+// ValidCongregationId reports whether id is a lower-case, hyphenated slug like category names
+func ValidCongregationId(id string) bool {
+	return ValidCategoryName(id)
+}
+
+// ValidateCongregation checks the congregation before it is stored.
+// Problems the caller should report are returned as a *ValidationError.
+func ValidateCongregation(congregation *Congregation) error {
+	if !ValidCongregationId(congregation.Id) {
+		return &ValidationError{Message: "id must be lower-case letters, digits and hyphens"}
+	}
+	if congregation.Name == "" {
+		return &ValidationError{Message: "name is required"}
+	}
+	if _, err := time.LoadLocation(congregation.TimeZone); err != nil {
+		return &ValidationError{Message: fmt.Sprintf("Unknown time zone: %s", congregation.TimeZone)}
+	}
+	languages, err := validateLanguages("languages", congregation.Languages)
+	if err != nil {
+		return err
+	}
+	congregation.Languages = languages
+	return nil
+}
+
+// Location returns the congregation's time zone, or UTC when none is configured
+func (c *Congregation) Location() *time.Location {
+	location, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// CongregationStore persists congregations
+type CongregationStore interface {
+	// CreateCongregation stores a new congregation, returning ErrCongregationExists if the ID is taken
+	CreateCongregation(ctx context.Context, congregation *Congregation) error
+	// UpdateCongregation replaces an existing congregation, returning ErrCongregationNotFound if there is none
+	UpdateCongregation(ctx context.Context, congregation *Congregation) error
+	// GetCongregation returns nil without an error when the congregation does not exist
+	GetCongregation(ctx context.Context, id string) (*Congregation, error)
+	ListCongregations(ctx context.Context) ([]Congregation, error)
+}
+
+// Congregations is the store holding the configured congregations
+var Congregations CongregationStore
+
+// DynamoCongregationStore keeps congregations in the AWS_DYNAMO_CONGREGATION_TABLE_NAME table, keyed by Id
+type DynamoCongregationStore struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+// NewDynamoCongregationStore creates a congregation store for the configured table
+func NewDynamoCongregationStore() *DynamoCongregationStore {
+	return &DynamoCongregationStore{
+		db:    newDynamoClient(),
+		table: os.Getenv("AWS_DYNAMO_CONGREGATION_TABLE_NAME"),
+	}
+}
+
+// CreateCongregation stores a new congregation, refusing to overwrite an existing one
+func (s *DynamoCongregationStore) CreateCongregation(ctx context.Context, congregation *Congregation) error {
+	err := s.put(ctx, congregation, "attribute_not_exists(Id)")
+	if isConditionFailed(err) {
+		return ErrCongregationExists
+	}
+	return err
+}
+
+// UpdateCongregation replaces an existing congregation
+func (s *DynamoCongregationStore) UpdateCongregation(ctx context.Context, congregation *Congregation) error {
+	err := s.put(ctx, congregation, "attribute_exists(Id)")
+	if isConditionFailed(err) {
+		return ErrCongregationNotFound
+	}
+	return err
+}
+
+func (s *DynamoCongregationStore) put(ctx context.Context, congregation *Congregation, condition string) error {
+	av, err := dynamodbattribute.MarshalMap(congregation)
+	if err != nil {
+		return fmt.Errorf("failed to store congregation %s: %w", congregation.Id, err)
+	}
+
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                av,
+		ConditionExpression: aws.String(condition),
+	})
+	if err != nil && !isConditionFailed(err) {
+		return fmt.Errorf("failed to store congregation %s: %w", congregation.Id, err)
+	}
+	return err
+}
+
+// GetCongregation loads a congregation by ID
+func (s *DynamoCongregationStore) GetCongregation(ctx context.Context, id string) (*Congregation, error) {
+	result, err := s.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get congregation %s: %w", id, err)
+	}
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var congregation Congregation
+	err = dynamodbattribute.UnmarshalMap(result.Item, &congregation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read congregation %s: %w", id, err)
+	}
+	return &congregation, nil
+}
+
+// ListCongregations returns every congregation sorted by ID
+func (s *DynamoCongregationStore) ListCongregations(ctx context.Context) ([]Congregation, error) {
+	var congregations []Congregation
+	var pageErr error
+	err := s.db.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName: aws.String(s.table),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageCongregations []Congregation
+		pageErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageCongregations)
+		if pageErr != nil {
+			return false
+		}
+		congregations = append(congregations, pageCongregations...)
+		return true
+	})
+	if err == nil {
+		err = pageErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list congregations: %w", err)
+	}
+
+	sortCongregations(congregations)
+	return congregations, nil
+}
+
+// MemoryCongregationStore is an in-memory CongregationStore for tests and local runs
+type MemoryCongregationStore struct {
+	mu            sync.Mutex
+	congregations map[string]Congregation
+}
+
+// NewMemoryCongregationStore creates an empty in-memory congregation store
+func NewMemoryCongregationStore() *MemoryCongregationStore {
+	return &MemoryCongregationStore{congregations: make(map[string]Congregation)}
+}
+
+// CreateCongregation stores a new congregation, refusing to overwrite an existing one
+func (s *MemoryCongregationStore) CreateCongregation(ctx context.Context, congregation *Congregation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.congregations[congregation.Id]; ok {
+		return ErrCongregationExists
+	}
+	s.congregations[congregation.Id] = *congregation
+	return nil
+}
+
+// UpdateCongregation replaces an existing congregation
+func (s *MemoryCongregationStore) UpdateCongregation(ctx context.Context, congregation *Congregation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.congregations[congregation.Id]; !ok {
+		return ErrCongregationNotFound
+	}
+	s.congregations[congregation.Id] = *congregation
+	return nil
+}
+
+// GetCongregation loads a congregation by ID
+func (s *MemoryCongregationStore) GetCongregation(ctx context.Context, id string) (*Congregation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	congregation, ok := s.congregations[id]
+	if !ok {
+		return nil, nil
+	}
+	return &congregation, nil
+}
+
+// ListCongregations returns every congregation sorted by ID
+func (s *MemoryCongregationStore) ListCongregations(ctx context.Context) ([]Congregation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	congregations := make([]Congregation, 0, len(s.congregations))
+	for _, congregation := range s.congregations {
+		congregations = append(congregations, congregation)
+	}
+	sortCongregations(congregations)
+	return congregations, nil
+}
+
+func sortCongregations(congregations []Congregation) {
+	sort.Slice(congregations, func(i, j int) bool {
+		return congregations[i].Id < congregations[j].Id
+	})
+}
@@ -0,0 +1,346 @@
+package util
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// deviceKeyPrefix marks a string as a noticeboard device API key
+const deviceKeyPrefix = "nbk_"
+
+var (
+	// ErrInvalidDeviceKey is returned for unknown, malformed or non-matching device keys
+	ErrInvalidDeviceKey = errors.New("invalid device key")
+	// ErrRevokedDeviceKey is returned when a device key has been revoked
+	ErrRevokedDeviceKey = errors.New("device key revoked")
+	// ErrExpiredDeviceKey is returned when a device key is past its expiry time
+	ErrExpiredDeviceKey = errors.New("device key expired")
+)
+
+// DeviceKey is an API key issued to a noticeboard display device. Only a hash
+// of the secret part is stored; the plain key is shown once when it is minted.
+type DeviceKey struct {
+	KeyId          string     `json:"key_id" dynamodbav:"KeyId"`
+	Name           string     `json:"name"`
+	CongregationId string     `json:"congregation_id"`
+	Scopes         []string   `json:"scopes"`
+	SecretHash     string     `json:"-" dynamodbav:"secret_hash"`
+	CreatedBy      string     `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
+// DeviceKeyStore persists device keys
+type DeviceKeyStore interface {
+	PutDeviceKey(ctx context.Context, key *DeviceKey) error
+	// GetDeviceKey returns nil without an error when the key does not exist
+	GetDeviceKey(ctx context.Context, keyId string) (*DeviceKey, error)
+	ListDeviceKeys(ctx context.Context) ([]DeviceKey, error)
+	RevokeDeviceKey(ctx context.Context, keyId string, at time.Time) error
+	TouchDeviceKey(ctx context.Context, keyId string, at time.Time) error
+}
+
+// DeviceKeys is the store consulted by RequireSession for x-api-key requests
+var DeviceKeys DeviceKeyStore
+
+// NewDeviceKey mints a read-only key for a display device of the given congregation.
+// It returns the record to store and the plain key to hand to the device.
+func NewDeviceKey(name, congregationId, createdBy string, expiresAt *time.Time) (*DeviceKey, string, error) {
+	idBytes := make([]byte, 8)
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate device key id: %w", err)
+	}
+
+	secretBytes := make([]byte, 32)
+	_, err = rand.Read(secretBytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate device key secret: %w", err)
+	}
+
+	keyId := hex.EncodeToString(idBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	key := &DeviceKey{
+		KeyId:          keyId,
+		Name:           name,
+		CongregationId: congregationId,
+		Scopes:         []string{ScopeRead},
+		SecretHash:     hashDeviceKeySecret(secret),
+		CreatedBy:      createdBy,
+		CreatedAt:      time.Now().UTC(),
+		ExpiresAt:      expiresAt,
+	}
+
+	return key, deviceKeyPrefix + keyId + "." + secret, nil
+}
+
+// VerifyDeviceKey checks a plain device key against the store, records its use
+// and returns the session claims the device acts with
+func VerifyDeviceKey(ctx context.Context, store DeviceKeyStore, plainKey string) (*SessionClaims, error) {
+	keyId, secret, err := parseDeviceKey(plainKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := store.GetDeviceKey(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrInvalidDeviceKey
+	}
+
+	// Compare the hashes in constant time
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashDeviceKeySecret(secret))) != 1 {
+		return nil, ErrInvalidDeviceKey
+	}
+
+	now := time.Now().UTC()
+	if key.RevokedAt != nil {
+		return nil, ErrRevokedDeviceKey
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, ErrExpiredDeviceKey
+	}
+
+	// Track the last use so stale devices can be found and revoked
+	err = store.TouchDeviceKey(ctx, keyId, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionClaims{
+		Subject:        "device:" + key.KeyId,
+		Username:       key.Name,
+		CongregationId: key.CongregationId,
+		Scopes:         key.Scopes,
+		Device:         true,
+		IssuedAt:       key.CreatedAt.Unix(),
+	}, nil
+}
+
+func parseDeviceKey(plainKey string) (string, string, error) {
+	if !strings.HasPrefix(plainKey, deviceKeyPrefix) {
+		return "", "", ErrInvalidDeviceKey
+	}
+
+	keyId, secret, found := strings.Cut(strings.TrimPrefix(plainKey, deviceKeyPrefix), ".")
+	if !found || keyId == "" || secret == "" {
+		return "", "", ErrInvalidDeviceKey
+	}
+	return keyId, secret, nil
+}
+
+func hashDeviceKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// DynamoDeviceKeyStore keeps device keys in the AWS_DYNAMO_DEVICE_KEY_TABLE_NAME table, keyed by KeyId
+type DynamoDeviceKeyStore struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+// NewDynamoDeviceKeyStore creates a device key store for the configured table
+func NewDynamoDeviceKeyStore() *DynamoDeviceKeyStore {
+	return &DynamoDeviceKeyStore{
+		db:    newDynamoClient(),
+		table: os.Getenv("AWS_DYNAMO_DEVICE_KEY_TABLE_NAME"),
+	}
+}
+
+// PutDeviceKey stores the key, refusing to overwrite an existing key id
+func (s *DynamoDeviceKeyStore) PutDeviceKey(ctx context.Context, key *DeviceKey) error {
+	av, err := dynamodbattribute.MarshalMap(key)
+	if err != nil {
+		return fmt.Errorf("failed to store device key %s: %w", key.KeyId, err)
+	}
+
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(KeyId)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store device key %s: %w", key.KeyId, err)
+	}
+	return nil
+}
+
+// GetDeviceKey loads a key by id
+func (s *DynamoDeviceKeyStore) GetDeviceKey(ctx context.Context, keyId string) (*DeviceKey, error) {
+	result, err := s.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"KeyId": {S: aws.String(keyId)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device key %s: %w", keyId, err)
+	}
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var key DeviceKey
+	err = dynamodbattribute.UnmarshalMap(result.Item, &key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device key %s: %w", keyId, err)
+	}
+	return &key, nil
+}
+
+// ListDeviceKeys returns every key, newest first
+func (s *DynamoDeviceKeyStore) ListDeviceKeys(ctx context.Context) ([]DeviceKey, error) {
+	var keys []DeviceKey
+	var pageErr error
+	err := s.db.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName: aws.String(s.table),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageKeys []DeviceKey
+		pageErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageKeys)
+		if pageErr != nil {
+			return false
+		}
+		keys = append(keys, pageKeys...)
+		return true
+	})
+	if err == nil {
+		err = pageErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device keys: %w", err)
+	}
+
+	sortDeviceKeys(keys)
+	return keys, nil
+}
+
+// RevokeDeviceKey marks a key as revoked; revoked keys are kept for auditing
+func (s *DynamoDeviceKeyStore) RevokeDeviceKey(ctx context.Context, keyId string, at time.Time) error {
+	return s.setTimestamp(ctx, keyId, "revoked_at", at)
+}
+
+// TouchDeviceKey records the last time the key was used
+func (s *DynamoDeviceKeyStore) TouchDeviceKey(ctx context.Context, keyId string, at time.Time) error {
+	return s.setTimestamp(ctx, keyId, "last_used_at", at)
+}
+
+func (s *DynamoDeviceKeyStore) setTimestamp(ctx context.Context, keyId, attribute string, at time.Time) error {
+	value, err := dynamodbattribute.Marshal(at)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"KeyId": {S: aws.String(keyId)},
+		},
+		ConditionExpression:       aws.String("attribute_exists(KeyId)"),
+		UpdateExpression:          aws.String("SET #attr = :at"),
+		ExpressionAttributeNames:  map[string]*string{"#attr": aws.String(attribute)},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":at": value},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update device key %s: %w", keyId, err)
+	}
+	return nil
+}
+
+// MemoryDeviceKeyStore is an in-memory DeviceKeyStore for tests and local runs
+type MemoryDeviceKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]DeviceKey
+}
+
+// NewMemoryDeviceKeyStore creates an empty in-memory device key store
+func NewMemoryDeviceKeyStore() *MemoryDeviceKeyStore {
+	return &MemoryDeviceKeyStore{keys: make(map[string]DeviceKey)}
+}
+
+// PutDeviceKey stores the key, refusing to overwrite an existing key id
+func (s *MemoryDeviceKeyStore) PutDeviceKey(ctx context.Context, key *DeviceKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.keys[key.KeyId]; exists {
+		return fmt.Errorf("device key %s already exists", key.KeyId)
+	}
+	s.keys[key.KeyId] = *key
+	return nil
+}
+
+// GetDeviceKey loads a key by id
+func (s *MemoryDeviceKeyStore) GetDeviceKey(ctx context.Context, keyId string) (*DeviceKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[keyId]
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+// ListDeviceKeys returns every key, newest first
+func (s *MemoryDeviceKeyStore) ListDeviceKeys(ctx context.Context) ([]DeviceKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]DeviceKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sortDeviceKeys(keys)
+	return keys, nil
+}
+
+// RevokeDeviceKey marks a key as revoked
+func (s *MemoryDeviceKeyStore) RevokeDeviceKey(ctx context.Context, keyId string, at time.Time) error {
+	return s.update(keyId, func(key *DeviceKey) { key.RevokedAt = &at })
+}
+
+// TouchDeviceKey records the last time the key was used
+func (s *MemoryDeviceKeyStore) TouchDeviceKey(ctx context.Context, keyId string, at time.Time) error {
+	return s.update(keyId, func(key *DeviceKey) { key.LastUsedAt = &at })
+}
+
+func (s *MemoryDeviceKeyStore) update(keyId string, apply func(key *DeviceKey)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[keyId]
+	if !ok {
+		return fmt.Errorf("device key %s not found", keyId)
+	}
+	apply(&key)
+	s.keys[keyId] = key
+	return nil
+}
+
+func sortDeviceKeys(keys []DeviceKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+}
@@ -0,0 +1,297 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"sort"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	digestHTMLTemplate = htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/digest.html.tmpl"))
+	digestTextTemplate = texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/digest.txt.tmpl"))
+)
+
+// Digest is the summary email of one member
+type Digest struct {
+	Board     string
+	Frequency string
+	// Period identifies the digest; a member receives one digest per period
+	Period string
+	// From and To bound the coming day or week; new notices are those published in the
+	// day or week before From
+	From time.Time
+	To   time.Time
+	// Events are the occurrences of events in the coming period
+	Events []Occurrence
+	// NewNotices were published in the past period, newest first
+	NewNotices []Notice
+	// Expiring notices leave the board in the coming period, soonest first
+	Expiring []Notice
+	// NoticeURL and PreferencesURL link into the web app, when NOTICEBOARD_URL is set
+	NoticeURL      string
+	PreferencesURL string
+}
+
+// digestDays returns the number of days a digest looks ahead, and back for new notices
+func digestDays(frequency string) int {
+	if frequency == DigestDaily {
+		return 1
+	}
+	return 7
+}
+
+// DigestPeriod returns the period of a digest starting at from: the date of daily
+// digests, such as 2024-05-01, and the ISO week of weekly ones, such as 2024-W18
+func DigestPeriod(from time.Time, frequency string) string {
+	if frequency == DigestDaily {
+		return from.Format(DateLayout)
+	}
+	year, week := from.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// BuildDigest collects the notices every member sees that the member subscribed to, for
+// the day or week starting at the beginning of today in the location
+func BuildDigest(preferences *UserPreferences, notices []Notice, now time.Time, location *time.Location) (*Digest, error) {
+	frequency := preferences.DigestFrequency()
+	days := digestDays(frequency)
+	local := now.In(location)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	to := from.AddDate(0, 0, days)
+	since := from.AddDate(0, 0, -days)
+
+	digest := &Digest{
+		Board:     "Noticeboard",
+		Frequency: frequency,
+		Period:    DigestPeriod(from, frequency),
+		From:      from,
+		To:        to,
+	}
+	if base := noticeboardURL(); base != "" {
+		digest.NoticeURL = base + "/notices/"
+		digest.PreferencesURL = base + "/preferences"
+	}
+
+	for i := range notices {
+		notice := &notices[i]
+		if !preferences.Subscribed(notice) {
+			continue
+		}
+		if notice.IsEvent() {
+			occurrences, err := notice.Occurrences(from, to)
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand event %d: %w", notice.ID, err)
+			}
+			for _, occurrence := range occurrences {
+				occurrence.Start = occurrence.Start.In(location)
+				occurrence.End = occurrence.End.In(location)
+				digest.Events = append(digest.Events, occurrence)
+			}
+			continue
+		}
+		if published := notice.PublishedAt(); !published.Before(since) && published.Before(from) {
+			digest.NewNotices = append(digest.NewNotices, *notice)
+		}
+		if notice.ExpireAt != nil && !notice.ExpireAt.Before(from) && notice.ExpireAt.Before(to) {
+			expiring := *notice
+			expireAt := notice.ExpireAt.In(location)
+			expiring.ExpireAt = &expireAt
+			digest.Expiring = append(digest.Expiring, expiring)
+		}
+	}
+
+	SortOccurrences(digest.Events)
+	digest.NewNotices = SortForFeed(digest.NewNotices)
+	sort.SliceStable(digest.Expiring, func(i, j int) bool {
+		return digest.Expiring[i].ExpireAt.Before(*digest.Expiring[j].ExpireAt)
+	})
+	return digest, nil
+}
+
+// Title returns the heading of the digest
+func (d *Digest) Title() string {
+	if d.Frequency == DigestDaily {
+		return "Your day, " + d.From.Format("Monday 2 January")
+	}
+	return "Your week from " + d.From.Format("Monday 2 January")
+}
+
+// Empty reports whether the digest has nothing to tell
+func (d *Digest) Empty() bool {
+	return len(d.Events) == 0 && len(d.NewNotices) == 0 && len(d.Expiring) == 0
+}
+
+// NoticeIds returns the IDs of the notices in the digest
+func (d *Digest) NoticeIds() []int {
+	seen := make(map[int]bool)
+	var ids []int
+	add := func(id int) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, occurrence := range d.Events {
+		add(occurrence.NoticeId)
+	}
+	for _, notice := range d.NewNotices {
+		add(notice.ID)
+	}
+	for _, notice := range d.Expiring {
+		add(notice.ID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Email renders the digest as an email to the address
+func (d *Digest) Email(to string) (EmailMessage, error) {
+	subject := fmt.Sprintf("[%s] %s", d.Board, d.Title())
+
+	var html, text bytes.Buffer
+	if err := digestHTMLTemplate.Execute(&html, d); err != nil {
+		return EmailMessage{}, fmt.Errorf("failed to render digest: %w", err)
+	}
+	if err := digestTextTemplate.Execute(&text, d); err != nil {
+		return EmailMessage{}, fmt.Errorf("failed to render digest: %w", err)
+	}
+	return EmailMessage{To: to, Subject: subject, HTML: html.String(), Text: text.String()}, nil
+}
+
+// ErrDigestSent is returned when claiming a digest that was already sent
+var ErrDigestSent = errors.New("digest already sent")
+
+// DigestRecord remembers that a member was sent the digest of a period
+type DigestRecord struct {
+	UserId    string    `json:"user_id" dynamodbav:"UserId"`
+	Period    string    `json:"period" dynamodbav:"Period"`
+	Email     string    `json:"email"`
+	NoticeIds []int     `json:"notice_ids"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// DigestStore records the digests sent, so a rerun does not send them again
+type DigestStore interface {
+	// ClaimDigest records the digest before it is sent, returning ErrDigestSent if the
+	// member already has a record for the period
+	ClaimDigest(ctx context.Context, record *DigestRecord) error
+	// ReleaseDigest removes the record of a digest that could not be sent
+	ReleaseDigest(ctx context.Context, userId, period string) error
+}
+
+// Digests is the store recording the digests sent
+var Digests DigestStore
+
+// DynamoDigestStore keeps digest records in the AWS_DYNAMO_DIGEST_TABLE_NAME table,
+// keyed by UserId and Period
+type DynamoDigestStore struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+// NewDynamoDigestStore creates a digest store for the configured table
+func NewDynamoDigestStore() *DynamoDigestStore {
+	return &DynamoDigestStore{
+		db:    newDynamoClient(),
+		table: os.Getenv("AWS_DYNAMO_DIGEST_TABLE_NAME"),
+	}
+}
+
+// ClaimDigest records the digest, unless one was already recorded for the period
+func (s *DynamoDigestStore) ClaimDigest(ctx context.Context, record *DigestRecord) error {
+	av, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return fmt.Errorf("failed to record digest for %s: %w", record.UserId, err)
+	}
+
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(UserId)"),
+	})
+	if isConditionFailed(err) {
+		return ErrDigestSent
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record digest for %s: %w", record.UserId, err)
+	}
+	return nil
+}
+
+// ReleaseDigest removes the record of the period's digest
+func (s *DynamoDigestStore) ReleaseDigest(ctx context.Context, userId, period string) error {
+	_, err := s.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserId": {S: aws.String(userId)},
+			"Period": {S: aws.String(period)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release digest for %s: %w", userId, err)
+	}
+	return nil
+}
+
+// MemoryDigestStore is an in-memory DigestStore for tests and local runs
+type MemoryDigestStore struct {
+	mu      sync.Mutex
+	records map[string]DigestRecord
+}
+
+// NewMemoryDigestStore creates an empty in-memory digest store
+func NewMemoryDigestStore() *MemoryDigestStore {
+	return &MemoryDigestStore{records: make(map[string]DigestRecord)}
+}
+
+// ClaimDigest records the digest, unless one was already recorded for the period
+func (s *MemoryDigestStore) ClaimDigest(ctx context.Context, record *DigestRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := record.UserId + "/" + record.Period
+	if _, ok := s.records[key]; ok {
+		return ErrDigestSent
+	}
+	s.records[key] = *record
+	return nil
+}
+
+// ReleaseDigest removes the record of the period's digest
+func (s *MemoryDigestStore) ReleaseDigest(ctx context.Context, userId, period string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, userId+"/"+period)
+	return nil
+}
+
+// Records returns the digests recorded so far, ordered by user and period
+func (s *MemoryDigestStore) Records() []DigestRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]DigestRecord, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].UserId != records[j].UserId {
+			return records[i].UserId < records[j].UserId
+		}
+		return records[i].Period < records[j].Period
+	})
+	return records
+}
@@ -0,0 +1,303 @@
+package util
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+)
+
+// Domain events published for other applications. Their schemas are in util/schemas.
+const (
+	DomainNoticePublished = "NoticePublished"
+	DomainNoticeUpdated   = "NoticeUpdated"
+	DomainNoticeDeleted   = "NoticeDeleted"
+	DomainUserRegistered  = "UserRegistered"
+	DomainUserConfirmed   = "UserConfirmed"
+)
+
+// domainEventVersions holds the current schema version of each domain event. A change
+// that could break consumers gets a new version, and a new schema file.
+var domainEventVersions = map[string]int{
+	DomainNoticePublished: 1,
+	DomainNoticeUpdated:   1,
+	DomainNoticeDeleted:   1,
+	DomainUserRegistered:  1,
+	DomainUserConfirmed:   1,
+}
+
+// DomainEvent is an event for other applications, sent to EventBridge with the type as
+// its detail type. The ID stays the same when an event is delivered again.
+type DomainEvent struct {
+	Id         string          `json:"id" dynamodbav:"Id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// NoticeEventData is the data of the NoticePublished and NoticeUpdated events
+type NoticeEventData struct {
+	NoticeId       int        `json:"notice_id"`
+	Title          string     `json:"title"`
+	Category       string     `json:"category,omitempty"`
+	CongregationId string     `json:"congregation_id,omitempty"`
+	Priority       string     `json:"priority"`
+	Pinned         bool       `json:"pinned"`
+	PublishAt      *time.Time `json:"publish_at,omitempty"`
+	ExpireAt       *time.Time `json:"expire_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	// URL links to the notice in the web app, when NOTICEBOARD_URL is set
+	URL string `json:"url,omitempty"`
+}
+
+// NoticeDeletedData is the data of the NoticeDeleted event
+type NoticeDeletedData struct {
+	NoticeId       int    `json:"notice_id"`
+	CongregationId string `json:"congregation_id,omitempty"`
+}
+
+// UserEventData is the data of the UserRegistered and UserConfirmed events
+type UserEventData struct {
+	UserId         string `json:"user_id"`
+	Username       string `json:"username"`
+	CongregationId string `json:"congregation_id,omitempty"`
+}
+
+// NewDomainEvent creates an event of the given type at its current version
+func NewDomainEvent(eventType string, data interface{}) (*DomainEvent, error) {
+	version, ok := domainEventVersions[eventType]
+	if !ok {
+		return nil, fmt.Errorf("unknown domain event %s", eventType)
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &DomainEvent{
+		Id:         hex.EncodeToString(id),
+		Type:       eventType,
+		Version:    version,
+		OccurredAt: time.Now().UTC(),
+		Data:       body,
+	}, nil
+}
+
+// NewNoticeEventData describes the notice in NoticePublished and NoticeUpdated events
+func NewNoticeEventData(notice *Notice) NoticeEventData {
+	data := NoticeEventData{
+		NoticeId:       notice.ID,
+		Title:          notice.Title,
+		Category:       notice.Category,
+		CongregationId: notice.CongregationId,
+		Priority:       notice.EffectivePriority(),
+		Pinned:         notice.Pinned,
+		PublishAt:      notice.PublishAt,
+		ExpireAt:       notice.ExpireAt,
+		UpdatedAt:      notice.UpdatedAt.UTC(),
+	}
+	if base := noticeboardURL(); base != "" {
+		data.URL = fmt.Sprintf("%s/notices/%d", base, notice.ID)
+	}
+	return data
+}
+
+// DomainEventForChange returns the domain event for a change read from the notices
+// table's stream, if there is one. Only published notices are announced: a notice that
+// becomes published, or is restored from the trash while published, is published;
+// changes to it are updates, and moving it to the trash or purging it deletes it.
+func DomainEventForChange(change *NoticeChange) (*DomainEvent, error) {
+	notice := change.Notice()
+	published := func(n *Notice) bool {
+		return n != nil && n.EffectiveStatus() == StatusPublished && !n.IsDeleted()
+	}
+
+	var event *DomainEvent
+	var err error
+	switch {
+	case change.Type == ChangeDeleted && published(change.Old):
+		event, err = NewDomainEvent(DomainNoticeDeleted, NoticeDeletedData{NoticeId: notice.ID, CongregationId: notice.CongregationId})
+	case change.Type == ChangePublished && published(change.New), change.Type == ChangeRestored && published(change.New):
+		event, err = NewDomainEvent(DomainNoticePublished, NewNoticeEventData(notice))
+	case change.Type == ChangeUpdated && published(change.New):
+		event, err = NewDomainEvent(DomainNoticeUpdated, NewNoticeEventData(notice))
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A change delivered again yields the same event
+	event.Id = change.EventId
+	event.OccurredAt = change.OccurredAt
+	return event, nil
+}
+
+// DomainEventSubscriber publishes the domain events of the notice changes it is handed
+// by the stream consumer
+type DomainEventSubscriber struct{}
+
+// HandleNoticeChange publishes the change's domain event, if it has one
+func (DomainEventSubscriber) HandleNoticeChange(ctx context.Context, change *NoticeChange) error {
+	event, err := DomainEventForChange(change)
+	if err != nil || event == nil {
+		return err
+	}
+	return PublishDomainEvents(ctx, event)
+}
+
+// DomainEventBus delivers domain events to other applications. It returns the events
+// that could not be delivered, each with its error.
+type DomainEventBus interface {
+	PutEvents(ctx context.Context, events []*DomainEvent) map[string]error
+}
+
+// Bus is the bus domain events go out through
+var Bus DomainEventBus
+
+// NewDomainEventBus returns the configured bus: EventBridge, or with EVENT_BUS set to log,
+// a LogDomainEventBus for local development
+func NewDomainEventBus() DomainEventBus {
+	if os.Getenv("EVENT_BUS") == "log" {
+		return LogDomainEventBus{}
+	}
+	return NewEventBridgeBus()
+}
+
+// eventBridgeBatchSize is the most entries PutEvents takes at once
+const eventBridgeBatchSize = 10
+
+// EventBridgeBus puts domain events on the EVENT_BUS_NAME event bus, with the
+// EVENT_SOURCE source, noticeboard by default
+type EventBridgeBus struct {
+	eventbridge eventbridgeiface.EventBridgeAPI
+	busName     string
+	source      string
+}
+
+// NewEventBridgeBus creates a bus for the configured event bus
+func NewEventBridgeBus() *EventBridgeBus {
+	config := &aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}
+	if endpoint := os.Getenv("AWS_EVENTBRIDGE_ENDPOINT"); endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+	source := os.Getenv("EVENT_SOURCE")
+	if source == "" {
+		source = "noticeboard"
+	}
+
+	sess := session.Must(session.NewSession(config))
+	return &EventBridgeBus{
+		eventbridge: eventbridge.New(sess),
+		busName:     os.Getenv("EVENT_BUS_NAME"),
+		source:      source,
+	}
+}
+
+// PutEvents puts the events on the bus, ten at a time
+func (b *EventBridgeBus) PutEvents(ctx context.Context, events []*DomainEvent) map[string]error {
+	failed := make(map[string]error)
+	for start := 0; start < len(events); start += eventBridgeBatchSize {
+		end := start + eventBridgeBatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+		batch := events[start:end]
+
+		var sent []*DomainEvent
+		var entries []*eventbridge.PutEventsRequestEntry
+		for _, event := range batch {
+			detail, err := json.Marshal(event)
+			if err != nil {
+				failed[event.Id] = fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+				continue
+			}
+			sent = append(sent, event)
+			entries = append(entries, &eventbridge.PutEventsRequestEntry{
+				EventBusName: aws.String(b.busName),
+				Source:       aws.String(b.source),
+				DetailType:   aws.String(event.Type),
+				Detail:       aws.String(string(detail)),
+				Time:         aws.Time(event.OccurredAt),
+			})
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		// The result has an entry for each event, in order, with an error code if it failed
+		result, err := b.eventbridge.PutEventsWithContext(ctx, &eventbridge.PutEventsInput{Entries: entries})
+		for i, event := range sent {
+			switch {
+			case err != nil:
+				failed[event.Id] = fmt.Errorf("failed to put %s event: %w", event.Type, err)
+			case i < len(result.Entries) && result.Entries[i].ErrorCode != nil:
+				failed[event.Id] = fmt.Errorf("failed to put %s event: %s: %s", event.Type,
+					aws.StringValue(result.Entries[i].ErrorCode), aws.StringValue(result.Entries[i].ErrorMessage))
+			}
+		}
+	}
+	return failed
+}
+
+// LogDomainEventBus writes domain events to the log instead of publishing them
+type LogDomainEventBus struct{}
+
+// PutEvents logs the events
+func (LogDomainEventBus) PutEvents(ctx context.Context, events []*DomainEvent) map[string]error {
+	for _, event := range events {
+		body, _ := json.Marshal(event)
+		log.Println("domain event:", string(body))
+	}
+	return nil
+}
+
+// MemoryDomainEventBus captures domain events for tests. While Fail is set, every event
+// fails to be delivered.
+type MemoryDomainEventBus struct {
+	Fail   bool
+	mu     sync.Mutex
+	events []DomainEvent
+}
+
+// PutEvents records the events
+func (b *MemoryDomainEventBus) PutEvents(ctx context.Context, events []*DomainEvent) map[string]error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := make(map[string]error)
+	for _, event := range events {
+		if b.Fail {
+			failed[event.Id] = fmt.Errorf("failed to put %s event: bus unavailable", event.Type)
+			continue
+		}
+		b.events = append(b.events, *event)
+	}
+	return failed
+}
+
+// Published returns the events recorded so far
+func (b *MemoryDomainEventBus) Published() []DomainEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]DomainEvent(nil), b.events...)
+}
@@ -0,0 +1,187 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaKeys returns the required and allowed properties of a JSON schema object
+func schemaKeys(schema map[string]interface{}) ([]string, map[string]interface{}) {
+	var required []string
+	for _, key := range schema["required"].([]interface{}) {
+		required = append(required, key.(string))
+	}
+	return required, schema["properties"].(map[string]interface{})
+}
+
+// assertMatchesSchema checks that the object has the schema's required properties and no others
+func assertMatchesSchema(t *testing.T, schema map[string]interface{}, object map[string]interface{}) {
+	required, properties := schemaKeys(schema)
+	for _, key := range required {
+		assert.Contains(t, object, key)
+	}
+	for key := range object {
+		assert.Contains(t, properties, key)
+	}
+}
+
+func TestDomainEventSchemas(t *testing.T) {
+	t.Setenv("NOTICEBOARD_URL", "https://board.example.org")
+	expireAt := time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)
+	notice := &Notice{ID: 7, Title: "Cleaning", Category: "cleaning", CongregationId: "north", Priority: PriorityUrgent, ExpireAt: &expireAt}
+	samples := map[string]interface{}{
+		DomainNoticePublished: NewNoticeEventData(notice),
+		DomainNoticeUpdated:   NewNoticeEventData(&Notice{ID: 8, Title: "Meeting"}),
+		DomainNoticeDeleted:   NoticeDeletedData{NoticeId: 7, CongregationId: "north"},
+		DomainUserRegistered:  UserEventData{UserId: "sub", Username: "jane"},
+		DomainUserConfirmed:   UserEventData{UserId: "sub", Username: "jane", CongregationId: "north"},
+	}
+	assert.Len(t, samples, len(domainEventVersions))
+
+	for eventType, data := range samples {
+		event, err := NewDomainEvent(eventType, data)
+		require.NoError(t, err)
+
+		// Each version of an event has its own schema
+		body, err := os.ReadFile("schemas/" + eventType + ".v1.json")
+		require.NoError(t, err, eventType)
+		var schema map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &schema))
+		properties := schema["properties"].(map[string]interface{})
+		assert.Equal(t, float64(event.Version), properties["version"].(map[string]interface{})["const"], eventType)
+		assert.Equal(t, eventType, properties["type"].(map[string]interface{})["const"])
+
+		var detail map[string]interface{}
+		body, err = json.Marshal(event)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &detail))
+		assertMatchesSchema(t, schema, detail)
+		assertMatchesSchema(t, properties["data"].(map[string]interface{}), detail["data"].(map[string]interface{}))
+	}
+
+	_, err := NewDomainEvent("NoticeFiled", nil)
+	assert.Error(t, err)
+}
+
+func TestDomainEventForChange(t *testing.T) {
+	deletedAt := time.Now()
+	draft := &Notice{ID: 1, Status: StatusDraft}
+	published := &Notice{ID: 1, Status: StatusPublished}
+	trashed := &Notice{ID: 1, Status: StatusPublished, DeletedAt: &deletedAt}
+
+	for _, test := range []struct {
+		old, new *Notice
+		event    string
+	}{
+		{nil, draft, ""},
+		{nil, published, DomainNoticePublished},
+		{draft, published, DomainNoticePublished},
+		{published, published, DomainNoticeUpdated},
+		{draft, draft, ""},
+		{published, trashed, DomainNoticeDeleted},
+		{trashed, published, DomainNoticePublished},
+		{trashed, nil, ""},
+		{published, nil, DomainNoticeDeleted},
+		{draft, nil, ""},
+	} {
+		change := &NoticeChange{Type: ClassifyNoticeChange(test.old, test.new), EventId: "stream-1", Old: test.old, New: test.new}
+		event, err := DomainEventForChange(change)
+		assert.NoError(t, err)
+		if test.event == "" {
+			assert.Nil(t, event, change.Type)
+			continue
+		}
+		require.NotNil(t, event, change.Type)
+		assert.Equal(t, test.event, event.Type)
+		assert.Equal(t, "stream-1", event.Id)
+	}
+}
+
+func TestPublishDomainEvents(t *testing.T) {
+	bus := &MemoryDomainEventBus{Fail: true}
+	Bus = bus
+	outbox := NewMemoryOutboxStore()
+	Outbox = outbox
+	ctx := context.Background()
+
+	// Events the bus does not take stay in the outbox
+	event, err := NewDomainEvent(DomainUserConfirmed, UserEventData{UserId: "sub", Username: "jane"})
+	require.NoError(t, err)
+	err = PublishDomainEvents(ctx, event)
+	assert.NoError(t, err)
+	assert.Empty(t, bus.Published())
+
+	records, err := outbox.ListOutbox(ctx)
+	assert.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, 1, records[0].Attempts)
+	assert.Contains(t, records[0].LastError, "bus unavailable")
+
+	// The relay leaves new events to their publisher for a minute
+	now := time.Now()
+	result, err := RelayOutbox(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, OutboxResult{}, result)
+
+	result, err = RelayOutbox(ctx, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, OutboxResult{Pending: 1, Failed: 1}, result)
+
+	bus.Fail = false
+	result, err = RelayOutbox(ctx, now.Add(4*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, OutboxResult{Pending: 1, Sent: 1}, result)
+	require.Len(t, bus.Published(), 1)
+	assert.Equal(t, event.Id, bus.Published()[0].Id)
+	records, err = outbox.ListOutbox(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	// Delivered events leave the outbox straight away
+	err = PublishDomainEvents(ctx, event)
+	assert.NoError(t, err)
+	records, err = outbox.ListOutbox(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+	assert.Len(t, bus.Published(), 2)
+}
+
+func TestRelayOutbox_DeadLetter(t *testing.T) {
+	bus := &MemoryDomainEventBus{Fail: true}
+	Bus = bus
+	outbox := NewMemoryOutboxStore()
+	Outbox = outbox
+	ctx := context.Background()
+
+	event, err := NewDomainEvent(DomainUserConfirmed, UserEventData{UserId: "sub", Username: "jane"})
+	require.NoError(t, err)
+	err = outbox.AddToOutbox(ctx, []OutboxRecord{{Id: event.Id, Event: *event, CreatedAt: time.Now().Add(-time.Hour), Attempts: maxOutboxAttempts - 2}})
+	require.NoError(t, err)
+
+	result, err := RelayOutbox(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, OutboxResult{Pending: 1, Failed: 1}, result)
+
+	// The last attempt gives the event up
+	result, err = RelayOutbox(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, OutboxResult{Pending: 1, Failed: 1}, result)
+	records, err := outbox.ListOutbox(ctx)
+	assert.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, maxOutboxAttempts, records[0].Attempts)
+	assert.NotNil(t, records[0].FailedAt)
+
+	// Dead letters are no longer delivered, even once the bus works again
+	bus.Fail = false
+	result, err = RelayOutbox(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, OutboxResult{DeadLetters: 1}, result)
+	assert.Empty(t, bus.Published())
+}
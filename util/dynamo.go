@@ -0,0 +1,46 @@
+package util
+
+import (
+	"errors"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// newDynamoClient creates a DynamoDB client for the configured region.
+// AWS_DYNAMO_ENDPOINT can point the client at DynamoDB Local for development.
+func newDynamoClient() dynamodbiface.DynamoDBAPI {
+	config := &aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}
+	if endpoint := os.Getenv("AWS_DYNAMO_ENDPOINT"); endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+
+	sess := session.Must(session.NewSession(config))
+	return dynamodb.New(sess)
+}
+
+// isConditionFailed reports whether err is a failed DynamoDB condition check,
+// either of a single write or of an item in a transaction
+func isConditionFailed(err error) bool {
+	var canceled *dynamodb.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		for _, reason := range canceled.CancellationReasons {
+			if aws.StringValue(reason.Code) == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+		return false
+	}
+
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		return aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+	}
+	return false
+}
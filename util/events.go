@@ -0,0 +1,100 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Notice event types
+const (
+	EventNoticeScheduled = "NoticeScheduled"
+	EventNoticeLive      = "NoticeLive"
+	EventNoticeExpired   = "NoticeExpired"
+	EventNoticeSubmitted = "NoticeSubmitted"
+	EventNoticeApproved  = "NoticeApproved"
+	EventNoticeRejected  = "NoticeRejected"
+	EventNoticeRevised   = "NoticeRevised"
+	EventNoticePublished = "NoticePublished"
+	EventNoticeArchived  = "NoticeArchived"
+	EventNoticeUpdated   = "NoticeUpdated"
+)
+
+// NoticeEvent describes something that happened to a notice
+type NoticeEvent struct {
+	Type       string    `json:"type"`
+	NoticeId   int       `json:"notice_id"`
+	Notice     *Notice   `json:"notice,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// NewNoticeEvent creates an event of the given type for the notice
+func NewNoticeEvent(eventType string, notice *Notice) NoticeEvent {
+	return NoticeEvent{
+		Type:       eventType,
+		NoticeId:   notice.ID,
+		Notice:     notice,
+		OccurredAt: time.Now().UTC(),
+	}
+}
+
+// EventPublisher delivers notice events to whoever is interested in them
+type EventPublisher interface {
+	Publish(ctx context.Context, event NoticeEvent) error
+}
+
+// Events is the publisher the handlers emit notice events through
+var Events EventPublisher = LogEventPublisher{}
+
+// LogEventPublisher writes events as JSON lines to the Lambda log
+type LogEventPublisher struct{}
+
+// Publish logs the event
+func (LogEventPublisher) Publish(ctx context.Context, event NoticeEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	log.Println("event:", string(body))
+	return nil
+}
+
+// EventPublishers publishes every event to each of the publishers in turn
+type EventPublishers []EventPublisher
+
+// Publish hands the event to every publisher, even when an earlier one fails
+func (p EventPublishers) Publish(ctx context.Context, event NoticeEvent) error {
+	var errs []error
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MemoryEventPublisher records published events for tests
+type MemoryEventPublisher struct {
+	mu     sync.Mutex
+	events []NoticeEvent
+}
+
+// Publish records the event
+func (p *MemoryEventPublisher) Publish(ctx context.Context, event NoticeEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Published returns the events recorded so far
+func (p *MemoryEventPublisher) Published() []NoticeEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]NoticeEvent(nil), p.events...)
+}
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var (
+	// ErrMissingFeedToken is returned for feed requests without a token when the feed is not public
+	ErrMissingFeedToken = errors.New("missing feed token")
+	// ErrInvalidFeedToken is returned when a feed token is invalid, expired or for another feed
+	ErrInvalidFeedToken = errors.New("invalid feed token")
+)
+
+// FeedToken is a token for a personal feed URL, such as the calendar feed
+type FeedToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// FeedCongregation returns the congregation whose notices a feed request may read.
+// Feed readers and calendar apps cannot log in, so the feed URL carries a token with
+// the given scope in the token query parameter. Public feeds are also served without
+// one, for the congregation in the congregation query parameter.
+func FeedCongregation(request events.APIGatewayProxyRequest, scope string, public bool) (string, error) {
+	if token := request.QueryStringParameters["token"]; token != "" {
+		claims, err := VerifySessionToken(token)
+		if err != nil {
+			return "", err
+		}
+		if !claims.HasScope(scope) {
+			return "", ErrInvalidFeedToken
+		}
+		return claims.CongregationId, nil
+	}
+	if public {
+		return request.QueryStringParameters["congregation"], nil
+	}
+	return "", ErrMissingFeedToken
+}
+
+// FeedTokenHandler returns a handler creating a feed token with the given scope for the
+// signed-in user. The token is valid for FeedTokenTTL and grants nothing but reading
+// the feed of the user's congregation. It must be used inside RequireSession.
+func FeedTokenHandler(scope string) Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		claims, _ := SessionFromContext(ctx)
+		if claims.Device {
+			return ErrorResponse(http.StatusForbidden, "Display devices cannot create feed tokens")
+		}
+
+		ttl := FeedTokenTTL()
+		token, err := GenerateSessionToken(SessionClaims{
+			Subject:        claims.Subject,
+			Username:       claims.Username,
+			CongregationId: claims.CongregationId,
+			Scopes:         []string{scope},
+		}, ttl)
+		if err != nil {
+			log.Println("Failed to generate feed token:", err)
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		}
+
+		return JSONResponse(http.StatusCreated, FeedToken{
+			Token:     token,
+			ExpiresAt: time.Now().Add(ttl).UTC(),
+		})
+	}
+}
+
+// FeedNotices returns the notices of the congregation that every member sees
+func FeedNotices(ctx context.Context, congregationId, category string, now time.Time) ([]Notice, error) {
+	notices, err := Notices.ListNotices(ctx, NoticeFilter{CongregationId: congregationId, Category: category})
+	if err != nil {
+		return nil, err
+	}
+	member := &SessionClaims{CongregationId: congregationId}
+	return VisibleNotices(member, notices, now), nil
+}
@@ -0,0 +1,266 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icalLocalLayout formats times in a named time zone, icalUTCLayout times in UTC
+const (
+	icalLocalLayout = "20060102T150405"
+	icalUTCLayout   = "20060102T150405Z"
+)
+
+// Calendar is an iCalendar (RFC 5545) feed of event notices
+type Calendar struct {
+	Name    string
+	Notices []Notice
+	// Now decides the year whose daylight saving rules describe the time zones
+	Now time.Time
+}
+
+// CalendarUID returns the stable UID of the notice's event in calendar feeds.
+// The domain part is read from CALENDAR_UID_DOMAIN.
+func CalendarUID(n *Notice) string {
+	domain := os.Getenv("CALENDAR_UID_DOMAIN")
+	if domain == "" {
+		domain = "noticeboard"
+	}
+	return fmt.Sprintf("notice-%d@%s", n.ID, domain)
+}
+
+// Render writes the feed. Recurring events keep their recurrence rule and exceptions,
+// and cancelled occurrences are overridden with a cancelled instance.
+func (c *Calendar) Render() ([]byte, error) {
+	w := &icalWriter{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//Congregation Noticeboard//Calendar//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		w.text("X-WR-CALNAME", c.Name)
+	}
+
+	// Every time zone the events use is described once
+	zones := make(map[string]*time.Location)
+	for i := range c.Notices {
+		if c.Notices[i].IsEvent() {
+			location := c.Notices[i].Event.Zone()
+			if location != time.UTC {
+				zones[location.String()] = location
+			}
+		}
+	}
+	names := make([]string, 0, len(zones))
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeTimeZone(w, zones[name], c.Now.Year())
+	}
+
+	for i := range c.Notices {
+		if c.Notices[i].IsEvent() {
+			err := writeEvent(w, &c.Notices[i])
+			if err != nil {
+				return nil, fmt.Errorf("failed to render notice %d: %w", c.Notices[i].ID, err)
+			}
+		}
+	}
+
+	w.line("END:VCALENDAR")
+	return []byte(w.String()), nil
+}
+
+func writeEvent(w *icalWriter, n *Notice) error {
+	event := n.Event
+	rule, err := event.Recurrence()
+	if err != nil {
+		return err
+	}
+	location := event.Zone()
+	start := event.Start.In(location)
+	duration := event.End.Sub(event.Start)
+
+	status := "CONFIRMED"
+	if event.Cancelled {
+		status = "CANCELLED"
+	}
+
+	w.line("BEGIN:VEVENT")
+	writeEventHeader(w, n)
+	w.dateTime("DTSTART", start)
+	w.dateTime("DTEND", start.Add(duration))
+	if rule != nil {
+		w.line("RRULE:" + rule.String())
+		for _, date := range event.ExDates {
+			w.dateTime("EXDATE", occurrenceOn(start, date))
+		}
+	}
+	w.text("SUMMARY", n.Title)
+	if n.Content != "" {
+		w.text("DESCRIPTION", n.Content)
+	}
+	if event.Location != "" {
+		w.text("LOCATION", event.Location)
+	}
+	if n.Category != "" {
+		w.text("CATEGORIES", n.Category)
+	}
+	w.line("STATUS:" + status)
+	w.line("END:VEVENT")
+
+	// Cancelled occurrences replace the instance the rule generates
+	if rule == nil || event.Cancelled {
+		return nil
+	}
+	for _, date := range event.CancelledDates {
+		occurrence := occurrenceOn(start, date)
+		w.line("BEGIN:VEVENT")
+		writeEventHeader(w, n)
+		w.dateTime("RECURRENCE-ID", occurrence)
+		w.dateTime("DTSTART", occurrence)
+		w.dateTime("DTEND", occurrence.Add(duration))
+		w.text("SUMMARY", n.Title)
+		w.line("STATUS:CANCELLED")
+		w.line("END:VEVENT")
+	}
+	return nil
+}
+
+// writeEventHeader writes the identifying properties shared by an event and its overrides.
+// They only change when the notice does, so an unchanged feed renders identically.
+func writeEventHeader(w *icalWriter, n *Notice) {
+	w.text("UID", CalendarUID(n))
+	w.line("DTSTAMP:" + n.UpdatedAt.UTC().Format(icalUTCLayout))
+	w.line("CREATED:" + n.CreatedAt.UTC().Format(icalUTCLayout))
+	w.line("LAST-MODIFIED:" + n.UpdatedAt.UTC().Format(icalUTCLayout))
+	w.line("SEQUENCE:" + strconv.Itoa(n.Version))
+}
+
+// occurrenceOn returns the occurrence on the given local date, at the time of day of start
+func occurrenceOn(start time.Time, date string) time.Time {
+	day, _ := time.Parse(DateLayout, date)
+	hour, minute, second := start.Clock()
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, second, 0, start.Location())
+}
+
+// writeTimeZone describes the location's offsets with the daylight saving rules of the given year
+func writeTimeZone(w *icalWriter, location *time.Location, year int) {
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + location.String())
+
+	january := time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+	july := time.Date(year, time.July, 1, 0, 0, 0, 0, location)
+	_, januaryOffset := january.Zone()
+	_, julyOffset := july.Zone()
+	_, januaryEnd := january.ZoneBounds()
+	_, julyEnd := july.ZoneBounds()
+
+	if januaryOffset == julyOffset || januaryEnd.IsZero() || julyEnd.IsZero() {
+		// No daylight saving time
+		name, offset := july.Zone()
+		w.line("BEGIN:STANDARD")
+		w.line("DTSTART:19700101T000000")
+		w.line("TZOFFSETFROM:" + formatOffset(offset))
+		w.line("TZOFFSETTO:" + formatOffset(offset))
+		w.text("TZNAME", name)
+		w.line("END:STANDARD")
+	} else {
+		// January's period ends when July's begins, and the other way around
+		writeObservance(w, july, januaryEnd, januaryOffset)
+		writeObservance(w, january, julyEnd, julyOffset)
+	}
+	w.line("END:VTIMEZONE")
+}
+
+// writeObservance writes the period t falls in, which begins yearly at onset
+func writeObservance(w *icalWriter, t, onset time.Time, fromOffset int) {
+	component := "STANDARD"
+	if t.IsDST() {
+		component = "DAYLIGHT"
+	}
+	name, offset := t.Zone()
+
+	// The onset is given in the local time before the change
+	local := onset.In(time.FixedZone("", fromOffset))
+	daysInMonth := time.Date(local.Year(), local.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	ordinal := (local.Day() + 6) / 7
+	if local.Day() > daysInMonth-7 {
+		ordinal = -1
+	}
+
+	// The rule starts in 1970, so it also covers events from before this year
+	rule := &Recurrence{ByDay: []RuleDay{{Ordinal: ordinal, Weekday: local.Weekday()}}}
+	first := time.Date(1970, local.Month(), 1, 0, 0, 0, 0, time.UTC)
+	hour, minute, second := local.Clock()
+	dtstart := time.Date(1970, local.Month(), rule.monthDays(first, 0)[0], hour, minute, second, 0, time.UTC)
+
+	w.line("BEGIN:" + component)
+	w.line("DTSTART:" + dtstart.Format(icalLocalLayout))
+	w.line(fmt.Sprintf("RRULE:FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", int(local.Month()), ordinal, weekdayCode(local.Weekday())))
+	w.line("TZOFFSETFROM:" + formatOffset(fromOffset))
+	w.line("TZOFFSETTO:" + formatOffset(offset))
+	w.text("TZNAME", name)
+	w.line("END:" + component)
+}
+
+// formatOffset formats an offset in seconds east of UTC as +hhmm
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+}
+
+// icalWriter builds iCalendar content lines, folded at 75 octets and ended with CRLF
+type icalWriter struct {
+	strings.Builder
+}
+
+func (w *icalWriter) line(line string) {
+	// Continuation lines start with a space, which counts towards their length
+	limit := 75
+	for len(line) > limit {
+		// Never split a UTF-8 sequence
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	w.WriteString(line + "\r\n")
+}
+
+// text writes a property with a text value, escaped as RFC 5545 requires
+func (w *icalWriter) text(name, value string) {
+	value = strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", "",
+	).Replace(value)
+	w.line(name + ":" + value)
+}
+
+// dateTime writes a date-time property, with a TZID unless the time is in UTC
+func (w *icalWriter) dateTime(name string, t time.Time) {
+	if t.Location() == time.UTC {
+		w.line(name + ":" + t.Format(icalUTCLayout))
+		return
+	}
+	w.line(name + ";TZID=" + t.Location().String() + ":" + t.Format(icalLocalLayout))
+}
@@ -0,0 +1,304 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Translation is the title and content of a notice in another language
+type Translation struct {
+	Title       string `json:"title"`
+	Content     string `json:"content,omitempty"`
+	ContentHTML string `json:"content_html,omitempty"`
+}
+
+func isLetters(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphanumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isLetters(s[i:i+1]) && !isDigits(s[i:i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLanguageTag checks that tag is a well-formed BCP 47 language tag, such as "en",
+// "nl-BE" or "sr-Latn-RS", and returns it in its conventional case: the language in
+// lower case, a script capitalised and a region in upper case. Underscores are taken
+// for hyphens.
+func ParseLanguageTag(tag string) (string, error) {
+	subtags := strings.Split(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+	language := subtags[0]
+	if !isLetters(language) || len(language) < 2 || len(language) > 8 || len(language) == 4 {
+		return "", fmt.Errorf("%q is not a language tag", tag)
+	}
+
+	canonical := []string{strings.ToLower(language)}
+	extension := false
+	for i, subtag := range subtags[1:] {
+		if subtag == "" || len(subtag) > 8 || !isAlphanumeric(subtag) {
+			return "", fmt.Errorf("%q is not a language tag", tag)
+		}
+		switch {
+		case len(subtag) == 1:
+			// Extensions and private use subtags follow a single-letter singleton
+			extension = true
+			subtag = strings.ToLower(subtag)
+		case !extension && i == 0 && len(subtag) == 4 && isLetters(subtag):
+			subtag = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		case !extension && len(subtag) == 2 && isLetters(subtag), !extension && len(subtag) == 3 && isDigits(subtag):
+			subtag = strings.ToUpper(subtag)
+		default:
+			subtag = strings.ToLower(subtag)
+		}
+		canonical = append(canonical, subtag)
+	}
+	if extension && len(subtags[len(subtags)-1]) == 1 {
+		return "", fmt.Errorf("%q is not a language tag", tag)
+	}
+	return strings.Join(canonical, "-"), nil
+}
+
+// baseLanguage returns the language subtag of a tag, "nl" for "nl-BE"
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(tag, "-")
+	return strings.ToLower(base)
+}
+
+// ParseAcceptLanguage returns the languages of an Accept-Language header, most preferred
+// first. Languages with a quality of 0, the * wildcard and tags that are not well-formed
+// are left out.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+	var languages []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		tag, err := ParseLanguageTag(tag)
+		if err != nil || quality <= 0 {
+			continue
+		}
+		languages = append(languages, weighted{tag, quality})
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+	tags := make([]string, 0, len(languages))
+	for _, language := range languages {
+		tags = append(tags, language.tag)
+	}
+	return tags
+}
+
+// RequestLanguages returns the languages the caller would like notices in, most
+// preferred first: the lang query parameter, then those of the Accept-Language header
+func RequestLanguages(request events.APIGatewayProxyRequest) []string {
+	var languages []string
+	if tag, err := ParseLanguageTag(request.QueryStringParameters["lang"]); err == nil {
+		languages = append(languages, tag)
+	}
+	return append(languages, ParseAcceptLanguage(HeaderValue(request, "Accept-Language"))...)
+}
+
+// MatchLanguage picks the available language that best suits the preferred languages,
+// or "" when none does. Each preferred language, in order, matches the same tag, then
+// a less specific one ("nl" for "nl-BE"), then any other of the same language.
+func MatchLanguage(preferred, available []string) string {
+	for _, want := range preferred {
+		for tag := want; tag != ""; {
+			for _, have := range available {
+				if strings.EqualFold(have, tag) {
+					return have
+				}
+			}
+			i := strings.LastIndex(tag, "-")
+			if i < 0 {
+				break
+			}
+			tag = tag[:i]
+		}
+		for _, have := range available {
+			if baseLanguage(have) == baseLanguage(want) {
+				return have
+			}
+		}
+	}
+	return ""
+}
+
+// DefaultLanguages returns the languages notices should be available in when the
+// congregation does not say: those of NOTICE_LANGUAGES, a comma-separated list
+func DefaultLanguages() []string {
+	var languages []string
+	for _, tag := range strings.Split(os.Getenv("NOTICE_LANGUAGES"), ",") {
+		if tag, err := ParseLanguageTag(tag); err == nil {
+			languages = append(languages, tag)
+		}
+	}
+	return languages
+}
+
+// CongregationLanguages returns the languages the congregation's notices should be
+// available in, or the DefaultLanguages when it has none configured
+func CongregationLanguages(ctx context.Context, congregationId string) ([]string, error) {
+	if congregationId != "" {
+		congregation, err := Congregations.GetCongregation(ctx, congregationId)
+		if err != nil {
+			return nil, err
+		}
+		if congregation != nil && len(congregation.Languages) > 0 {
+			return congregation.Languages, nil
+		}
+	}
+	return DefaultLanguages(), nil
+}
+
+// validateLanguages canonicalises a list of language tags, rejecting malformed ones and
+// dropping repeats
+func validateLanguages(field string, languages []string) ([]string, error) {
+	var valid []string
+	seen := make(map[string]bool)
+	for _, language := range languages {
+		tag, err := ParseLanguageTag(language)
+		if err != nil {
+			return nil, &ValidationError{Message: fmt.Sprintf("%s must be BCP 47 language tags such as en or nl-BE", field)}
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			valid = append(valid, tag)
+		}
+	}
+	return valid, nil
+}
+
+// validateTranslations checks the notice's language and translations, bringing their
+// tags into their conventional case
+func validateTranslations(notice *Notice) error {
+	if notice.Language != "" {
+		tag, err := ParseLanguageTag(notice.Language)
+		if err != nil {
+			return &ValidationError{Message: "language must be a BCP 47 language tag such as en or nl-BE"}
+		}
+		notice.Language = tag
+	}
+
+	// A notice read in another language must not be saved over its own
+	if notice.DisplayLanguage != "" && notice.Language != "" && !strings.EqualFold(notice.DisplayLanguage, notice.Language) {
+		return &ValidationError{Message: fmt.Sprintf("The title and content are in %s, not the notice's language; read it with lang=%s to change it", notice.DisplayLanguage, notice.Language)}
+	}
+	notice.DisplayLanguage = ""
+	notice.MissingTranslations = nil
+
+	if len(notice.Translations) == 0 {
+		notice.Translations = nil
+		return nil
+	}
+	if notice.Language == "" {
+		return &ValidationError{Message: "language is required for a notice with translations"}
+	}
+	translations := make(map[string]Translation, len(notice.Translations))
+	for language, translation := range notice.Translations {
+		tag, err := ParseLanguageTag(language)
+		if err != nil {
+			return &ValidationError{Message: fmt.Sprintf("Translations must be keyed by BCP 47 language tags, not %q", language)}
+		}
+		if tag == notice.Language {
+			return &ValidationError{Message: fmt.Sprintf("The notice is already in %s", tag)}
+		}
+		if _, ok := translations[tag]; ok {
+			return &ValidationError{Message: fmt.Sprintf("There is more than one %s translation", tag)}
+		}
+		if translation.Title == "" {
+			return &ValidationError{Message: fmt.Sprintf("The %s translation needs a title", tag)}
+		}
+		translations[tag] = translation
+	}
+	notice.Translations = translations
+	return nil
+}
+
+// Languages returns the languages the notice is available in: its own, if it says,
+// followed by those of its translations
+func (n *Notice) Languages() []string {
+	var languages []string
+	if n.Language != "" {
+		languages = append(languages, n.Language)
+	}
+	translated := make([]string, 0, len(n.Translations))
+	for language := range n.Translations {
+		translated = append(translated, language)
+	}
+	sort.Strings(translated)
+	return append(languages, translated...)
+}
+
+// Localize puts the notice's title and content in the available language that best
+// suits the preferred languages, keeping its own when none does, and lists the expected
+// languages it has no translation in. A notice that does not say which language it is
+// in is taken to be in the first expected language.
+func (n *Notice) Localize(preferred, expected []string) {
+	language := n.Language
+	if language == "" && len(expected) > 0 {
+		language = expected[0]
+	}
+	available := n.Languages()
+	if n.Language == "" && language != "" {
+		available = append([]string{language}, available...)
+	}
+
+	if match := MatchLanguage(preferred, available); match != "" && match != language {
+		// Parts left untranslated stay in the notice's own language
+		translation := n.Translations[match]
+		n.Title = translation.Title
+		if translation.Content != "" {
+			n.Content = translation.Content
+			n.ContentHTML = translation.ContentHTML
+		}
+		language = match
+	}
+	n.DisplayLanguage = language
+
+	n.MissingTranslations = nil
+	for _, want := range expected {
+		if MatchLanguage([]string{want}, available) == "" {
+			n.MissingTranslations = append(n.MissingTranslations, want)
+		}
+	}
+}
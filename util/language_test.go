@@ -0,0 +1,83 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLanguageTag(t *testing.T) {
+	for tag, canonical := range map[string]string{
+		"en":                      "en",
+		"NL-be":                   "nl-BE",
+		"sr_latn_rs":              "sr-Latn-RS",
+		"es-419":                  "es-419",
+		"de-CH-1996":              "de-CH-1996",
+		"en-US-x-twain":           "en-US-x-twain",
+		"zh-hant-TW-u-ca-chinese": "zh-Hant-TW-u-ca-chinese",
+	} {
+		got, err := ParseLanguageTag(tag)
+		assert.NoError(t, err, tag)
+		assert.Equal(t, canonical, got)
+	}
+	for _, tag := range []string{"", "e", "en-toolongsubtag", "en-", "en--US", "en-x", "12", "en-US!"} {
+		_, err := ParseLanguageTag(tag)
+		assert.Error(t, err, tag)
+	}
+}
+
+func TestMatchLanguage(t *testing.T) {
+	assert.Equal(t, []string{"nl-BE", "fr", "en"}, ParseAcceptLanguage("en;q=0.5, nl-be, *;q=0.1, de;q=0, fr;q=0.8, 12"))
+
+	available := []string{"en", "nl-BE", "pt"}
+	assert.Equal(t, "nl-BE", MatchLanguage([]string{"nl"}, available))
+	assert.Equal(t, "en", MatchLanguage([]string{"en-GB"}, available))
+	assert.Equal(t, "pt", MatchLanguage([]string{"de", "pt-BR", "en"}, available))
+	assert.Equal(t, "", MatchLanguage([]string{"fr"}, available))
+}
+
+func TestValidateTranslations(t *testing.T) {
+	Categories = NewMemoryCategoryStore()
+	notice := &Notice{Title: "Cleaning", Language: "EN", Translations: map[string]Translation{"nl-be": {Title: "Schoonmaak"}}}
+	assert.NoError(t, ValidateNotice(context.Background(), notice))
+	assert.Equal(t, "en", notice.Language)
+	assert.Contains(t, notice.Translations, "nl-BE")
+
+	for message, notice := range map[string]*Notice{
+		"language is required": {Title: "Cleaning", Translations: map[string]Translation{"nl": {Title: "Schoonmaak"}}},
+		"already in en":        {Title: "Cleaning", Language: "en", Translations: map[string]Translation{"EN": {Title: "Cleaning"}}},
+		"needs a title":        {Title: "Cleaning", Language: "en", Translations: map[string]Translation{"nl": {Content: "Zaterdag"}}},
+		"BCP 47":               {Title: "Cleaning", Language: "en_1"},
+		"read it with lang=en": {Title: "Schoonmaak", Language: "en", DisplayLanguage: "nl"},
+		"more than one nl-BE":  {Title: "Cleaning", Language: "en", Translations: map[string]Translation{"nl-be": {Title: "A"}, "nl-BE": {Title: "B"}}},
+	} {
+		err := ValidateNotice(context.Background(), notice)
+		assert.ErrorContains(t, err, message)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	notice := Notice{
+		Title:        "Cleaning",
+		Content:      "Saturday",
+		ContentHTML:  "<p>Saturday</p>\n",
+		Language:     "en",
+		Translations: map[string]Translation{"nl": {Title: "Schoonmaak"}},
+	}
+
+	// Untranslated parts stay in the notice's language
+	localized := notice
+	localized.Localize([]string{"nl-NL"}, []string{"en", "nl", "fr"})
+	assert.Equal(t, "Schoonmaak", localized.Title)
+	assert.Equal(t, "Saturday", localized.Content)
+	assert.Equal(t, "nl", localized.DisplayLanguage)
+	assert.Equal(t, []string{"fr"}, localized.MissingTranslations)
+
+	// Notices that do not say are in the congregation's first language
+	untagged := Notice{Title: "Schoonmaak"}
+	untagged.Localize([]string{"en"}, []string{"nl", "en"})
+	assert.Equal(t, "Schoonmaak", untagged.Title)
+	assert.Equal(t, "nl", untagged.DisplayLanguage)
+	assert.Equal(t, []string{"en"}, untagged.MissingTranslations)
+}
@@ -0,0 +1,120 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+)
+
+// EmailMessage is an email with HTML and plain text bodies
+type EmailMessage struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, message EmailMessage) error
+}
+
+// Mail is the mailer notifications are sent through
+var Mail Mailer
+
+// NewMailer returns the configured mailer: SES, or with MAIL_SENDER set to log,
+// a LogMailer for local development
+func NewMailer() Mailer {
+	if os.Getenv("MAIL_SENDER") == "log" {
+		return LogMailer{}
+	}
+	return NewSESMailer()
+}
+
+// SESMailer sends emails through Amazon SES from the SES_FROM_ADDRESS address.
+// SES_CONFIGURATION_SET optionally names the configuration set tracking deliveries.
+type SESMailer struct {
+	ses              sesiface.SESAPI
+	from             string
+	configurationSet string
+}
+
+// NewSESMailer creates a mailer for the configured sender
+func NewSESMailer() *SESMailer {
+	config := &aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}
+	if endpoint := os.Getenv("AWS_SES_ENDPOINT"); endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+
+	sess := session.Must(session.NewSession(config))
+	return &SESMailer{
+		ses:              ses.New(sess),
+		from:             os.Getenv("SES_FROM_ADDRESS"),
+		configurationSet: os.Getenv("SES_CONFIGURATION_SET"),
+	}
+}
+
+// Send sends the email
+func (m *SESMailer) Send(ctx context.Context, message EmailMessage) error {
+	input := &ses.SendEmailInput{
+		Source:      aws.String(m.from),
+		Destination: &ses.Destination{ToAddresses: []*string{aws.String(message.To)}},
+		Message: &ses.Message{
+			Subject: &ses.Content{Charset: aws.String("UTF-8"), Data: aws.String(message.Subject)},
+			Body: &ses.Body{
+				Html: &ses.Content{Charset: aws.String("UTF-8"), Data: aws.String(message.HTML)},
+				Text: &ses.Content{Charset: aws.String("UTF-8"), Data: aws.String(message.Text)},
+			},
+		},
+	}
+	if m.configurationSet != "" {
+		input.ConfigurationSetName = aws.String(m.configurationSet)
+	}
+
+	_, err := m.ses.SendEmailWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", message.To, err)
+	}
+	return nil
+}
+
+// LogMailer writes emails to the log instead of sending them
+type LogMailer struct{}
+
+// Send logs the email
+func (LogMailer) Send(ctx context.Context, message EmailMessage) error {
+	log.Printf("email to %s: %s\n%s", message.To, message.Subject, message.Text)
+	return nil
+}
+
+// MemoryMailer captures emails for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []EmailMessage
+}
+
+// Send records the email
+func (m *MemoryMailer) Send(ctx context.Context, message EmailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// Sent returns the emails recorded so far
+func (m *MemoryMailer) Sent() []EmailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]EmailMessage(nil), m.messages...)
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdown renders notice content. Raw HTML in the source is dropped by the renderer;
+// the output is sanitised again so nothing unsafe reaches the clients.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.Strikethrough, extension.Linkify),
+)
+
+// htmlPolicy allows the formatting Markdown produces and removes scripts, event
+// handlers, styles and links other than http, https and mailto
+var htmlPolicy = func() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowURLSchemes("http", "https", "mailto")
+	policy.RequireParseableURLs(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	return policy
+}()
+
+// RenderMarkdown turns Markdown into sanitised HTML
+func RenderMarkdown(source string) string {
+	var buf bytes.Buffer
+	err := markdown.Convert([]byte(source), &buf)
+	if err != nil {
+		// Fall back to the escaped source rather than failing the save
+		return "<p>" + html.EscapeString(source) + "</p>"
+	}
+	return htmlPolicy.Sanitize(buf.String())
+}
+
+// RenderContent stores the rendered HTML of the notice's Markdown content, and of its
+// translations
+func (n *Notice) RenderContent() {
+	n.ContentHTML = ""
+	if n.Content != "" {
+		n.ContentHTML = RenderMarkdown(n.Content)
+	}
+	for language, translation := range n.Translations {
+		translation.ContentHTML = ""
+		if translation.Content != "" {
+			translation.ContentHTML = RenderMarkdown(translation.Content)
+		}
+		n.Translations[language] = translation
+	}
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	html := RenderMarkdown("# Cleaning\n\n- **Group 2** on [Saturday](https://example.org/rota)\n- Group 3")
+	assert.Contains(t, html, "<h1>Cleaning</h1>")
+	assert.Contains(t, html, "<li><strong>Group 2</strong> on <a href=\"https://example.org/rota\"")
+	assert.Contains(t, html, "<li>Group 3</li>")
+}
+
+func TestRenderMarkdown_Unsafe(t *testing.T) {
+	sources := []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[click](javascript:alert(1))",
+		"![pic](data:text/html;base64,PHNjcmlwdD4=)",
+		"<a href=\"#\" onclick=\"alert(1)\">link</a>",
+	}
+	for _, source := range sources {
+		html := strings.ToLower(RenderMarkdown(source))
+		assert.NotContains(t, html, "<script", source)
+		assert.NotContains(t, html, "onerror", source)
+		assert.NotContains(t, html, "onclick", source)
+		assert.NotContains(t, html, "javascript:", source)
+		assert.NotContains(t, html, "data:", source)
+	}
+}
+
+func TestApplyNoticePatch_Content(t *testing.T) {
+	notice := &Notice{ID: 1, Title: "Rota", Content: "old"}
+	notice.RenderContent()
+
+	patched, attributes, err := ApplyNoticePatch(notice, []byte(`{"content": "**new**"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"content", "content_html"}, attributes)
+	assert.Equal(t, "<p><strong>new</strong></p>\n", patched.ContentHTML)
+
+	// Other changes keep the rendered content
+	patched, attributes, err = ApplyNoticePatch(notice, []byte(`{"title": "Cleaning rota"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"title"}, attributes)
+	assert.Equal(t, "<p>old</p>\n", patched.ContentHTML)
+
+	// The HTML is never set directly
+	_, _, err = ApplyNoticePatch(notice, []byte(`{"content_html": "<script></script>"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler is the signature shared by the API Gateway Lambda handlers
+type Handler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+type sessionContextKey struct{}
+
+// ContextWithSession returns a copy of ctx carrying the given session claims
+func ContextWithSession(ctx context.Context, claims *SessionClaims) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, claims)
+}
+
+// SessionFromContext returns the session claims stored by RequireSession, if any
+func SessionFromContext(ctx context.Context) (*SessionClaims, bool) {
+	claims, ok := ctx.Value(sessionContextKey{}).(*SessionClaims)
+	return claims, ok && claims != nil
+}
+
+// RequireSession wraps a handler so it only runs for requests carrying a valid session
+// token with the given scope. The token is read from the auth header set at login,
+// or from a bearer Authorization header. Display devices authenticate with an
+// x-api-key header instead, which is checked against DeviceKeys.
+func RequireSession(scope string, next Handler) Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		var claims *SessionClaims
+		var err error
+
+		if apiKey := HeaderValue(request, "x-api-key"); apiKey != "" {
+			claims, err = VerifyDeviceKey(ctx, DeviceKeys, apiKey)
+			if err != nil {
+				log.Println("Failed to verify device key:", err)
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusUnauthorized,
+					Body:       "Invalid device key",
+				}, nil
+			}
+		} else {
+			token := SessionTokenFromRequest(request)
+			if token == "" {
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusUnauthorized,
+					Body:       "Missing session token",
+				}, nil
+			}
+
+			claims, err = VerifySessionToken(token)
+			if err != nil {
+				log.Println("Failed to verify session token:", err)
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusUnauthorized,
+					Body:       "Invalid session token",
+				}, nil
+			}
+		}
+
+		if !claims.HasScope(scope) {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       "Session token does not grant " + scope + " access",
+			}, nil
+		}
+
+		return next(ContextWithSession(ctx, claims), request)
+	}
+}
+
+// RequireGroup wraps a handler so it only runs for sessions in one of the given Cognito groups.
+// It must be used inside RequireSession.
+func RequireGroup(next Handler, groups ...string) Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		claims, ok := SessionFromContext(ctx)
+		if ok {
+			for _, group := range groups {
+				if claims.InGroup(group) {
+					return next(ctx, request)
+				}
+			}
+		}
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusForbidden,
+			Body:       "Insufficient permissions",
+		}, nil
+	}
+}
+
+// SessionTokenFromRequest extracts the session token from the request headers
+func SessionTokenFromRequest(request events.APIGatewayProxyRequest) string {
+	if token := HeaderValue(request, "auth"); token != "" {
+		return token
+	}
+
+	authorization := HeaderValue(request, "Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+	return ""
+}
+
+// HeaderValue looks up a request header case-insensitively
+func HeaderValue(request events.APIGatewayProxyRequest, name string) string {
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,1312 @@
+package util
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Notice is a single item on the noticeboard
+type Notice struct {
+	ID          int          `json:"id" dynamodbav:"Id"`
+	Title       string       `json:"title"`
+	Content     string       `json:"content"`
+	ContentHTML string       `json:"content_html,omitempty"`
+	Author      string       `json:"author"`
+	AuthorId    string       `json:"author_id,omitempty"`
+	Version     int          `json:"version"`
+	Category    string       `json:"category,omitempty"`
+	Tags        []string     `json:"tags,omitempty" dynamodbav:"tags,omitempty,stringset"`
+	Pinned      bool         `json:"pinned,omitempty"`
+	Priority    string       `json:"priority,omitempty"`
+	Position    int          `json:"position,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	DeletedAt   *time.Time   `json:"deleted_at"`
+	DeletedBy   string       `json:"deleted_by,omitempty"`
+	// CongregationId is the congregation the notice belongs to, empty in single-congregation deployments
+	CongregationId string `json:"congregation_id,omitempty" dynamodbav:"congregation_id,omitempty"`
+	// Type is NoticeTypeEvent for events, which say when they take place in Event
+	Type  string        `json:"type,omitempty"`
+	Event *EventDetails `json:"event,omitempty"`
+	// PublishAt and ExpireAt bound the time the notice is shown on the board
+	PublishAt     *time.Time `json:"publish_at,omitempty"`
+	ExpireAt      *time.Time `json:"expire_at,omitempty"`
+	ScheduleState string     `json:"schedule_state,omitempty"`
+	// Status is the review status, see workflow.go for the transitions between them
+	Status         string          `json:"status,omitempty"`
+	ReviewComments []ReviewComment `json:"review_comments,omitempty"`
+	// Language is the BCP 47 tag of the language the title and content are written in, and
+	// Translations holds them in other languages, keyed by tag
+	Language     string                 `json:"language,omitempty" dynamodbav:"language,omitempty"`
+	Translations map[string]Translation `json:"translations,omitempty" dynamodbav:"translations,omitempty"`
+	// DisplayLanguage is the language the title and content were read in, and
+	// MissingTranslations the congregation's languages the notice is not available in,
+	// both filled in when the notice is read
+	DisplayLanguage     string   `json:"display_language,omitempty" dynamodbav:"-"`
+	MissingTranslations []string `json:"missing_translations,omitempty" dynamodbav:"-"`
+	// TTL is the DynamoDB time-to-live attribute, in Unix seconds
+	TTL int64 `json:"-" dynamodbav:"ttl,omitempty"`
+}
+
+// MarshalJSON encodes the creation and update times as Unix seconds
+func (n Notice) MarshalJSON() ([]byte, error) {
+	type Alias Notice
+	return json.Marshal(&struct {
+		Alias
+		CreatedAt int64 `json:"created_at"`
+		UpdatedAt int64 `json:"updated_at"`
+	}{
+		Alias:     Alias(n),
+		CreatedAt: n.CreatedAt.Unix(),
+		UpdatedAt: n.UpdatedAt.Unix(),
+	})
+}
+
+// UnmarshalJSON decodes the creation and update times from Unix seconds
+func (n *Notice) UnmarshalJSON(data []byte) error {
+	type Alias Notice
+	aux := &struct {
+		CreatedAt int64 `json:"created_at"`
+		UpdatedAt int64 `json:"updated_at"`
+		*Alias
+	}{
+		Alias: (*Alias)(n),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	n.CreatedAt = time.Unix(aux.CreatedAt, 0).UTC()
+	n.UpdatedAt = time.Unix(aux.UpdatedAt, 0).UTC()
+	return nil
+}
+
+// HasTag reports whether the notice carries the given tag
+func (n *Notice) HasTag(tag string) bool {
+	for _, t := range n.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTags trims, lower-cases, de-duplicates and sorts free-form tags
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// NewNoticeId returns a random positive notice ID that stays exact in JavaScript clients
+func NewNoticeId() (int, error) {
+	var b [8]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate notice id: %w", err)
+	}
+	return int(binary.BigEndian.Uint64(b[:])>>11) + 1, nil
+}
+
+// NoticeFilter selects the notices returned by ListNotices.
+// Ids takes precedence; CongregationId, Category and Tag can be combined.
+// An empty CongregationId lists the notices of every congregation.
+// Notices in the trash are only listed when Deleted is set, and then exclusively.
+type NoticeFilter struct {
+	Ids            []int
+	CongregationId string
+	Category       string
+	Tag            string
+	Deleted        bool
+}
+
+// ErrConflict is returned when a conditional write finds the notice changed by someone else
+var ErrConflict = errors.New("notice was changed concurrently")
+
+// ErrNotFound is returned when a notice to change does not exist, or is not in the expected place
+var ErrNotFound = errors.New("notice not found")
+
+// NoticeStore persists notices. Every change to a stored notice increments its version,
+// so that saving a notice read before the change fails with ErrConflict.
+type NoticeStore interface {
+	PutNotice(ctx context.Context, notice *Notice) error
+	// SaveNotice stores the notice as version expectedVersion+1 together with a revision,
+	// returning ErrConflict if the stored version is no longer expectedVersion
+	SaveNotice(ctx context.Context, notice *Notice, expectedVersion int, editedBy string) error
+	// ListRevisions returns the saved versions of a notice, newest first
+	ListRevisions(ctx context.Context, id int) ([]Revision, error)
+	// UpdateNotice writes only the given attributes of the notice, together with its
+	// version, update time and schedule, and returns the notice as stored. It returns
+	// ErrConflict if the stored version is no longer expectedVersion.
+	UpdateNotice(ctx context.Context, notice *Notice, attributes []string, expectedVersion int, editedBy string) (*Notice, error)
+	// UpdateAttachment replaces the attachment with the same ID. It returns ErrNotFound if
+	// the notice or attachment does not exist.
+	UpdateAttachment(ctx context.Context, noticeId int, attachment Attachment) error
+	// GetRevision returns nil without an error when the version does not exist
+	GetRevision(ctx context.Context, id, version int) (*Revision, error)
+	// GetNotice returns nil without an error when the notice does not exist
+	GetNotice(ctx context.Context, id int) (*Notice, error)
+	// DeleteNotice removes the notice and its revisions permanently
+	DeleteNotice(ctx context.Context, id int) error
+	// TrashNotice moves the notice to the trash, returning ErrNotFound if it does not exist or is already there
+	TrashNotice(ctx context.Context, id int, deletedBy string, at time.Time) error
+	// RestoreNotice takes the notice out of the trash, returning ErrNotFound if it is not there
+	RestoreNotice(ctx context.Context, id int) error
+	ListNotices(ctx context.Context, filter NoticeFilter) ([]Notice, error)
+	// UpdateScheduleState moves the notice from one schedule state to another,
+	// returning ErrConflict if its state is no longer from
+	UpdateScheduleState(ctx context.Context, id int, from, to string) error
+	// UpdateStatus moves the notice from one review status to another and records the
+	// review comment, returning ErrConflict if its status is no longer from
+	UpdateStatus(ctx context.Context, id int, from, to string, comment ReviewComment) error
+	// ReorderNotices gives the notices the positions 1, 2, 3... in the order of ids, all
+	// at once. It returns ErrConflict if any of them no longer exists in the given
+	// congregation and category.
+	ReorderNotices(ctx context.Context, congregationId, category string, ids []int) error
+}
+
+// updatedAttributes are written by every UpdateNotice besides the requested attributes
+var updatedAttributes = []string{"version", "updated_at", "schedule_state", "ttl"}
+
+// bumpVersion is added to the update expression of every other change to a notice, so that
+// SaveNotice and UpdateNotice of the version read before it fail with ErrConflict. Such
+// changes record no revision.
+const bumpVersion = "version = if_not_exists(version, :zero) + :one"
+
+// bumpValues adds the values bumpVersion refers to
+func bumpValues(values map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	values[":zero"] = &dynamodb.AttributeValue{N: aws.String("0")}
+	values[":one"] = &dynamodb.AttributeValue{N: aws.String("1")}
+	return values
+}
+
+// Notices is the store used by the notice handlers
+var Notices NoticeStore
+
+// DynamoNoticeStore keeps notices in the AWS_DYNAMO_TABLE_NAME table, keyed by Id.
+// Categories and congregations are served by global secondary indexes on category
+// and congregation_id, both sorted by created_at, and tags by a separate table
+// holding one item per tag and notice.
+// Revisions live in their own table, keyed by NoticeId and Version.
+type DynamoNoticeStore struct {
+	db                dynamodbiface.DynamoDBAPI
+	table             string
+	categoryIndex     string
+	congregationIndex string
+	tagTable          string
+	revisionTable     string
+}
+
+// NewDynamoNoticeStore creates a notice store for the configured tables
+func NewDynamoNoticeStore() *DynamoNoticeStore {
+	categoryIndex := os.Getenv("AWS_DYNAMO_CATEGORY_INDEX_NAME")
+	if categoryIndex == "" {
+		categoryIndex = "category-index"
+	}
+
+	congregationIndex := os.Getenv("AWS_DYNAMO_CONGREGATION_INDEX_NAME")
+	if congregationIndex == "" {
+		congregationIndex = "congregation-index"
+	}
+
+	return &DynamoNoticeStore{
+		db:                newDynamoClient(),
+		table:             os.Getenv("AWS_DYNAMO_TABLE_NAME"),
+		categoryIndex:     categoryIndex,
+		congregationIndex: congregationIndex,
+		tagTable:          os.Getenv("AWS_DYNAMO_TAG_TABLE_NAME"),
+		revisionTable:     os.Getenv("AWS_DYNAMO_REVISION_TABLE_NAME"),
+	}
+}
+
+// PutNotice stores the notice and brings its tag index entries up to date in one transaction
+func (s *DynamoNoticeStore) PutNotice(ctx context.Context, notice *Notice) error {
+	return s.writeNotice(ctx, notice, &dynamodb.Put{})
+}
+
+// SaveNotice stores the next version of the notice and its revision in one transaction
+func (s *DynamoNoticeStore) SaveNotice(ctx context.Context, notice *Notice, expectedVersion int, editedBy string) error {
+	notice.Version = expectedVersion + 1
+	revision, err := dynamodbattribute.MarshalMap(NewRevision(notice, editedBy))
+	if err != nil {
+		return fmt.Errorf("failed to store notice %d: %w", notice.ID, err)
+	}
+
+	// Notices stored before versioning have no version attribute
+	put := &dynamodb.Put{ConditionExpression: aws.String("attribute_not_exists(version)")}
+	if expectedVersion > 0 {
+		put.ConditionExpression = aws.String("version = :expected")
+		put.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":expected": {N: aws.String(strconv.Itoa(expectedVersion))},
+		}
+	}
+
+	err = s.writeNotice(ctx, notice, put, &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{TableName: aws.String(s.revisionTable), Item: revision},
+	})
+	if isConditionFailed(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+// writeNotice puts the notice with the given put options, updating its tag index
+// entries and writing any extra items in the same transaction
+func (s *DynamoNoticeStore) writeNotice(ctx context.Context, notice *Notice, put *dynamodb.Put, extra ...*dynamodb.TransactWriteItem) error {
+	previous, err := s.GetNotice(ctx, notice.ID)
+	if err != nil {
+		return err
+	}
+
+	av, err := dynamodbattribute.MarshalMap(notice)
+	if err != nil {
+		return fmt.Errorf("failed to store notice %d: %w", notice.ID, err)
+	}
+	put.TableName = aws.String(s.table)
+	put.Item = av
+
+	items := append([]*dynamodb.TransactWriteItem{{Put: put}}, extra...)
+	items = append(items, s.tagChanges(previous, notice)...)
+
+	_, err = s.db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store notice %d: %w", notice.ID, err)
+	}
+	return nil
+}
+
+// UpdateNotice writes the given attributes of the notice as its next version and returns
+// the notice as stored. Attributes missing from the notice are removed. The tag index
+// entries and the revision are written in the same transaction as the update.
+func (s *DynamoNoticeStore) UpdateNotice(ctx context.Context, notice *Notice, attributes []string, expectedVersion int, editedBy string) (*Notice, error) {
+	previous, err := s.getNotice(ctx, notice.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	if previous == nil || previous.Version != expectedVersion {
+		return nil, ErrConflict
+	}
+
+	notice.Version = expectedVersion + 1
+	av, err := dynamodbattribute.MarshalMap(notice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notice %d: %w", notice.ID, err)
+	}
+	// Every change to a notice increments its version, so the version condition makes
+	// sure the update applies to the notice just read and the revision is that notice
+	// with the attributes copied over
+	item, err := dynamodbattribute.MarshalMap(previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notice %d: %w", notice.ID, err)
+	}
+
+	// Build the update expression from the attributes' current values
+	names := map[string]*string{"#version": aws.String("version")}
+	values := map[string]*dynamodb.AttributeValue{}
+	var sets, removes []string
+	for i, attribute := range append(attributes, updatedAttributes...) {
+		name := fmt.Sprintf("#a%d", i)
+		names[name] = aws.String(attribute)
+		if value, ok := av[attribute]; ok && value.NULL == nil {
+			values[fmt.Sprintf(":a%d", i)] = value
+			sets = append(sets, fmt.Sprintf("%s = :a%d", name, i))
+			item[attribute] = value
+		} else {
+			removes = append(removes, name)
+			delete(item, attribute)
+		}
+	}
+	expression := "SET " + strings.Join(sets, ", ")
+	if len(removes) > 0 {
+		expression += " REMOVE " + strings.Join(removes, ", ")
+	}
+
+	// Notices stored before versioning have no version attribute
+	condition := "attribute_exists(Id) AND attribute_not_exists(#version)"
+	if expectedVersion > 0 {
+		condition = "#version = :expected"
+		values[":expected"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(expectedVersion))}
+	}
+
+	var updated Notice
+	err = dynamodbattribute.UnmarshalMap(item, &updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notice %d: %w", notice.ID, err)
+	}
+	revision, err := dynamodbattribute.MarshalMap(NewRevision(&updated, editedBy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notice %d: %w", notice.ID, err)
+	}
+
+	// Update the notice, record the revision and bring the tag index up to date at once
+	items := []*dynamodb.TransactWriteItem{
+		{Update: &dynamodb.Update{
+			TableName:                 aws.String(s.table),
+			Key:                       noticeKey(notice.ID),
+			ConditionExpression:       aws.String(condition),
+			UpdateExpression:          aws.String(expression),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}},
+		{Put: &dynamodb.Put{TableName: aws.String(s.revisionTable), Item: revision}},
+	}
+	items = append(items, s.tagChanges(previous, &updated)...)
+
+	_, err = s.db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if isConditionFailed(err) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notice %d: %w", notice.ID, err)
+	}
+
+	// Return the item as DynamoDB stored it
+	stored, err := s.getNotice(ctx, notice.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrNotFound
+	}
+	return stored, nil
+}
+
+// UpdateAttachment replaces one element of the attachments list, checking it still holds the same attachment
+func (s *DynamoNoticeStore) UpdateAttachment(ctx context.Context, noticeId int, attachment Attachment) error {
+	notice, err := s.GetNotice(ctx, noticeId)
+	if err != nil {
+		return err
+	}
+	index := attachmentIndex(notice, attachment.Id)
+	if index < 0 {
+		return ErrNotFound
+	}
+
+	value, err := dynamodbattribute.Marshal(attachment)
+	if err != nil {
+		return fmt.Errorf("failed to update attachment of notice %d: %w", noticeId, err)
+	}
+	_, err = s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.table),
+		Key:                 noticeKey(noticeId),
+		ConditionExpression: aws.String(fmt.Sprintf("attachments[%d].id = :id", index)),
+		UpdateExpression:    aws.String(fmt.Sprintf("SET attachments[%d] = :attachment, %s", index, bumpVersion)),
+		ExpressionAttributeValues: bumpValues(map[string]*dynamodb.AttributeValue{
+			":id":         {S: aws.String(attachment.Id)},
+			":attachment": value,
+		}),
+	})
+	if isConditionFailed(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update attachment of notice %d: %w", noticeId, err)
+	}
+	return nil
+}
+
+// tagChanges adds index entries for new tags and removes those for dropped tags
+func (s *DynamoNoticeStore) tagChanges(previous, notice *Notice) []*dynamodb.TransactWriteItem {
+	var items []*dynamodb.TransactWriteItem
+	for _, tag := range notice.Tags {
+		if previous == nil || !previous.HasTag(tag) {
+			items = append(items, &dynamodb.TransactWriteItem{
+				Put: &dynamodb.Put{TableName: aws.String(s.tagTable), Item: s.tagKey(tag, notice.ID)},
+			})
+		}
+	}
+	if previous != nil {
+		for _, tag := range previous.Tags {
+			if !notice.HasTag(tag) {
+				items = append(items, &dynamodb.TransactWriteItem{
+					Delete: &dynamodb.Delete{TableName: aws.String(s.tagTable), Key: s.tagKey(tag, notice.ID)},
+				})
+			}
+		}
+	}
+	return items
+}
+
+// ListRevisions returns the saved versions of a notice, newest first
+func (s *DynamoNoticeStore) ListRevisions(ctx context.Context, id int) ([]Revision, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.revisionTable),
+		KeyConditionExpression: aws.String("NoticeId = :id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":id": {N: aws.String(strconv.Itoa(id))},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	var revisions []Revision
+	var pageErr error
+	err := s.db.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageRevisions []Revision
+		pageErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageRevisions)
+		revisions = append(revisions, pageRevisions...)
+		return pageErr == nil
+	})
+	if err == nil {
+		err = pageErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions of notice %d: %w", id, err)
+	}
+	return revisions, nil
+}
+
+// GetRevision loads one version of a notice
+func (s *DynamoNoticeStore) GetRevision(ctx context.Context, id, version int) (*Revision, error) {
+	result, err := s.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.revisionTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"NoticeId": {N: aws.String(strconv.Itoa(id))},
+			"Version":  {N: aws.String(strconv.Itoa(version))},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision %d of notice %d: %w", version, id, err)
+	}
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var revision Revision
+	err = dynamodbattribute.UnmarshalMap(result.Item, &revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revision %d of notice %d: %w", version, id, err)
+	}
+	return &revision, nil
+}
+
+// GetNotice loads a notice by ID
+func (s *DynamoNoticeStore) GetNotice(ctx context.Context, id int) (*Notice, error) {
+	return s.getNotice(ctx, id, false)
+}
+
+// getNotice reads the notice, with a strongly consistent read when it has to reflect
+// the latest write
+func (s *DynamoNoticeStore) getNotice(ctx context.Context, id int, consistent bool) (*Notice, error) {
+	result, err := s.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            noticeKey(id),
+		ConsistentRead: aws.Bool(consistent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notice %d: %w", id, err)
+	}
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var notice Notice
+	err = dynamodbattribute.UnmarshalMap(result.Item, &notice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notice %d: %w", id, err)
+	}
+	return &notice, nil
+}
+
+// DeleteNotice removes the notice together with its tag index entries
+func (s *DynamoNoticeStore) DeleteNotice(ctx context.Context, id int) error {
+	previous, err := s.GetNotice(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	items := []*dynamodb.TransactWriteItem{
+		{Delete: &dynamodb.Delete{TableName: aws.String(s.table), Key: noticeKey(id)}},
+	}
+	if previous != nil {
+		for _, tag := range previous.Tags {
+			items = append(items, &dynamodb.TransactWriteItem{
+				Delete: &dynamodb.Delete{TableName: aws.String(s.tagTable), Key: s.tagKey(tag, id)},
+			})
+		}
+	}
+
+	_, err = s.db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete notice %d: %w", id, err)
+	}
+	return s.deleteRevisions(ctx, id)
+}
+
+// deleteRevisions removes every saved version of the notice
+func (s *DynamoNoticeStore) deleteRevisions(ctx context.Context, id int) error {
+	revisions, err := s.ListRevisions(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var requests []*dynamodb.WriteRequest
+	for _, revision := range revisions {
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{Key: map[string]*dynamodb.AttributeValue{
+				"NoticeId": {N: aws.String(strconv.Itoa(id))},
+				"Version":  {N: aws.String(strconv.Itoa(revision.Version))},
+			}},
+		})
+	}
+
+	// BatchWriteItem accepts at most 25 requests at a time
+	for len(requests) > 0 {
+		n := len(requests)
+		if n > 25 {
+			n = 25
+		}
+		result, err := s.db.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{s.revisionTable: requests[:n]},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete revisions of notice %d: %w", id, err)
+		}
+		requests = append(result.UnprocessedItems[s.revisionTable], requests[n:]...)
+	}
+	return nil
+}
+
+// TrashNotice marks the notice as deleted without removing it
+func (s *DynamoNoticeStore) TrashNotice(ctx context.Context, id int, deletedBy string, at time.Time) error {
+	deletedAt, err := dynamodbattribute.Marshal(at)
+	if err != nil {
+		return fmt.Errorf("failed to trash notice %d: %w", id, err)
+	}
+
+	// Notices that were never deleted hold a NULL deleted_at
+	_, err = s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.table),
+		Key:                 noticeKey(id),
+		ConditionExpression: aws.String("attribute_exists(Id) AND (attribute_not_exists(deleted_at) OR attribute_type(deleted_at, :null))"),
+		UpdateExpression:    aws.String("SET deleted_at = :at, deleted_by = :by, " + bumpVersion),
+		ExpressionAttributeValues: bumpValues(map[string]*dynamodb.AttributeValue{
+			":at":   deletedAt,
+			":by":   {S: aws.String(deletedBy)},
+			":null": {S: aws.String("NULL")},
+		}),
+	})
+	if isConditionFailed(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to trash notice %d: %w", id, err)
+	}
+	return nil
+}
+
+// RestoreNotice clears the deletion mark of a notice in the trash
+func (s *DynamoNoticeStore) RestoreNotice(ctx context.Context, id int) error {
+	_, err := s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.table),
+		Key:                 noticeKey(id),
+		ConditionExpression: aws.String("attribute_type(deleted_at, :string)"),
+		UpdateExpression:    aws.String("SET deleted_at = :null, " + bumpVersion + " REMOVE deleted_by"),
+		ExpressionAttributeValues: bumpValues(map[string]*dynamodb.AttributeValue{
+			":string": {S: aws.String(dynamodb.ScalarAttributeTypeS)},
+			":null":   {NULL: aws.Bool(true)},
+		}),
+	})
+	if isConditionFailed(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore notice %d: %w", id, err)
+	}
+	return nil
+}
+
+// ListNotices returns the notices matching the filter. Explicit IDs keep their
+// requested order; everything else is returned newest first.
+func (s *DynamoNoticeStore) ListNotices(ctx context.Context, filter NoticeFilter) ([]Notice, error) {
+	var notices []Notice
+	var err error
+
+	switch {
+	case len(filter.Ids) > 0:
+		return s.batchGet(ctx, filter.Ids)
+	case filter.Tag != "":
+		notices, err = s.listByTag(ctx, filter.Tag)
+	case filter.Category != "":
+		notices, err = s.queryNotices(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(s.table),
+			IndexName:                 aws.String(s.categoryIndex),
+			KeyConditionExpression:    aws.String("category = :category"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":category": {S: aws.String(filter.Category)}},
+			ScanIndexForward:          aws.Bool(false),
+		})
+	case filter.CongregationId != "":
+		notices, err = s.queryNotices(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(s.table),
+			IndexName:                 aws.String(s.congregationIndex),
+			KeyConditionExpression:    aws.String("congregation_id = :congregation"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":congregation": {S: aws.String(filter.CongregationId)}},
+			ScanIndexForward:          aws.Bool(false),
+		})
+	default:
+		notices, err = s.scanNotices(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	notices = filterNotices(notices, filter)
+	sortNoticesNewestFirst(notices)
+	return notices, nil
+}
+
+// UpdateScheduleState moves the notice from one schedule state to another
+func (s *DynamoNoticeStore) UpdateScheduleState(ctx context.Context, id int, from, to string) error {
+	condition := "attribute_exists(Id) AND schedule_state = :from"
+	values := map[string]*dynamodb.AttributeValue{
+		":to": {S: aws.String(to)},
+	}
+	if from == "" {
+		condition = "attribute_exists(Id) AND attribute_not_exists(schedule_state)"
+	} else {
+		values[":from"] = &dynamodb.AttributeValue{S: aws.String(from)}
+	}
+
+	_, err := s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.table),
+		Key:                       noticeKey(id),
+		ConditionExpression:       aws.String(condition),
+		UpdateExpression:          aws.String("SET schedule_state = :to, " + bumpVersion),
+		ExpressionAttributeValues: bumpValues(values),
+	})
+	if isConditionFailed(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update schedule state of notice %d: %w", id, err)
+	}
+	return nil
+}
+
+// UpdateStatus moves the notice from one review status to another and records the review comment
+func (s *DynamoNoticeStore) UpdateStatus(ctx context.Context, id int, from, to string, comment ReviewComment) error {
+	entry, err := dynamodbattribute.Marshal([]ReviewComment{comment})
+	if err != nil {
+		return fmt.Errorf("failed to update status of notice %d: %w", id, err)
+	}
+	updatedAt, err := dynamodbattribute.Marshal(comment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update status of notice %d: %w", id, err)
+	}
+
+	// status is a reserved word in DynamoDB expressions
+	condition := "attribute_exists(Id) AND #status = :from"
+	values := map[string]*dynamodb.AttributeValue{
+		":to":      {S: aws.String(to)},
+		":comment": entry,
+		":empty":   {L: []*dynamodb.AttributeValue{}},
+		":now":     updatedAt,
+	}
+	if from == "" {
+		condition = "attribute_exists(Id) AND attribute_not_exists(#status)"
+	} else {
+		values[":from"] = &dynamodb.AttributeValue{S: aws.String(from)}
+	}
+
+	_, err = s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.table),
+		Key:                       noticeKey(id),
+		ConditionExpression:       aws.String(condition),
+		UpdateExpression:          aws.String("SET #status = :to, updated_at = :now, review_comments = list_append(if_not_exists(review_comments, :empty), :comment), " + bumpVersion),
+		ExpressionAttributeNames:  map[string]*string{"#status": aws.String("status")},
+		ExpressionAttributeValues: bumpValues(values),
+	})
+	if isConditionFailed(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update status of notice %d: %w", id, err)
+	}
+	return nil
+}
+
+// ReorderNotices sets the positions of the notices in one transaction
+func (s *DynamoNoticeStore) ReorderNotices(ctx context.Context, congregationId, category string, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > MaxReorder {
+		return fmt.Errorf("cannot reorder more than %d notices at once", MaxReorder)
+	}
+
+	// Every notice must still be in the category, and never in another congregation
+	condition := "attribute_exists(Id) AND category = :category AND congregation_id = :congregation"
+	if congregationId == "" {
+		condition = "attribute_exists(Id) AND category = :category AND attribute_not_exists(congregation_id)"
+	}
+
+	items := make([]*dynamodb.TransactWriteItem, 0, len(ids))
+	for i, id := range ids {
+		values := map[string]*dynamodb.AttributeValue{
+			":category": {S: aws.String(category)},
+			":position": {N: aws.String(strconv.Itoa(i + 1))},
+		}
+		if congregationId != "" {
+			values[":congregation"] = &dynamodb.AttributeValue{S: aws.String(congregationId)}
+		}
+
+		// position is a reserved word in DynamoDB expressions
+		items = append(items, &dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				TableName:                 aws.String(s.table),
+				Key:                       noticeKey(id),
+				ConditionExpression:       aws.String(condition),
+				UpdateExpression:          aws.String("SET #position = :position, " + bumpVersion),
+				ExpressionAttributeNames:  map[string]*string{"#position": aws.String("position")},
+				ExpressionAttributeValues: bumpValues(values),
+			},
+		})
+	}
+
+	_, err := s.db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if isConditionFailed(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reorder notices of category %s: %w", category, err)
+	}
+	return nil
+}
+
+func (s *DynamoNoticeStore) listByTag(ctx context.Context, tag string) ([]Notice, error) {
+	var ids []int
+	var pageErr error
+	err := s.db.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tagTable),
+		KeyConditionExpression:    aws.String("Tag = :tag"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":tag": {S: aws.String(tag)}},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			var id int
+			id, pageErr = strconv.Atoi(aws.StringValue(item["NoticeId"].N))
+			if pageErr != nil {
+				return false
+			}
+			ids = append(ids, id)
+		}
+		return true
+	})
+	if err == nil {
+		err = pageErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notices tagged %q: %w", tag, err)
+	}
+
+	return s.batchGet(ctx, ids)
+}
+
+func (s *DynamoNoticeStore) batchGet(ctx context.Context, ids []int) ([]Notice, error) {
+	found := make(map[int]Notice)
+
+	// BatchGetItem accepts at most 100 keys per call
+	for start := 0; start < len(ids); start += 100 {
+		end := start + 100
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		keys := make([]map[string]*dynamodb.AttributeValue, 0, end-start)
+		seen := make(map[int]bool)
+		for _, id := range ids[start:end] {
+			if !seen[id] {
+				seen[id] = true
+				keys = append(keys, noticeKey(id))
+			}
+		}
+
+		request := map[string]*dynamodb.KeysAndAttributes{
+			s.table: {Keys: keys, ConsistentRead: aws.Bool(true)},
+		}
+		for len(request) > 0 {
+			result, err := s.db.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{RequestItems: request})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get notices: %w", err)
+			}
+
+			var page []Notice
+			err = dynamodbattribute.UnmarshalListOfMaps(result.Responses[s.table], &page)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read notices: %w", err)
+			}
+			for _, notice := range page {
+				found[notice.ID] = notice
+			}
+
+			// Retry whatever DynamoDB did not get to
+			request = result.UnprocessedKeys
+		}
+	}
+
+	notices := make([]Notice, 0, len(found))
+	for _, id := range ids {
+		if notice, ok := found[id]; ok {
+			notices = append(notices, notice)
+			delete(found, id)
+		}
+	}
+	return notices, nil
+}
+
+func (s *DynamoNoticeStore) queryNotices(ctx context.Context, input *dynamodb.QueryInput) ([]Notice, error) {
+	var notices []Notice
+	var pageErr error
+	err := s.db.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageNotices []Notice
+		pageErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageNotices)
+		if pageErr != nil {
+			return false
+		}
+		notices = append(notices, pageNotices...)
+		return true
+	})
+	if err == nil {
+		err = pageErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notices: %w", err)
+	}
+	return notices, nil
+}
+
+func (s *DynamoNoticeStore) scanNotices(ctx context.Context) ([]Notice, error) {
+	var notices []Notice
+	var pageErr error
+	err := s.db.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName: aws.String(s.table),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageNotices []Notice
+		pageErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageNotices)
+		if pageErr != nil {
+			return false
+		}
+		notices = append(notices, pageNotices...)
+		return true
+	})
+	if err == nil {
+		err = pageErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan notices: %w", err)
+	}
+	return notices, nil
+}
+
+func (s *DynamoNoticeStore) tagKey(tag string, id int) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Tag":      {S: aws.String(tag)},
+		"NoticeId": {N: aws.String(strconv.Itoa(id))},
+	}
+}
+
+func noticeKey(id int) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id": {N: aws.String(strconv.Itoa(id))},
+	}
+}
+
+// attachmentIndex returns the position of the attachment in the notice's list, or -1
+func attachmentIndex(notice *Notice, id string) int {
+	if notice == nil {
+		return -1
+	}
+	for i := range notice.Attachments {
+		if notice.Attachments[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// filterNotices applies the congregation, category and tag parts of a filter
+func filterNotices(notices []Notice, filter NoticeFilter) []Notice {
+	filtered := notices[:0]
+	for _, notice := range notices {
+		if filter.CongregationId != "" && notice.CongregationId != filter.CongregationId {
+			continue
+		}
+		if filter.Category != "" && notice.Category != filter.Category {
+			continue
+		}
+		if filter.Tag != "" && !notice.HasTag(filter.Tag) {
+			continue
+		}
+		if notice.IsDeleted() != filter.Deleted {
+			continue
+		}
+		filtered = append(filtered, notice)
+	}
+	return filtered
+}
+
+func sortNoticesNewestFirst(notices []Notice) {
+	sort.SliceStable(notices, func(i, j int) bool {
+		return notices[i].CreatedAt.After(notices[j].CreatedAt)
+	})
+}
+
+// MemoryNoticeStore is an in-memory NoticeStore for tests and local runs.
+// Notices are kept in their DynamoDB attribute form so tests exercise the same marshalling.
+type MemoryNoticeStore struct {
+	mu        sync.Mutex
+	items     map[int]map[string]*dynamodb.AttributeValue
+	revisions map[int][]Revision
+}
+
+// NewMemoryNoticeStore creates an empty in-memory notice store
+func NewMemoryNoticeStore() *MemoryNoticeStore {
+	return &MemoryNoticeStore{
+		items:     make(map[int]map[string]*dynamodb.AttributeValue),
+		revisions: make(map[int][]Revision),
+	}
+}
+
+// PutNotice stores the notice
+func (s *MemoryNoticeStore) PutNotice(ctx context.Context, notice *Notice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	av, err := dynamodbattribute.MarshalMap(notice)
+	if err != nil {
+		return fmt.Errorf("failed to store notice %d: %w", notice.ID, err)
+	}
+	s.items[notice.ID] = av
+	return nil
+}
+
+// SaveNotice stores the next version of the notice and its revision
+func (s *MemoryNoticeStore) SaveNotice(ctx context.Context, notice *Notice, expectedVersion int, editedBy string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.get(notice.ID)
+	if err != nil {
+		return err
+	}
+	currentVersion := 0
+	if current != nil {
+		currentVersion = current.Version
+	}
+	if currentVersion != expectedVersion {
+		return ErrConflict
+	}
+
+	notice.Version = expectedVersion + 1
+	av, err := dynamodbattribute.MarshalMap(notice)
+	if err != nil {
+		return fmt.Errorf("failed to store notice %d: %w", notice.ID, err)
+	}
+	s.items[notice.ID] = av
+	s.revisions[notice.ID] = append(s.revisions[notice.ID], NewRevision(notice, editedBy))
+	return nil
+}
+
+// UpdateNotice writes the given attributes of the notice as its next version
+func (s *MemoryNoticeStore) UpdateNotice(ctx context.Context, notice *Notice, attributes []string, expectedVersion int, editedBy string) (*Notice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.get(notice.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.Version != expectedVersion {
+		return nil, ErrConflict
+	}
+
+	notice.Version = expectedVersion + 1
+	av, err := dynamodbattribute.MarshalMap(notice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notice %d: %w", notice.ID, err)
+	}
+
+	// Copy the attributes onto the stored item, removing those the notice leaves out
+	item := make(map[string]*dynamodb.AttributeValue, len(s.items[notice.ID]))
+	for name, value := range s.items[notice.ID] {
+		item[name] = value
+	}
+	for _, attribute := range append(attributes, updatedAttributes...) {
+		if value, ok := av[attribute]; ok && value.NULL == nil {
+			item[attribute] = value
+		} else {
+			delete(item, attribute)
+		}
+	}
+	s.items[notice.ID] = item
+
+	updated, err := s.get(notice.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.revisions[notice.ID] = append(s.revisions[notice.ID], NewRevision(updated, editedBy))
+	return updated, nil
+}
+
+// UpdateAttachment replaces the attachment with the same ID
+func (s *MemoryNoticeStore) UpdateAttachment(ctx context.Context, noticeId int, attachment Attachment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notice, err := s.get(noticeId)
+	if err != nil {
+		return err
+	}
+	index := attachmentIndex(notice, attachment.Id)
+	if index < 0 {
+		return ErrNotFound
+	}
+
+	notice.Attachments[index] = attachment
+	notice.Version++
+	return s.put(notice)
+}
+
+// ListRevisions returns the saved versions of a notice, newest first
+func (s *MemoryNoticeStore) ListRevisions(ctx context.Context, id int) ([]Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revisions := make([]Revision, 0, len(s.revisions[id]))
+	for i := len(s.revisions[id]) - 1; i >= 0; i-- {
+		revisions = append(revisions, s.revisions[id][i])
+	}
+	return revisions, nil
+}
+
+// GetRevision loads one version of a notice
+func (s *MemoryNoticeStore) GetRevision(ctx context.Context, id, version int) (*Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, revision := range s.revisions[id] {
+		if revision.Version == version {
+			return &revision, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetNotice loads a notice by ID
+func (s *MemoryNoticeStore) GetNotice(ctx context.Context, id int) (*Notice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(id)
+}
+
+// DeleteNotice removes the notice
+func (s *MemoryNoticeStore) DeleteNotice(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, id)
+	delete(s.revisions, id)
+	return nil
+}
+
+// TrashNotice marks the notice as deleted without removing it
+func (s *MemoryNoticeStore) TrashNotice(ctx context.Context, id int, deletedBy string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notice, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	if notice == nil || notice.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	notice.DeletedAt = &at
+	notice.DeletedBy = deletedBy
+	notice.Version++
+	return s.put(notice)
+}
+
+// RestoreNotice clears the deletion mark of a notice in the trash
+func (s *MemoryNoticeStore) RestoreNotice(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notice, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	if notice == nil || notice.DeletedAt == nil {
+		return ErrNotFound
+	}
+
+	notice.DeletedAt = nil
+	notice.DeletedBy = ""
+	notice.Version++
+	return s.put(notice)
+}
+
+// ListNotices returns the notices matching the filter
+func (s *MemoryNoticeStore) ListNotices(ctx context.Context, filter NoticeFilter) ([]Notice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := filter.Ids
+	if len(ids) == 0 {
+		for id := range s.items {
+			ids = append(ids, id)
+		}
+	}
+
+	var notices []Notice
+	for _, id := range ids {
+		notice, err := s.get(id)
+		if err != nil {
+			return nil, err
+		}
+		if notice != nil {
+			notices = append(notices, *notice)
+		}
+	}
+	if len(filter.Ids) > 0 {
+		return notices, nil
+	}
+
+	notices = filterNotices(notices, filter)
+	sortNoticesNewestFirst(notices)
+	return notices, nil
+}
+
+// UpdateScheduleState moves the notice from one schedule state to another
+func (s *MemoryNoticeStore) UpdateScheduleState(ctx context.Context, id int, from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notice, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	if notice == nil || notice.ScheduleState != from {
+		return ErrConflict
+	}
+
+	notice.ScheduleState = to
+	notice.Version++
+	av, err := dynamodbattribute.MarshalMap(notice)
+	if err != nil {
+		return err
+	}
+	s.items[id] = av
+	return nil
+}
+
+// UpdateStatus moves the notice from one review status to another and records the review comment
+func (s *MemoryNoticeStore) UpdateStatus(ctx context.Context, id int, from, to string, comment ReviewComment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notice, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	if notice == nil || notice.Status != from {
+		return ErrConflict
+	}
+
+	notice.Status = to
+	notice.UpdatedAt = comment.CreatedAt
+	notice.ReviewComments = append(notice.ReviewComments, comment)
+	notice.Version++
+	av, err := dynamodbattribute.MarshalMap(notice)
+	if err != nil {
+		return err
+	}
+	s.items[id] = av
+	return nil
+}
+
+// ReorderNotices sets the positions of the notices, all or none of them
+func (s *MemoryNoticeStore) ReorderNotices(ctx context.Context, congregationId, category string, ids []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(ids) > MaxReorder {
+		return fmt.Errorf("cannot reorder more than %d notices at once", MaxReorder)
+	}
+
+	// Check every notice before changing any of them
+	notices := make([]*Notice, 0, len(ids))
+	for _, id := range ids {
+		notice, err := s.get(id)
+		if err != nil {
+			return err
+		}
+		if notice == nil || notice.Category != category || notice.CongregationId != congregationId {
+			return ErrConflict
+		}
+		notices = append(notices, notice)
+	}
+
+	for i, notice := range notices {
+		notice.Position = i + 1
+		notice.Version++
+		err := s.put(notice)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryNoticeStore) put(notice *Notice) error {
+	av, err := dynamodbattribute.MarshalMap(notice)
+	if err != nil {
+		return fmt.Errorf("failed to store notice %d: %w", notice.ID, err)
+	}
+	s.items[notice.ID] = av
+	return nil
+}
+
+func (s *MemoryNoticeStore) get(id int) (*Notice, error) {
+	av, ok := s.items[id]
+	if !ok {
+		return nil, nil
+	}
+
+	var notice Notice
+	err := dynamodbattribute.UnmarshalMap(av, &notice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notice %d: %w", id, err)
+	}
+	return &notice, nil
+}
@@ -0,0 +1,401 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+var (
+	noticeHTMLTemplate = htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/notice.html.tmpl"))
+	noticeTextTemplate = texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/notice.txt.tmpl"))
+)
+
+// NotificationSubscriber emails the members about new and urgent notices, texts them about
+// urgent ones and pushes new and updated notices to their devices, for the notice changes
+// it is handed by the stream consumer. This keeps the sending, and the SMS rate limit, out
+// of the API requests that change notices.
+type NotificationSubscriber struct{}
+
+// HandleNoticeChange notifies the members about the change, if it calls for it. Failures
+// are logged rather than returned: the change would be delivered again and everyone
+// notified twice, so members are notified at most once.
+func (NotificationSubscriber) HandleNoticeChange(ctx context.Context, change *NoticeChange) error {
+	event, ok := NoticeEventForChange(change)
+	if !ok {
+		return nil
+	}
+	notifiers := EventPublishers{EmailNotifier{}, NewSMSNotifier(), PushNotifier{}}
+	if err := notifiers.Publish(ctx, event); err != nil {
+		log.Printf("Failed to notify members of notice %d: %v", change.NoticeId, err)
+	}
+	return nil
+}
+
+// NoticeEventForChange returns the event the notifiers act on for a change read from the
+// stream: published when the notice becomes published, live when its schedule makes it
+// live, and updated when its content changes. Other changes, such as reviews, moves to the
+// trash, new positions and thumbnails, have none.
+func NoticeEventForChange(change *NoticeChange) (NoticeEvent, bool) {
+	before, after := change.Old, change.New
+	eventType := ""
+	switch {
+	case after == nil || after.IsDeleted() || change.Type == ChangeRestored:
+		return NoticeEvent{}, false
+	case change.Type == ChangePublished:
+		eventType = EventNoticePublished
+	case before == nil:
+		return NoticeEvent{}, false
+	case after.ScheduleState == ScheduleLive && before.ScheduleState != ScheduleLive:
+		eventType = EventNoticeLive
+	case len(DiffNotices(before, after)) > 0:
+		eventType = EventNoticeUpdated
+	default:
+		return NoticeEvent{}, false
+	}
+	return NoticeEvent{Type: eventType, NoticeId: after.ID, Notice: after, OccurredAt: change.OccurredAt}, true
+}
+
+// EmailNotifier emails subscribed members when a notice is published, and again when an
+// urgent notice is updated. Members hear about notices they can see on the board, in the
+// categories they subscribed to.
+type EmailNotifier struct{}
+
+// Publish emails the members about the notice, if the event calls for it
+func (EmailNotifier) Publish(ctx context.Context, event NoticeEvent) error {
+	now := time.Now()
+	notice := event.Notice
+	if notice == nil || !notifies(event, now) {
+		return nil
+	}
+
+	message, err := noticeEmail(ctx, notice, event.Type == EventNoticeUpdated)
+	if err != nil {
+		return err
+	}
+
+	members, err := recipients(ctx, notice, ChannelEmail, now)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range members {
+		if members[i].Email == "" {
+			continue
+		}
+		message.To = members[i].Email
+		if err := Mail.Send(ctx, message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recipients returns the members of the notice's congregation who want to hear about it
+// through the channel now and can see it on the board
+func recipients(ctx context.Context, notice *Notice, channel string, now time.Time) ([]UserPreferences, error) {
+	preferences, err := Preferences.ListPreferences(ctx, notice.CongregationId)
+	if err != nil {
+		return nil, err
+	}
+	var members []UserPreferences
+	for i := range preferences {
+		member := &preferences[i]
+		if member.Wants(channel, notice, now) && NoticeVisibleTo(member.Claims(), notice, now) {
+			members = append(members, *member)
+		}
+	}
+	return members, nil
+}
+
+// SMSNotifier texts members who enabled ChannelSMS and have a verified phone number when
+// an urgent notice is published or updated. Every message is kept in the audit log. Each
+// member gets at most Limit messages a day, and messages go out at most one per Interval.
+type SMSNotifier struct {
+	Limit    int
+	Interval time.Duration
+}
+
+// NewSMSNotifier creates a notifier sending at most SMS_DAILY_LIMIT (default 5) messages
+// a day to each member and SMS_PER_SECOND (default 10) messages a second
+func NewSMSNotifier() *SMSNotifier {
+	limit, err := strconv.Atoi(os.Getenv("SMS_DAILY_LIMIT"))
+	if err != nil || limit <= 0 {
+		limit = 5
+	}
+	rate, err := strconv.Atoi(os.Getenv("SMS_PER_SECOND"))
+	if err != nil || rate <= 0 {
+		rate = 10
+	}
+	return &SMSNotifier{Limit: limit, Interval: time.Second / time.Duration(rate)}
+}
+
+// Publish texts the members about the notice, if it is urgent and the event calls for it
+func (n *SMSNotifier) Publish(ctx context.Context, event NoticeEvent) error {
+	now := time.Now()
+	notice := event.Notice
+	if notice == nil || !notice.IsUrgent() || !notifies(event, now) {
+		return nil
+	}
+
+	board, err := boardName(ctx, notice)
+	if err != nil {
+		return err
+	}
+	body := SMSText(board, notice.Title, ShortLink(notice.ID))
+
+	members, err := recipients(ctx, notice, ChannelSMS, now)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	var last time.Time
+	for i := range members {
+		member := &members[i]
+		if member.PhoneNumber == "" {
+			continue
+		}
+		sent, err := SMSAudit.CountSMS(ctx, member.UserId, now.Add(-24*time.Hour))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Stay below the sending rate SNS allows, but stop once the caller's deadline
+		// would pass while waiting
+		if wait := n.Interval - time.Since(last); sent < n.Limit && wait > 0 {
+			if err := sleep(ctx, wait); err != nil {
+				errs = append(errs, fmt.Errorf("stopped texting notice %d with %d members left: %w", notice.ID, len(members)-i, err))
+				break
+			}
+		}
+		record := NewSMSRecord(member, notice.ID, time.Now())
+		if sent >= n.Limit {
+			record.Status = SMSLimited
+		} else {
+			last = time.Now()
+			record.Status = SMSSent
+			record.MessageId, err = SMS.Send(ctx, SMSMessage{To: member.PhoneNumber, Body: body})
+			if err != nil {
+				record.Status = SMSFailed
+				record.Error = err.Error()
+				errs = append(errs, err)
+			}
+		}
+		if err := SMSAudit.RecordSMS(ctx, record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sleep waits for d, returning early with an error when the context is done first or its
+// deadline comes before the wait is over
+func sleep(ctx context.Context, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return context.DeadlineExceeded
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// PushNotifier sends a Web Push message to every device of the members who enabled
+// ChannelPush when a notice is published, and again when it is updated. Subscriptions the
+// push service has dropped are removed.
+type PushNotifier struct{}
+
+// pushPayload is the message the web app's service worker shows as a notification
+type pushPayload struct {
+	Type     string `json:"type"`
+	NoticeId int    `json:"notice_id"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	Board    string `json:"board"`
+	Urgent   bool   `json:"urgent"`
+	// Tag lets the update of a notice replace its earlier notification
+	Tag string `json:"tag"`
+	URL string `json:"url,omitempty"`
+}
+
+// Publish pushes the notice to the members' devices, if the event calls for it
+func (PushNotifier) Publish(ctx context.Context, event NoticeEvent) error {
+	now := time.Now()
+	notice := event.Notice
+	if notice == nil {
+		return nil
+	}
+	updated := event.Type == EventNoticeUpdated
+	if updated {
+		// Any change to a notice on the board is pushed, not only to urgent ones
+		if notice.EffectiveStatus() != StatusPublished || notice.IsDeleted() || !notice.IsLiveAt(now) {
+			return nil
+		}
+	} else if !notifies(event, now) {
+		return nil
+	}
+
+	board, err := boardName(ctx, notice)
+	if err != nil {
+		return err
+	}
+	payload := pushPayload{
+		Type:     "published",
+		NoticeId: notice.ID,
+		Title:    notice.Title,
+		Body:     excerpt(notice.Content, 120),
+		Board:    board,
+		Urgent:   notice.IsUrgent(),
+		Tag:      fmt.Sprintf("notice-%d", notice.ID),
+	}
+	if updated {
+		payload.Type = "updated"
+	}
+	if base := noticeboardURL(); base != "" {
+		payload.URL = fmt.Sprintf("%s/notices/%d", base, notice.ID)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	message := PushMessage{Payload: body, TTL: 24 * time.Hour, Urgency: PushUrgencyNormal, Topic: payload.Tag}
+	if payload.Urgent {
+		message.Urgency = PushUrgencyHigh
+	}
+
+	members, err := recipients(ctx, notice, ChannelPush, now)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range members {
+		subscriptions, err := PushSubscriptions.ListPushSubscriptions(ctx, members[i].UserId)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for j := range subscriptions {
+			message.Subscription = &subscriptions[j]
+			err := Push.Send(ctx, message)
+			if errors.Is(err, ErrPushSubscriptionGone) {
+				log.Printf("Removing push subscription of %s: %v", members[i].UserId, err)
+				err = PushSubscriptions.DeletePushSubscription(ctx, members[i].UserId, subscriptions[j].Endpoint)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// excerpt returns the start of the text on one line, at most n characters long
+func excerpt(text string, n int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+// notifies reports whether members are told about the event. Notices are announced once,
+// when they are both published and inside their publishing window.
+func notifies(event NoticeEvent, now time.Time) bool {
+	notice := event.Notice
+	published := notice.EffectiveStatus() == StatusPublished && !notice.IsDeleted()
+	switch event.Type {
+	case EventNoticePublished:
+		return notice.IsLiveAt(now)
+	case EventNoticeLive:
+		return published
+	case EventNoticeUpdated:
+		return published && notice.IsUrgent() && notice.IsLiveAt(now)
+	}
+	return false
+}
+
+type noticeEmailData struct {
+	Subject        string
+	Board          string
+	Notice         *Notice
+	Content        htmltemplate.HTML
+	Urgent         bool
+	Updated        bool
+	NoticeURL      string
+	PreferencesURL string
+}
+
+// noticeEmail renders the email announcing the notice. Links point into the web app
+// at NOTICEBOARD_URL, when it is set.
+func noticeEmail(ctx context.Context, notice *Notice, updated bool) (EmailMessage, error) {
+	board, err := boardName(ctx, notice)
+	if err != nil {
+		return EmailMessage{}, err
+	}
+	data := noticeEmailData{
+		Board:   board,
+		Notice:  notice,
+		Content: htmltemplate.HTML(notice.ContentHTML),
+		Urgent:  notice.IsUrgent(),
+		Updated: updated,
+	}
+	if base := noticeboardURL(); base != "" {
+		data.NoticeURL = fmt.Sprintf("%s/notices/%d", base, notice.ID)
+		data.PreferencesURL = base + "/preferences"
+	}
+
+	data.Subject = fmt.Sprintf("[%s] %s", data.Board, notice.Title)
+	switch {
+	case data.Urgent && updated:
+		data.Subject = fmt.Sprintf("[%s] Urgent update: %s", data.Board, notice.Title)
+	case data.Urgent:
+		data.Subject = fmt.Sprintf("[%s] Urgent: %s", data.Board, notice.Title)
+	}
+
+	var html, text bytes.Buffer
+	if err := noticeHTMLTemplate.Execute(&html, data); err != nil {
+		return EmailMessage{}, fmt.Errorf("failed to render email for notice %d: %w", notice.ID, err)
+	}
+	if err := noticeTextTemplate.Execute(&text, data); err != nil {
+		return EmailMessage{}, fmt.Errorf("failed to render email for notice %d: %w", notice.ID, err)
+	}
+	return EmailMessage{Subject: data.Subject, HTML: html.String(), Text: text.String()}, nil
+}
+
+// boardName returns the name of the notice's congregation, as notifications are signed
+func boardName(ctx context.Context, notice *Notice) (string, error) {
+	if notice.CongregationId == "" {
+		return "Noticeboard", nil
+	}
+	congregation, err := Congregations.GetCongregation(ctx, notice.CongregationId)
+	if err != nil {
+		return "", err
+	}
+	if congregation == nil {
+		return "Noticeboard", nil
+	}
+	return congregation.Name, nil
+}
+
+// noticeboardURL returns the address of the web app emails link to, NOTICEBOARD_URL
+func noticeboardURL() string {
+	return strings.TrimSuffix(os.Getenv("NOTICEBOARD_URL"), "/")
+}
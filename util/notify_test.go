@@ -0,0 +1,301 @@
+package util
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailNotifier(t *testing.T) {
+	Congregations = NewMemoryCongregationStore()
+	Preferences = NewMemoryPreferencesStore()
+	mail := &MemoryMailer{}
+	Mail = mail
+	t.Setenv("NOTICEBOARD_URL", "https://board.example.org/")
+
+	ctx := context.Background()
+	err := Congregations.CreateCongregation(ctx, &Congregation{Id: "north", Name: "North"})
+	assert.NoError(t, err)
+	for _, preferences := range []*UserPreferences{
+		{UserId: "all", Email: "all@example.org", CongregationId: "north", Channels: []string{ChannelEmail}},
+		{UserId: "cleaning", Email: "cleaning@example.org", CongregationId: "north", Channels: []string{ChannelEmail}, Categories: []string{"cleaning"}},
+		{UserId: "meetings", Email: "meetings@example.org", CongregationId: "north", Channels: []string{ChannelEmail}, Categories: []string{"meetings"}},
+		{UserId: "quiet", Email: "quiet@example.org", CongregationId: "north"},
+		{UserId: "south", Email: "south@example.org", CongregationId: "south", Channels: []string{ChannelEmail}},
+	} {
+		err := Preferences.PutPreferences(ctx, preferences)
+		assert.NoError(t, err)
+	}
+
+	notice := &Notice{ID: 7, Title: "Cleaning <rota>", Content: "Group **2**", Category: "cleaning", CongregationId: "north", Status: StatusPublished}
+	notice.RenderContent()
+	notifier := EmailNotifier{}
+
+	// Published notices go to members subscribed to their category
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticePublished, notice))
+	assert.NoError(t, err)
+	sent := mail.Sent()
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "all@example.org", sent[0].To)
+	assert.Equal(t, "cleaning@example.org", sent[1].To)
+	assert.Equal(t, "[North] Cleaning <rota>", sent[0].Subject)
+	assert.Contains(t, sent[0].HTML, "<h1>Cleaning &lt;rota&gt;</h1>")
+	assert.Contains(t, sent[0].HTML, "<strong>2</strong>")
+	assert.Contains(t, sent[0].HTML, `href="https://board.example.org/notices/7"`)
+	assert.Contains(t, sent[0].Text, "Group **2**")
+	assert.Contains(t, sent[0].Text, "https://board.example.org/preferences")
+
+	// Updates only go out for urgent notices, to every subscriber
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticeUpdated, notice))
+	assert.NoError(t, err)
+	assert.Len(t, mail.Sent(), 2)
+
+	notice.Priority = PriorityUrgent
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticeUpdated, notice))
+	assert.NoError(t, err)
+	sent = mail.Sent()[2:]
+	assert.Len(t, sent, 3)
+	assert.Equal(t, "[North] Urgent update: Cleaning <rota>", sent[0].Subject)
+	assert.Contains(t, sent[0].Text, "An urgent notice was updated")
+
+	// Scheduled notices are announced when they go live, not when they are published
+	publishAt := time.Now().Add(time.Hour)
+	scheduled := &Notice{ID: 8, Title: "Later", CongregationId: "north", Status: StatusPublished, PublishAt: &publishAt}
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticePublished, scheduled))
+	assert.NoError(t, err)
+	assert.Len(t, mail.Sent(), 5)
+
+	// Notices going live while still under review wait for their publication
+	draft := &Notice{ID: 9, Title: "Draft", CongregationId: "north", Status: StatusSubmitted}
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticeLive, draft))
+	assert.NoError(t, err)
+	assert.Len(t, mail.Sent(), 5)
+}
+
+func TestEmailNotifier_QuietHours(t *testing.T) {
+	Congregations = NewMemoryCongregationStore()
+	Preferences = NewMemoryPreferencesStore()
+	mail := &MemoryMailer{}
+	Mail = mail
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	err := Preferences.PutPreferences(ctx, &UserPreferences{
+		UserId:         "sleeper",
+		Email:          "sleeper@example.org",
+		CongregationId: "north",
+		Channels:       []string{ChannelEmail},
+		QuietHours:     &QuietHours{Start: now.Add(-time.Hour).Format(QuietHoursLayout), End: now.Add(time.Hour).Format(QuietHoursLayout), TimeZone: "UTC"},
+	})
+	assert.NoError(t, err)
+	notifier := EmailNotifier{}
+
+	// Notices published during quiet hours are suppressed, not kept to send later
+	notice := &Notice{ID: 10, Title: "Cleaning", CongregationId: "north", Status: StatusPublished}
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticePublished, notice))
+	assert.NoError(t, err)
+	assert.Empty(t, mail.Sent())
+
+	// Only urgent notices get through
+	urgent := &Notice{ID: 11, Title: "Hall closed", CongregationId: "north", Status: StatusPublished, Priority: PriorityUrgent}
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticePublished, urgent))
+	assert.NoError(t, err)
+	sent := mail.Sent()
+	assert.Len(t, sent, 1)
+	assert.Contains(t, sent[0].Subject, "Hall closed")
+}
+
+func TestUserPreferences_Wants(t *testing.T) {
+	preferences := &UserPreferences{
+		Channels:   []string{ChannelEmail},
+		Categories: []string{"cleaning"},
+		QuietHours: &QuietHours{Start: "22:00", End: "07:00", TimeZone: "Europe/Amsterdam"},
+	}
+	cleaning := &Notice{Category: "cleaning"}
+	urgent := &Notice{Category: "meetings", Priority: PriorityUrgent}
+	day := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	night := time.Date(2024, 5, 6, 23, 30, 0, 0, time.UTC)
+	morning := time.Date(2024, 5, 7, 4, 59, 0, 0, time.UTC)
+
+	assert.True(t, preferences.Wants(ChannelEmail, cleaning, day))
+	assert.False(t, preferences.Wants(ChannelPush, cleaning, day))
+	assert.False(t, preferences.Wants(ChannelEmail, &Notice{Category: "meetings"}, day))
+
+	// Quiet hours span midnight, in the member's time zone; urgent notices still go out
+	assert.False(t, preferences.Wants(ChannelEmail, cleaning, night))
+	assert.False(t, preferences.Wants(ChannelEmail, cleaning, morning))
+	assert.True(t, preferences.Wants(ChannelEmail, cleaning, morning.Add(time.Minute)))
+	assert.True(t, preferences.Wants(ChannelEmail, urgent, night))
+}
+
+func TestSMSNotifier(t *testing.T) {
+	Congregations = NewMemoryCongregationStore()
+	Preferences = NewMemoryPreferencesStore()
+	sms := &MemorySMSSender{Fail: map[string]bool{"+31600000002": true}}
+	SMS = sms
+	audit := NewMemorySMSAuditStore()
+	SMSAudit = audit
+	t.Setenv("SHORT_LINK_URL", "https://nb.example.org/n/")
+
+	ctx := context.Background()
+	err := Congregations.CreateCongregation(ctx, &Congregation{Id: "north", Name: "North"})
+	assert.NoError(t, err)
+	quiet := &QuietHours{Start: "00:00", End: "23:59", TimeZone: "UTC"}
+	for _, preferences := range []*UserPreferences{
+		{UserId: "a", PhoneNumber: "+31600000001", CongregationId: "north", Channels: []string{ChannelSMS}, QuietHours: quiet},
+		{UserId: "b", PhoneNumber: "+31600000002", CongregationId: "north", Channels: []string{ChannelSMS}},
+		{UserId: "c", PhoneNumber: "+31600000003", CongregationId: "north", Channels: []string{ChannelSMS}},
+		{UserId: "email", PhoneNumber: "+31600000004", CongregationId: "north", Channels: []string{ChannelEmail}},
+		{UserId: "unverified", CongregationId: "north", Channels: []string{ChannelSMS}},
+	} {
+		err := Preferences.PutPreferences(ctx, preferences)
+		assert.NoError(t, err)
+	}
+
+	// Member c already had their texts for the day
+	for i := 0; i < 2; i++ {
+		record := NewSMSRecord(&UserPreferences{UserId: "c"}, 1, time.Now().Add(-time.Hour))
+		record.Status = SMSSent
+		err := audit.RecordSMS(ctx, record)
+		assert.NoError(t, err)
+	}
+	notifier := &SMSNotifier{Limit: 2}
+
+	// Only urgent notices are texted
+	notice := &Notice{ID: 100, Title: "Hall closed", CongregationId: "north", Status: StatusPublished}
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticePublished, notice))
+	assert.NoError(t, err)
+	assert.Empty(t, sms.Sent())
+
+	// Urgent notices ignore quiet hours; failures are audited and reported
+	notice.Priority = PriorityUrgent
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticePublished, notice))
+	assert.ErrorContains(t, err, "unreachable")
+	sent := sms.Sent()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "+31600000001", sent[0].To)
+	assert.Equal(t, "[North] URGENT: Hall closed https://nb.example.org/n/1C", sent[0].Body)
+
+	var statuses []string
+	for _, record := range audit.Records() {
+		if record.NoticeId == 100 {
+			statuses = append(statuses, record.UserId+" "+record.Status)
+		}
+	}
+	assert.Equal(t, []string{"a sent", "b failed", "c rate_limited"}, statuses)
+}
+
+func TestSMSNotifier_Deadline(t *testing.T) {
+	Congregations = NewMemoryCongregationStore()
+	Preferences = NewMemoryPreferencesStore()
+	sms := &MemorySMSSender{}
+	SMS = sms
+	audit := NewMemorySMSAuditStore()
+	SMSAudit = audit
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, phoneNumber := range []string{"+31600000001", "+31600000002", "+31600000003"} {
+		err := Preferences.PutPreferences(ctx, &UserPreferences{UserId: phoneNumber, PhoneNumber: phoneNumber, CongregationId: "north", Channels: []string{ChannelSMS}})
+		assert.NoError(t, err)
+	}
+
+	// Texts that would have to wait past the deadline are not sent
+	notifier := &SMSNotifier{Limit: 5, Interval: time.Hour}
+	notice := &Notice{ID: 101, Title: "Hall closed", CongregationId: "north", Status: StatusPublished, Priority: PriorityUrgent}
+	start := time.Now()
+	err := notifier.Publish(ctx, NewNoticeEvent(EventNoticePublished, notice))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "with 2 members left")
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Len(t, sms.Sent(), 1)
+	assert.Len(t, audit.Records(), 1)
+}
+
+func TestSMSText(t *testing.T) {
+	link := "https://nb.example.org/n/1C"
+	assert.Equal(t, "[North] URGENT: Hall closed "+link, SMSText("North", "Hall closed", link))
+
+	// Long titles are shortened to fit a single message
+	text := SMSText("North", strings.Repeat("Flooding in the hall ", 10), link)
+	length, limit := smsLength(text)
+	assert.Equal(t, 160, limit)
+	assert.LessOrEqual(t, length, 160)
+	assert.Contains(t, text, "... "+link)
+
+	// Text outside the GSM alphabet only has room for 70 characters
+	text = SMSText("North", strings.Repeat("Zaal gesloten ✝ ", 10), link)
+	length, limit = smsLength(text)
+	assert.Equal(t, 70, limit)
+	assert.LessOrEqual(t, length, 70)
+	assert.Contains(t, text, "… "+link)
+}
+
+func TestShortCode(t *testing.T) {
+	for _, id := range []int{1, 61, 62, 100, 1712345678} {
+		parsed, err := ParseShortCode(ShortCode(id))
+		assert.NoError(t, err)
+		assert.Equal(t, id, parsed)
+	}
+	assert.Equal(t, "1C", ShortCode(100))
+
+	_, err := ParseShortCode("a-b")
+	assert.Error(t, err)
+	_, err = ParseShortCode("")
+	assert.Error(t, err)
+}
+
+func TestNoticeEventForChange(t *testing.T) {
+	draft := &Notice{ID: 1, Title: "Cleaning", Status: StatusDraft}
+	approved := &Notice{ID: 1, Title: "Cleaning", Status: StatusApproved, ScheduleState: ScheduleScheduled}
+	scheduled := &Notice{ID: 1, Title: "Cleaning", Status: StatusPublished, ScheduleState: ScheduleScheduled}
+	live := &Notice{ID: 1, Title: "Cleaning", Status: StatusPublished, ScheduleState: ScheduleLive}
+	edited := &Notice{ID: 1, Title: "Cleaning rota", Status: StatusPublished, ScheduleState: ScheduleLive}
+	moved := &Notice{ID: 1, Title: "Cleaning", Status: StatusPublished, ScheduleState: ScheduleLive, Position: 2, Version: 5}
+	deletedAt := time.Now()
+	trashed := &Notice{ID: 1, Title: "Cleaning", Status: StatusPublished, ScheduleState: ScheduleLive, DeletedAt: &deletedAt}
+
+	for _, test := range []struct {
+		before, after *Notice
+		event         string
+	}{
+		{nil, draft, ""},
+		{approved, scheduled, EventNoticePublished},
+		{scheduled, live, EventNoticeLive},
+		{live, edited, EventNoticeUpdated},
+		{live, moved, ""},
+		{live, trashed, ""},
+		{trashed, live, ""},
+		{live, nil, ""},
+	} {
+		change := &NoticeChange{Type: ClassifyNoticeChange(test.before, test.after), Old: test.before, New: test.after}
+		event, ok := NoticeEventForChange(change)
+		assert.Equal(t, test.event != "", ok, test.event)
+		assert.Equal(t, test.event, event.Type)
+	}
+}
+
+func TestNotificationSubscriber(t *testing.T) {
+	Congregations = NewMemoryCongregationStore()
+	Preferences = NewMemoryPreferencesStore()
+	mail := &MemoryMailer{}
+	Mail = mail
+	Push = &MemoryPushSender{}
+	PushSubscriptions = NewMemoryPushSubscriptionStore()
+
+	ctx := context.Background()
+	err := Preferences.PutPreferences(ctx, &UserPreferences{UserId: "member", Email: "member@example.org", CongregationId: "north", Channels: []string{ChannelEmail}})
+	assert.NoError(t, err)
+
+	// Members hear about a notice once the stream shows it published
+	before := &Notice{ID: 12, Title: "Cleaning", CongregationId: "north", Status: StatusApproved}
+	after := &Notice{ID: 12, Title: "Cleaning", CongregationId: "north", Status: StatusPublished}
+	err = NotificationSubscriber{}.HandleNoticeChange(ctx, &NoticeChange{Type: ChangePublished, NoticeId: 12, Old: before, New: after})
+	assert.NoError(t, err)
+	sent := mail.Sent()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "member@example.org", sent[0].To)
+}
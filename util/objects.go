@@ -0,0 +1,228 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// ObjectStore keeps attachment files
+type ObjectStore interface {
+	// PresignPut returns a URL the client uploads the file to with a PUT request.
+	// The upload must use the given content type and size.
+	PresignPut(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error)
+	// PresignGet returns a URL the file can be downloaded from
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// DeleteObjects removes the files; keys that do not exist are ignored
+	DeleteObjects(ctx context.Context, keys []string) error
+	// GetObject reads a file
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	// PutObject writes a file
+	PutObject(ctx context.Context, key, contentType string, body []byte) error
+}
+
+// Objects is the store used for attachment files
+var Objects ObjectStore
+
+// S3ObjectStore keeps files in the AWS_S3_ATTACHMENT_BUCKET bucket.
+// AWS_S3_ENDPOINT can point it at an S3-compatible stand-in such as MinIO for development.
+type S3ObjectStore struct {
+	s3     s3iface.S3API
+	bucket string
+}
+
+// NewS3ObjectStore creates an object store for the configured bucket
+func NewS3ObjectStore() *S3ObjectStore {
+	config := &aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}
+	if endpoint := os.Getenv("AWS_S3_ENDPOINT"); endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	sess := session.Must(session.NewSession(config))
+	return &S3ObjectStore{
+		s3:     s3.New(sess),
+		bucket: os.Getenv("AWS_S3_ATTACHMENT_BUCKET"),
+	}
+}
+
+// PresignPut returns a presigned PUT URL limited to the content type and size
+func (s *S3ObjectStore) PresignPut(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error) {
+	req, _ := s.s3.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	})
+	req.SetContext(ctx)
+
+	link, err := req.Presign(ttl)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign upload of %s: %w", key, err)
+	}
+	return link, nil
+}
+
+// PresignGet returns a presigned GET URL
+func (s *S3ObjectStore) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, _ := s.s3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	req.SetContext(ctx)
+
+	link, err := req.Presign(ttl)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign download of %s: %w", key, err)
+	}
+	return link, nil
+}
+
+// DeleteObjects removes the files, at most 1000 per request
+func (s *S3ObjectStore) DeleteObjects(ctx context.Context, keys []string) error {
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > 1000 {
+			n = 1000
+		}
+
+		var objects []*s3.ObjectIdentifier
+		for _, key := range keys[:n] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+		result, err := s.s3.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete objects: %w", err)
+		}
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("failed to delete %s: %s", aws.StringValue(result.Errors[0].Key), aws.StringValue(result.Errors[0].Message))
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// GetObject reads a file
+func (s *S3ObjectStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	result, err := s.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	defer result.Body.Close()
+
+	body, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	return body, nil
+}
+
+// PutObject writes a file
+func (s *S3ObjectStore) PutObject(ctx context.Context, key, contentType string, body []byte) error {
+	_, err := s.s3.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        bytes.NewReader(body),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	return nil
+}
+
+// MemoryObjectStore keeps files in memory for tests. Its presigned URLs use the
+// memory:// scheme and are not meant to be fetched.
+type MemoryObjectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// NewMemoryObjectStore creates an empty in-memory object store
+func NewMemoryObjectStore() *MemoryObjectStore {
+	return &MemoryObjectStore{objects: make(map[string][]byte)}
+}
+
+// PresignPut returns a fake upload URL
+func (s *MemoryObjectStore) PresignPut(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error) {
+	query := url.Values{
+		"content-type":   {contentType},
+		"content-length": {fmt.Sprint(size)},
+		"expires":        {fmt.Sprint(int(ttl.Seconds()))},
+	}
+	return "memory://objects/" + key + "?method=PUT&" + query.Encode(), nil
+}
+
+// PresignGet returns a fake download URL
+func (s *MemoryObjectStore) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return fmt.Sprintf("memory://objects/%s?method=GET&expires=%d", key, int(ttl.Seconds())), nil
+}
+
+// DeleteObjects removes the files
+func (s *MemoryObjectStore) DeleteObjects(ctx context.Context, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.objects, key)
+	}
+	return nil
+}
+
+// GetObject reads a file
+func (s *MemoryObjectStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("failed to get object %s: no such key", key)
+	}
+	return body, nil
+}
+
+// PutObject writes a file
+func (s *MemoryObjectStore) PutObject(ctx context.Context, key, contentType string, body []byte) error {
+	s.Put(key, body)
+	return nil
+}
+
+// Put stores a file, standing in for a client upload
+func (s *MemoryObjectStore) Put(key string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[key] = body
+}
+
+// Keys returns the keys of the stored files in order
+func (s *MemoryObjectStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
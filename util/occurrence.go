@@ -0,0 +1,198 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Notice types. Notices without a type are plain notices.
+const (
+	NoticeTypeNotice = "notice"
+	NoticeTypeEvent  = "event"
+)
+
+// DateLayout is the format of calendar dates such as event exceptions
+const DateLayout = "2006-01-02"
+
+// EventDetails says when and where an event notice takes place. Recurring events
+// repeat by an RFC 5545 recurrence rule in the event's time zone, so a meeting
+// at 19:30 stays at 19:30 when daylight saving time starts or ends.
+type EventDetails struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	TimeZone string    `json:"time_zone,omitempty"`
+	Location string    `json:"location,omitempty"`
+	// RRule is a recurrence rule such as "FREQ=WEEKLY;BYDAY=TH", empty for one-off events
+	RRule string `json:"rrule,omitempty"`
+	// ExDates are the local dates on which a recurring event does not take place
+	ExDates []string `json:"exdates,omitempty"`
+	// Cancelled calls off the whole event, CancelledDates single occurrences. Unlike
+	// exceptions, cancelled occurrences are still listed, marked as cancelled.
+	Cancelled      bool     `json:"cancelled,omitempty"`
+	CancelledDates []string `json:"cancelled_dates,omitempty"`
+}
+
+// Occurrence is one time an event takes place
+type Occurrence struct {
+	NoticeId  int       `json:"notice_id"`
+	Title     string    `json:"title"`
+	Category  string    `json:"category,omitempty"`
+	Location  string    `json:"location,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Cancelled bool      `json:"cancelled,omitempty"`
+}
+
+// IsEvent reports whether the notice is an event
+func (n *Notice) IsEvent() bool {
+	return n.Type == NoticeTypeEvent && n.Event != nil
+}
+
+// Zone returns the event's time zone, or UTC when it has none
+func (e *EventDetails) Zone() *time.Location {
+	location, err := time.LoadLocation(e.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Recurrence returns the event's parsed recurrence rule, or nil for one-off events
+func (e *EventDetails) Recurrence() (*Recurrence, error) {
+	if e.RRule == "" {
+		return nil, nil
+	}
+	return ParseRecurrence(e.RRule, e.Zone())
+}
+
+// Occurrences returns the times the event notice takes place that overlap the range
+// from..to, in order. Dates listed as exceptions are left out.
+func (n *Notice) Occurrences(from, to time.Time) ([]Occurrence, error) {
+	if !n.IsEvent() {
+		return nil, nil
+	}
+	event := n.Event
+	rule, err := event.Recurrence()
+	if err != nil {
+		return nil, err
+	}
+
+	start := event.Start.In(event.Zone())
+	duration := event.End.Sub(event.Start)
+	starts := []time.Time{start}
+	if rule != nil {
+		starts = rule.Starts(start, to)
+	}
+
+	exceptions := dateSet(event.ExDates)
+	cancelled := dateSet(event.CancelledDates)
+
+	var occurrences []Occurrence
+	for _, s := range starts {
+		end := s.Add(duration)
+		// Keep the occurrences overlapping the range; those without a duration must start in it
+		overlaps := end.After(from) || (duration == 0 && !s.Before(from))
+		if !s.Before(to) || !overlaps {
+			continue
+		}
+		date := s.Format(DateLayout)
+		if exceptions[date] {
+			continue
+		}
+		occurrences = append(occurrences, Occurrence{
+			NoticeId:  n.ID,
+			Title:     n.Title,
+			Category:  n.Category,
+			Location:  event.Location,
+			Start:     s,
+			End:       end,
+			Cancelled: event.Cancelled || cancelled[date],
+		})
+	}
+	return occurrences, nil
+}
+
+func dateSet(dates []string) map[string]bool {
+	set := make(map[string]bool, len(dates))
+	for _, date := range dates {
+		set[date] = true
+	}
+	return set
+}
+
+// SortOccurrences orders occurrences by their start time
+func SortOccurrences(occurrences []Occurrence) {
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+}
+
+// validateEvent checks the type and event details of a notice. Events without a time
+// zone get the one of the caller's congregation.
+func validateEvent(ctx context.Context, notice *Notice) error {
+	switch notice.Type {
+	case "", NoticeTypeNotice:
+		if notice.Event != nil {
+			return &ValidationError{Message: "event is only allowed on event notices"}
+		}
+		return nil
+	case NoticeTypeEvent:
+	default:
+		return &ValidationError{Message: "type must be notice or event"}
+	}
+
+	event := notice.Event
+	if event == nil || event.Start.IsZero() {
+		return &ValidationError{Message: "event start is required"}
+	}
+	if event.End.IsZero() {
+		event.End = event.Start
+	}
+	if event.End.Before(event.Start) {
+		return &ValidationError{Message: "event end must not be before its start"}
+	}
+
+	if event.TimeZone == "" {
+		zone, err := congregationTimeZone(ctx)
+		if err != nil {
+			return err
+		}
+		event.TimeZone = zone
+	}
+	if _, err := time.LoadLocation(event.TimeZone); err != nil {
+		return &ValidationError{Message: fmt.Sprintf("Unknown time zone: %s", event.TimeZone)}
+	}
+
+	if _, err := event.Recurrence(); err != nil {
+		return &ValidationError{Message: fmt.Sprintf("Invalid rrule: %s", err)}
+	}
+	for _, date := range event.ExDates {
+		if _, err := time.Parse(DateLayout, date); err != nil {
+			return &ValidationError{Message: "exdates must be dates like 2024-12-25"}
+		}
+	}
+	for _, date := range event.CancelledDates {
+		if _, err := time.Parse(DateLayout, date); err != nil {
+			return &ValidationError{Message: "cancelled_dates must be dates like 2024-12-25"}
+		}
+	}
+	return nil
+}
+
+// congregationTimeZone returns the time zone of the caller's congregation, or UTC
+func congregationTimeZone(ctx context.Context) (string, error) {
+	claims, _ := SessionFromContext(ctx)
+	if claims == nil || claims.CongregationId == "" {
+		return "UTC", nil
+	}
+	congregation, err := Congregations.GetCongregation(ctx, claims.CongregationId)
+	if err != nil {
+		return "", fmt.Errorf("failed to load congregation: %w", err)
+	}
+	if congregation == nil {
+		return "UTC", nil
+	}
+	return congregation.Location().String(), nil
+}
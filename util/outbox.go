@@ -0,0 +1,321 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// OutboxRecord is a domain event waiting to be delivered
+type OutboxRecord struct {
+	Id        string      `json:"id" dynamodbav:"Id"`
+	Event     DomainEvent `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Attempts  int         `json:"attempts"`
+	LastError string      `json:"last_error,omitempty"`
+	// FailedAt is set once delivery has been given up, leaving the record as a dead letter
+	FailedAt *time.Time `json:"failed_at,omitempty"`
+}
+
+// OutboxStore keeps the domain events that have not been delivered yet
+type OutboxStore interface {
+	AddToOutbox(ctx context.Context, records []OutboxRecord) error
+	RemoveFromOutbox(ctx context.Context, id string) error
+	// RecordOutboxFailure counts a failed attempt to deliver the event
+	RecordOutboxFailure(ctx context.Context, id string, cause error) error
+	// FailOutbox gives up delivering the event, keeping it in the outbox as a dead letter
+	FailOutbox(ctx context.Context, id string, at time.Time) error
+	// ListOutbox returns the events in the outbox, dead letters included, oldest first
+	ListOutbox(ctx context.Context) ([]OutboxRecord, error)
+}
+
+// Outbox is the store holding undelivered domain events
+var Outbox OutboxStore
+
+// PublishDomainEvents delivers the events through the outbox: they are stored first, so
+// that events the bus does not take stay behind for RelayOutbox to deliver later. An
+// error means the events could not even be stored, and are lost unless the caller retries.
+func PublishDomainEvents(ctx context.Context, events ...*DomainEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	records := make([]OutboxRecord, 0, len(events))
+	for _, event := range events {
+		records = append(records, OutboxRecord{Id: event.Id, Event: *event, CreatedAt: now})
+	}
+	err := Outbox.AddToOutbox(ctx, records)
+	if err != nil {
+		return err
+	}
+
+	deliverOutbox(ctx, records)
+	return nil
+}
+
+// OutboxResult summarises one run of the outbox relay
+type OutboxResult struct {
+	Pending int `json:"pending"`
+	Sent    int `json:"sent"`
+	Failed  int `json:"failed"`
+	// DeadLetters counts the events given up on, which wait for someone to look into them
+	DeadLetters int `json:"dead_letters"`
+}
+
+// outboxGrace is how long the relay leaves new events to the publisher that stored them
+const outboxGrace = time.Minute
+
+// maxOutboxAttempts is how often delivery of an event is tried before it is given up
+const maxOutboxAttempts = 10
+
+// RelayOutbox delivers the events left in the outbox
+func RelayOutbox(ctx context.Context, now time.Time) (OutboxResult, error) {
+	var result OutboxResult
+	records, err := Outbox.ListOutbox(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	var due []OutboxRecord
+	for _, record := range records {
+		switch {
+		case record.FailedAt != nil:
+			result.DeadLetters++
+		case record.CreatedAt.Before(now.Add(-outboxGrace)):
+			due = append(due, record)
+		}
+	}
+	result.Pending = len(due)
+	result.Sent, result.Failed = deliverOutbox(ctx, due)
+	return result, nil
+}
+
+// deliverOutbox puts the events on the bus, removing those delivered from the outbox and
+// counting a failed attempt for the others, which are given up after maxOutboxAttempts.
+// It returns the numbers sent and failed.
+func deliverOutbox(ctx context.Context, records []OutboxRecord) (int, int) {
+	if len(records) == 0 {
+		return 0, 0
+	}
+	events := make([]*DomainEvent, 0, len(records))
+	for i := range records {
+		events = append(events, &records[i].Event)
+	}
+
+	failed := Bus.PutEvents(ctx, events)
+	for _, record := range records {
+		if cause, ok := failed[record.Id]; ok {
+			log.Printf("Left %s event %s in the outbox: %v", record.Event.Type, record.Id, cause)
+			if err := Outbox.RecordOutboxFailure(ctx, record.Id, cause); err != nil {
+				log.Printf("Failed to record outbox failure of %s: %v", record.Id, err)
+			}
+			if record.Attempts+1 >= maxOutboxAttempts {
+				log.Printf("Gave up on %s event %s after %d attempts", record.Event.Type, record.Id, record.Attempts+1)
+				if err := Outbox.FailOutbox(ctx, record.Id, time.Now().UTC()); err != nil {
+					log.Printf("Failed to move %s aside in the outbox: %v", record.Id, err)
+				}
+			}
+			continue
+		}
+		// A record that stays behind is delivered again, and consumers see it twice
+		if err := Outbox.RemoveFromOutbox(ctx, record.Id); err != nil {
+			log.Printf("Failed to remove %s from the outbox: %v", record.Id, err)
+		}
+	}
+	return len(records) - len(failed), len(failed)
+}
+
+// DynamoOutboxStore keeps the outbox in the AWS_DYNAMO_OUTBOX_TABLE_NAME table, keyed by Id
+type DynamoOutboxStore struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+// NewDynamoOutboxStore creates an outbox for the configured table
+func NewDynamoOutboxStore() *DynamoOutboxStore {
+	return &DynamoOutboxStore{
+		db:    newDynamoClient(),
+		table: os.Getenv("AWS_DYNAMO_OUTBOX_TABLE_NAME"),
+	}
+}
+
+// AddToOutbox stores the events
+func (s *DynamoOutboxStore) AddToOutbox(ctx context.Context, records []OutboxRecord) error {
+	for i := range records {
+		av, err := dynamodbattribute.MarshalMap(&records[i])
+		if err != nil {
+			return fmt.Errorf("failed to add %s to the outbox: %w", records[i].Id, err)
+		}
+		_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(s.table),
+			Item:      av,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add %s to the outbox: %w", records[i].Id, err)
+		}
+	}
+	return nil
+}
+
+// RemoveFromOutbox removes a delivered event
+func (s *DynamoOutboxStore) RemoveFromOutbox(ctx context.Context, id string) error {
+	_, err := s.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from the outbox: %w", id, err)
+	}
+	return nil
+}
+
+// RecordOutboxFailure counts a failed attempt to deliver the event
+func (s *DynamoOutboxStore) RecordOutboxFailure(ctx context.Context, id string, cause error) error {
+	_, err := s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(id)},
+		},
+		UpdateExpression:    aws.String("ADD attempts :one SET last_error = :error"),
+		ConditionExpression: aws.String("attribute_exists(Id)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one":   {N: aws.String("1")},
+			":error": {S: aws.String(cause.Error())},
+		},
+	})
+	if err != nil && !isConditionFailed(err) {
+		return fmt.Errorf("failed to update %s in the outbox: %w", id, err)
+	}
+	return nil
+}
+
+// FailOutbox gives up delivering the event, keeping it in the outbox as a dead letter
+func (s *DynamoOutboxStore) FailOutbox(ctx context.Context, id string, at time.Time) error {
+	_, err := s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(id)},
+		},
+		UpdateExpression:    aws.String("SET failed_at = :at"),
+		ConditionExpression: aws.String("attribute_exists(Id)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":at": {S: aws.String(at.Format(time.RFC3339Nano))},
+		},
+	})
+	if err != nil && !isConditionFailed(err) {
+		return fmt.Errorf("failed to update %s in the outbox: %w", id, err)
+	}
+	return nil
+}
+
+// ListOutbox returns the events in the outbox, dead letters included, oldest first
+func (s *DynamoOutboxStore) ListOutbox(ctx context.Context) ([]OutboxRecord, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	err := s.db.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName: aws.String(s.table),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the outbox: %w", err)
+	}
+
+	var records []OutboxRecord
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the outbox: %w", err)
+	}
+	sortOutbox(records)
+	return records, nil
+}
+
+func sortOutbox(records []OutboxRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+}
+
+// MemoryOutboxStore is an in-memory OutboxStore for tests and local runs
+type MemoryOutboxStore struct {
+	mu      sync.Mutex
+	records map[string]OutboxRecord
+}
+
+// NewMemoryOutboxStore creates an empty in-memory outbox
+func NewMemoryOutboxStore() *MemoryOutboxStore {
+	return &MemoryOutboxStore{records: make(map[string]OutboxRecord)}
+}
+
+// AddToOutbox stores the events
+func (s *MemoryOutboxStore) AddToOutbox(ctx context.Context, records []OutboxRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		s.records[record.Id] = record
+	}
+	return nil
+}
+
+// RemoveFromOutbox removes a delivered event
+func (s *MemoryOutboxStore) RemoveFromOutbox(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, id)
+	return nil
+}
+
+// RecordOutboxFailure counts a failed attempt to deliver the event
+func (s *MemoryOutboxStore) RecordOutboxFailure(ctx context.Context, id string, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[id]; ok {
+		record.Attempts++
+		record.LastError = cause.Error()
+		s.records[id] = record
+	}
+	return nil
+}
+
+// FailOutbox gives up delivering the event, keeping it in the outbox as a dead letter
+func (s *MemoryOutboxStore) FailOutbox(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[id]; ok {
+		record.FailedAt = &at
+		s.records[id] = record
+	}
+	return nil
+}
+
+// ListOutbox returns the events in the outbox, dead letters included, oldest first
+func (s *MemoryOutboxStore) ListOutbox(ctx context.Context) ([]OutboxRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]OutboxRecord, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Id < records[j].Id
+	})
+	sortOutbox(records)
+	return records, nil
+}
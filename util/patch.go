@@ -0,0 +1,147 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// patchableFields are the notice fields a PATCH may change. Their JSON names match
+// the DynamoDB attribute names.
+var patchableFields = map[string]bool{
+	"title":        true,
+	"content":      true,
+	"category":     true,
+	"tags":         true,
+	"publish_at":   true,
+	"expire_at":    true,
+	"pinned":       true,
+	"priority":     true,
+	"type":         true,
+	"event":        true,
+	"language":     true,
+	"translations": true,
+}
+
+// immutableFields never change once a notice exists
+var immutableFields = map[string]bool{
+	"id":         true,
+	"Id":         true,
+	"author":     true,
+	"author_id":  true,
+	"created_at": true,
+	// Notices never move between congregations
+	"congregation_id": true,
+}
+
+// ApplyNoticePatch applies a JSON merge patch (RFC 7396) to a copy of the notice. Objects
+// such as event and translations are merged member by member.
+// It returns the patched notice and the attributes the patch touched. A version in
+// the patch is not applied; callers use it as a precondition.
+// Patches that cannot be applied are reported as a *ValidationError.
+func ApplyNoticePatch(notice *Notice, patch []byte) (*Notice, []string, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(patch, &fields)
+	if err != nil || fields == nil {
+		return nil, nil, &ValidationError{Message: "The patch must be a JSON object"}
+	}
+
+	// Start from the notice as clients see it
+	current, err := json.Marshal(notice)
+	if err != nil {
+		return nil, nil, err
+	}
+	var merged map[string]json.RawMessage
+	err = json.Unmarshal(current, &merged)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var attributes []string
+	for name, value := range fields {
+		switch {
+		case name == "version":
+			continue
+		case immutableFields[name]:
+			return nil, nil, &ValidationError{Message: fmt.Sprintf("%s cannot be changed", name)}
+		case !patchableFields[name]:
+			return nil, nil, &ValidationError{Message: fmt.Sprintf("%s cannot be changed with a patch", name)}
+		}
+
+		// null removes a field
+		if isNull(value) {
+			delete(merged, name)
+		} else {
+			merged[name], err = mergePatch(merged[name], value)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		attributes = append(attributes, name)
+	}
+
+	// The rendered HTML follows the Markdown source
+	if fields["content"] != nil {
+		attributes = append(attributes, "content_html")
+	}
+	sort.Strings(attributes)
+
+	body, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	var patched Notice
+	err = json.Unmarshal(body, &patched)
+	if err != nil {
+		return nil, nil, &ValidationError{Message: "Invalid request payload"}
+	}
+
+	// Fields hidden from JSON are carried over as they are
+	patched.TTL = notice.TTL
+
+	if fields["content"] != nil || fields["translations"] != nil {
+		patched.RenderContent()
+	}
+	return &patched, attributes, nil
+}
+
+// mergePatch merges the patch into the target as RFC 7396 describes: an object patch
+// changes the members it names and keeps the others, with null removing a member, and
+// anything else replaces the target
+func mergePatch(target, patch json.RawMessage) (json.RawMessage, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return patch, nil
+	}
+
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(target, &merged); err != nil || merged == nil {
+		merged = make(map[string]json.RawMessage)
+	}
+	for name, value := range members {
+		if isNull(value) {
+			delete(merged, name)
+			continue
+		}
+		value, err := mergePatch(merged[name], value)
+		if err != nil {
+			return nil, err
+		}
+		merged[name] = value
+	}
+	return json.Marshal(merged)
+}
+
+func isNull(value json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
+
+// PatchVersion returns the version a patch expects the notice to have, or 0 if it does not say
+func PatchVersion(patch []byte) int {
+	var fields struct {
+		Version int `json:"version"`
+	}
+	_ = json.Unmarshal(patch, &fields)
+	return fields.Version
+}
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyNoticePatch_Event(t *testing.T) {
+	start := time.Date(2024, 5, 2, 19, 30, 0, 0, time.UTC)
+	notice := &Notice{ID: 1, Title: "Meeting", Type: NoticeTypeEvent, Event: &EventDetails{
+		Start:    start,
+		End:      start.Add(time.Hour),
+		TimeZone: "Europe/Amsterdam",
+		Location: "Kingdom Hall",
+		RRule:    "FREQ=WEEKLY;BYDAY=TH",
+	}}
+
+	// Only the named members of the event change
+	patched, attributes, err := ApplyNoticePatch(notice, []byte(`{"event": {"location": "Assembly Hall", "rrule": null}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"event"}, attributes)
+	assert.Equal(t, &EventDetails{
+		Start:    start,
+		End:      start.Add(time.Hour),
+		TimeZone: "Europe/Amsterdam",
+		Location: "Assembly Hall",
+	}, patched.Event)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=TH", notice.Event.RRule)
+
+	// null still removes the whole event
+	patched, _, err = ApplyNoticePatch(notice, []byte(`{"event": null}`))
+	require.NoError(t, err)
+	assert.Nil(t, patched.Event)
+}
+
+func TestApplyNoticePatch_Translations(t *testing.T) {
+	notice := &Notice{ID: 1, Title: "Cleaning", Content: "Group 2", Language: "en", Translations: map[string]Translation{
+		"nl": {Title: "Schoonmaak", Content: "Groep 2"},
+		"fr": {Title: "Nettoyage"},
+	}}
+	notice.RenderContent()
+
+	// Translations are merged by language, and their members one by one
+	patched, _, err := ApplyNoticePatch(notice, []byte(`{"translations": {"fr": null, "nl": {"content": "Groep **3**"}, "de": {"title": "Reinigung"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Translation{
+		"nl": {Title: "Schoonmaak", Content: "Groep **3**", ContentHTML: "<p>Groep <strong>3</strong></p>\n"},
+		"de": {Title: "Reinigung"},
+	}, patched.Translations)
+	assert.Len(t, notice.Translations, 2)
+}
@@ -0,0 +1,407 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Notification channels
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelPush  = "push"
+)
+
+// Channels lists the notification channels members can enable
+var Channels = []string{ChannelEmail, ChannelSMS, ChannelPush}
+
+// Digest frequencies
+const (
+	DigestNone   = "none"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// QuietHoursLayout is the layout of the start and end of quiet hours
+const QuietHoursLayout = "15:04"
+
+// ErrPreferencesExist is returned when creating preferences for a user who already has them
+var ErrPreferencesExist = errors.New("preferences already exist")
+
+// UserPreferences says how a member wants to hear about notices. They are keyed by the
+// member's Cognito sub. The addresses, congregation and groups are copied from the member's
+// session, so notifications can be sent without it; PhoneNumber is only set once verified.
+type UserPreferences struct {
+	UserId         string   `json:"user_id" dynamodbav:"UserId"`
+	Email          string   `json:"email,omitempty"`
+	PhoneNumber    string   `json:"phone_number,omitempty"`
+	CongregationId string   `json:"congregation_id,omitempty" dynamodbav:"congregation_id,omitempty"`
+	Groups         []string `json:"groups,omitempty"`
+	// Channels are the enabled notification channels, such as ChannelEmail
+	Channels []string `json:"channels"`
+	// Categories limits notifications to notices in these categories, empty for all
+	Categories []string `json:"categories"`
+	// Digest is how often the member gets a summary email, DigestNone when empty
+	Digest string `json:"digest"`
+	// QuietHours suppress notifications of all but urgent notices. They are not sent
+	// later; members who want to catch up choose a Digest.
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// QuietHours is a daily period, such as 22:00 to 07:00, in which the member does not
+// want to be disturbed
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TimeZone string `json:"time_zone"`
+}
+
+// DefaultPreferences returns the preferences new members start with: emails about
+// notices in every category and no digest
+func DefaultPreferences(userId, email, congregationId string) *UserPreferences {
+	return &UserPreferences{
+		UserId:         userId,
+		Email:          email,
+		CongregationId: congregationId,
+		Channels:       []string{ChannelEmail},
+		Categories:     []string{},
+		Digest:         DigestNone,
+		UpdatedAt:      time.Now().UTC(),
+	}
+}
+
+// HasChannel reports whether the member enabled the notification channel
+func (p *UserPreferences) HasChannel(channel string) bool {
+	for _, c := range p.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Wants reports whether the member wants to hear about the notice through the channel
+// at the given time. Only urgent notices are sent during quiet hours; notifications of
+// other notices are dropped, not delayed.
+func (p *UserPreferences) Wants(channel string, notice *Notice, now time.Time) bool {
+	if p.QuietHours.Contains(now) && !notice.IsUrgent() {
+		return false
+	}
+	return p.HasChannel(channel) && p.Subscribed(notice)
+}
+
+// Subscribed reports whether the notice is in one of the member's categories.
+// Members are subscribed to urgent notices regardless of their categories.
+func (p *UserPreferences) Subscribed(notice *Notice) bool {
+	if len(p.Categories) == 0 || notice.IsUrgent() {
+		return true
+	}
+	for _, category := range p.Categories {
+		if category == notice.Category {
+			return true
+		}
+	}
+	return false
+}
+
+// DigestFrequency returns how often the member gets a digest
+func (p *UserPreferences) DigestFrequency() string {
+	if p.Digest == "" {
+		return DigestNone
+	}
+	return p.Digest
+}
+
+// Claims returns session claims standing in for the member, to check which notices they see
+func (p *UserPreferences) Claims() *SessionClaims {
+	return &SessionClaims{
+		Subject:        p.UserId,
+		Email:          p.Email,
+		PhoneNumber:    p.PhoneNumber,
+		Groups:         p.Groups,
+		CongregationId: p.CongregationId,
+	}
+}
+
+// Contains reports whether the time falls in the quiet hours, which may span midnight.
+// Without quiet hours it is never quiet.
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q == nil {
+		return false
+	}
+	start, err1 := time.Parse(QuietHoursLayout, q.Start)
+	end, err2 := time.Parse(QuietHoursLayout, q.End)
+	location, err3 := time.LoadLocation(q.TimeZone)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return false
+	}
+
+	local := t.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// PreferencesStore persists notification preferences
+type PreferencesStore interface {
+	// GetPreferences returns nil without an error when the user has not saved any
+	GetPreferences(ctx context.Context, userId string) (*UserPreferences, error)
+	// CreatePreferences stores the first preferences of a user, returning ErrPreferencesExist if there are some
+	CreatePreferences(ctx context.Context, preferences *UserPreferences) error
+	PutPreferences(ctx context.Context, preferences *UserPreferences) error
+	// ListPreferences returns the preferences of every member of the congregation
+	ListPreferences(ctx context.Context, congregationId string) ([]UserPreferences, error)
+}
+
+// Preferences is the store holding the members' notification preferences
+var Preferences PreferencesStore
+
+// DynamoPreferencesStore keeps preferences in the AWS_DYNAMO_PREFERENCES_TABLE_NAME table,
+// keyed by UserId, the Cognito sub. Members are listed per congregation through the
+// AWS_DYNAMO_PREFERENCES_CONGREGATION_INDEX_NAME index.
+type DynamoPreferencesStore struct {
+	db                dynamodbiface.DynamoDBAPI
+	table             string
+	congregationIndex string
+}
+
+// NewDynamoPreferencesStore creates a preferences store for the configured table
+func NewDynamoPreferencesStore() *DynamoPreferencesStore {
+	congregationIndex := os.Getenv("AWS_DYNAMO_PREFERENCES_CONGREGATION_INDEX_NAME")
+	if congregationIndex == "" {
+		congregationIndex = "congregation-index"
+	}
+
+	return &DynamoPreferencesStore{
+		db:                newDynamoClient(),
+		table:             os.Getenv("AWS_DYNAMO_PREFERENCES_TABLE_NAME"),
+		congregationIndex: congregationIndex,
+	}
+}
+
+// GetPreferences loads the preferences of a user
+func (s *DynamoPreferencesStore) GetPreferences(ctx context.Context, userId string) (*UserPreferences, error) {
+	result, err := s.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserId": {S: aws.String(userId)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences of %s: %w", userId, err)
+	}
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var preferences UserPreferences
+	err = dynamodbattribute.UnmarshalMap(result.Item, &preferences)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preferences of %s: %w", userId, err)
+	}
+	return &preferences, nil
+}
+
+// CreatePreferences stores the first preferences of a user, refusing to overwrite existing ones
+func (s *DynamoPreferencesStore) CreatePreferences(ctx context.Context, preferences *UserPreferences) error {
+	err := s.put(ctx, preferences, aws.String("attribute_not_exists(UserId)"))
+	if isConditionFailed(err) {
+		return ErrPreferencesExist
+	}
+	return err
+}
+
+// PutPreferences stores the preferences of a user, replacing earlier ones
+func (s *DynamoPreferencesStore) PutPreferences(ctx context.Context, preferences *UserPreferences) error {
+	return s.put(ctx, preferences, nil)
+}
+
+func (s *DynamoPreferencesStore) put(ctx context.Context, preferences *UserPreferences, condition *string) error {
+	av, err := dynamodbattribute.MarshalMap(preferences)
+	if err != nil {
+		return fmt.Errorf("failed to store preferences of %s: %w", preferences.UserId, err)
+	}
+
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                av,
+		ConditionExpression: condition,
+	})
+	if err != nil && !isConditionFailed(err) {
+		return fmt.Errorf("failed to store preferences of %s: %w", preferences.UserId, err)
+	}
+	return err
+}
+
+// ListPreferences returns the preferences of every member of the congregation.
+// Members without a congregation are found with a scan, as the index leaves them out.
+func (s *DynamoPreferencesStore) ListPreferences(ctx context.Context, congregationId string) ([]UserPreferences, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	var err error
+	if congregationId != "" {
+		err = s.db.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(s.table),
+			IndexName:                 aws.String(s.congregationIndex),
+			KeyConditionExpression:    aws.String("congregation_id = :congregation"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":congregation": {S: aws.String(congregationId)}},
+		}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+			items = append(items, page.Items...)
+			return true
+		})
+	} else {
+		err = s.db.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+			TableName:        aws.String(s.table),
+			FilterExpression: aws.String("attribute_not_exists(congregation_id)"),
+		}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+			items = append(items, page.Items...)
+			return true
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list preferences: %w", err)
+	}
+
+	var preferences []UserPreferences
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &preferences)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preferences: %w", err)
+	}
+	sortPreferences(preferences)
+	return preferences, nil
+}
+
+// MemoryPreferencesStore is an in-memory PreferencesStore for tests and local runs
+type MemoryPreferencesStore struct {
+	mu          sync.Mutex
+	preferences map[string]UserPreferences
+}
+
+// NewMemoryPreferencesStore creates an empty in-memory preferences store
+func NewMemoryPreferencesStore() *MemoryPreferencesStore {
+	return &MemoryPreferencesStore{preferences: make(map[string]UserPreferences)}
+}
+
+// GetPreferences loads the preferences of a user
+func (s *MemoryPreferencesStore) GetPreferences(ctx context.Context, userId string) (*UserPreferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	preferences, ok := s.preferences[userId]
+	if !ok {
+		return nil, nil
+	}
+	return &preferences, nil
+}
+
+// CreatePreferences stores the first preferences of a user, refusing to overwrite existing ones
+func (s *MemoryPreferencesStore) CreatePreferences(ctx context.Context, preferences *UserPreferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.preferences[preferences.UserId]; ok {
+		return ErrPreferencesExist
+	}
+	s.preferences[preferences.UserId] = *preferences
+	return nil
+}
+
+// PutPreferences stores the preferences of a user, replacing earlier ones
+func (s *MemoryPreferencesStore) PutPreferences(ctx context.Context, preferences *UserPreferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.preferences[preferences.UserId] = *preferences
+	return nil
+}
+
+// ListPreferences returns the preferences of every member of the congregation
+func (s *MemoryPreferencesStore) ListPreferences(ctx context.Context, congregationId string) ([]UserPreferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var preferences []UserPreferences
+	for _, p := range s.preferences {
+		if p.CongregationId == congregationId {
+			preferences = append(preferences, p)
+		}
+	}
+	sortPreferences(preferences)
+	return preferences, nil
+}
+
+func sortPreferences(preferences []UserPreferences) {
+	sort.Slice(preferences, func(i, j int) bool {
+		return preferences[i].UserId < preferences[j].UserId
+	})
+}
+
+// ValidatePreferences checks the preferences before they are stored. Quiet hours without
+// a time zone get the one of the caller's congregation.
+// Problems the caller should report are returned as a *ValidationError.
+func ValidatePreferences(ctx context.Context, preferences *UserPreferences) error {
+	for _, channel := range preferences.Channels {
+		known := false
+		for _, c := range Channels {
+			known = known || c == channel
+		}
+		if !known {
+			return &ValidationError{Message: fmt.Sprintf("Unknown channel: %s", channel)}
+		}
+	}
+	for _, category := range preferences.Categories {
+		if !ValidCategoryName(category) {
+			return &ValidationError{Message: fmt.Sprintf("Invalid category: %s", category)}
+		}
+	}
+
+	if preferences.HasChannel(ChannelSMS) && preferences.PhoneNumber == "" {
+		return &ValidationError{Message: "Text messages need a verified phone number"}
+	}
+
+	switch preferences.Digest {
+	case "":
+		preferences.Digest = DigestNone
+	case DigestNone, DigestDaily, DigestWeekly:
+	default:
+		return &ValidationError{Message: fmt.Sprintf("Unknown digest: %s", preferences.Digest)}
+	}
+
+	quiet := preferences.QuietHours
+	if quiet == nil {
+		return nil
+	}
+	for _, t := range []string{quiet.Start, quiet.End} {
+		if _, err := time.Parse(QuietHoursLayout, t); err != nil {
+			return &ValidationError{Message: fmt.Sprintf("Quiet hours must be given as HH:MM, not %q", t)}
+		}
+	}
+	if quiet.Start == quiet.End {
+		return &ValidationError{Message: "Quiet hours must not start and end at the same time"}
+	}
+	if quiet.TimeZone == "" {
+		timeZone, err := congregationTimeZone(ctx)
+		if err != nil {
+			return err
+		}
+		quiet.TimeZone = timeZone
+	}
+	if _, err := time.LoadLocation(quiet.TimeZone); err != nil {
+		return &ValidationError{Message: fmt.Sprintf("Unknown time zone: %s", quiet.TimeZone)}
+	}
+	return nil
+}
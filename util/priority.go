@@ -0,0 +1,66 @@
+package util
+
+import "sort"
+
+// Notice priorities. Notices without a priority are normal.
+const (
+	PriorityNormal    = "normal"
+	PriorityImportant = "important"
+	PriorityUrgent    = "urgent"
+)
+
+// MaxReorder is the number of notices one reorder can renumber, the DynamoDB transaction limit
+const MaxReorder = 100
+
+// ValidPriority reports whether p is a known priority, or empty for normal
+func ValidPriority(p string) bool {
+	switch p {
+	case "", PriorityNormal, PriorityImportant, PriorityUrgent:
+		return true
+	}
+	return false
+}
+
+// EffectivePriority returns the notice's priority, counting notices without one as normal
+func (n *Notice) EffectivePriority() string {
+	if n.Priority == "" {
+		return PriorityNormal
+	}
+	return n.Priority
+}
+
+// IsUrgent reports whether the notice has urgent priority
+func (n *Notice) IsUrgent() bool {
+	return n.Priority == PriorityUrgent
+}
+
+// boardGroup puts pinned notices first, then urgent ones, then the rest
+func boardGroup(n *Notice) int {
+	switch {
+	case n.Pinned:
+		return 0
+	case n.IsUrgent():
+		return 1
+	}
+	return 2
+}
+
+// SortForBoard orders notices the way the board shows them: pinned notices first,
+// then urgent ones, then the rest. Within each group notices with a manual position
+// come first in that order, followed by the others newest first.
+func SortForBoard(notices []Notice) {
+	sort.SliceStable(notices, func(i, j int) bool {
+		a, b := &notices[i], &notices[j]
+		if boardGroup(a) != boardGroup(b) {
+			return boardGroup(a) < boardGroup(b)
+		}
+		if a.Position != b.Position {
+			// Notices without a position go after those with one
+			if a.Position == 0 || b.Position == 0 {
+				return b.Position == 0
+			}
+			return a.Position < b.Position
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+}
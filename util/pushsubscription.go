@@ -0,0 +1,191 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// PushSubscription is a browser's Web Push subscription, as returned by
+// PushManager.subscribe(). Each device a member enables push on has its own endpoint.
+type PushSubscription struct {
+	UserId   string   `json:"user_id" dynamodbav:"UserId"`
+	Endpoint string   `json:"endpoint" dynamodbav:"Endpoint"`
+	Keys     PushKeys `json:"keys"`
+	// UserAgent helps members tell their devices apart
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PushKeys are the subscription's keys, in unpadded base64url
+type PushKeys struct {
+	// P256dh is the browser's P-256 public key
+	P256dh string `json:"p256dh"`
+	// Auth is the 16-byte authentication secret
+	Auth string `json:"auth"`
+}
+
+// decode returns the browser's public key and the authentication secret
+func (k *PushKeys) decode() ([]byte, []byte, error) {
+	// Browsers use unpadded base64url, but some libraries pad the keys
+	public, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.P256dh, "="))
+	if err != nil || len(public) != 65 || public[0] != 4 {
+		return nil, nil, fmt.Errorf("invalid p256dh key")
+	}
+	auth, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.Auth, "="))
+	if err != nil || len(auth) != 16 {
+		return nil, nil, fmt.Errorf("invalid auth secret")
+	}
+	return public, auth, nil
+}
+
+// ValidatePushSubscription checks the subscription a browser sent. The endpoint must
+// be an HTTPS URL, as every push service has one.
+func ValidatePushSubscription(subscription *PushSubscription) error {
+	endpoint, err := url.Parse(subscription.Endpoint)
+	if subscription.Endpoint == "" || err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return &ValidationError{Message: "The endpoint must be an HTTPS URL"}
+	}
+	if _, _, err := subscription.Keys.decode(); err != nil {
+		return &ValidationError{Message: "The subscription keys are invalid"}
+	}
+	return nil
+}
+
+// PushSubscriptionStore keeps the push subscriptions of members
+type PushSubscriptionStore interface {
+	// PutPushSubscription stores the subscription, replacing one with the same endpoint
+	PutPushSubscription(ctx context.Context, subscription *PushSubscription) error
+	DeletePushSubscription(ctx context.Context, userId, endpoint string) error
+	ListPushSubscriptions(ctx context.Context, userId string) ([]PushSubscription, error)
+}
+
+// PushSubscriptions is the store holding push subscriptions
+var PushSubscriptions PushSubscriptionStore
+
+// DynamoPushSubscriptionStore keeps subscriptions in the AWS_DYNAMO_PUSH_SUBSCRIPTION_TABLE_NAME
+// table, keyed by UserId and Endpoint
+type DynamoPushSubscriptionStore struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+// NewDynamoPushSubscriptionStore creates a subscription store for the configured table
+func NewDynamoPushSubscriptionStore() *DynamoPushSubscriptionStore {
+	return &DynamoPushSubscriptionStore{
+		db:    newDynamoClient(),
+		table: os.Getenv("AWS_DYNAMO_PUSH_SUBSCRIPTION_TABLE_NAME"),
+	}
+}
+
+// PutPushSubscription stores the subscription, replacing one with the same endpoint
+func (s *DynamoPushSubscriptionStore) PutPushSubscription(ctx context.Context, subscription *PushSubscription) error {
+	av, err := dynamodbattribute.MarshalMap(subscription)
+	if err != nil {
+		return fmt.Errorf("failed to store push subscription of %s: %w", subscription.UserId, err)
+	}
+
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store push subscription of %s: %w", subscription.UserId, err)
+	}
+	return nil
+}
+
+// DeletePushSubscription removes the subscription, if it exists
+func (s *DynamoPushSubscriptionStore) DeletePushSubscription(ctx context.Context, userId, endpoint string) error {
+	_, err := s.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserId":   {S: aws.String(userId)},
+			"Endpoint": {S: aws.String(endpoint)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete push subscription of %s: %w", userId, err)
+	}
+	return nil
+}
+
+// ListPushSubscriptions returns the subscriptions of the user
+func (s *DynamoPushSubscriptionStore) ListPushSubscriptions(ctx context.Context, userId string) ([]PushSubscription, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	err := s.db.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.table),
+		KeyConditionExpression:    aws.String("UserId = :user"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":user": {S: aws.String(userId)}},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list push subscriptions of %s: %w", userId, err)
+	}
+
+	var subscriptions []PushSubscription
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &subscriptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read push subscriptions of %s: %w", userId, err)
+	}
+	return subscriptions, nil
+}
+
+// MemoryPushSubscriptionStore is an in-memory PushSubscriptionStore for tests and local runs
+type MemoryPushSubscriptionStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]PushSubscription
+}
+
+// NewMemoryPushSubscriptionStore creates an empty in-memory subscription store
+func NewMemoryPushSubscriptionStore() *MemoryPushSubscriptionStore {
+	return &MemoryPushSubscriptionStore{subscriptions: make(map[string]PushSubscription)}
+}
+
+// PutPushSubscription stores the subscription, replacing one with the same endpoint
+func (s *MemoryPushSubscriptionStore) PutPushSubscription(ctx context.Context, subscription *PushSubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscriptions[subscription.UserId+" "+subscription.Endpoint] = *subscription
+	return nil
+}
+
+// DeletePushSubscription removes the subscription, if it exists
+func (s *MemoryPushSubscriptionStore) DeletePushSubscription(ctx context.Context, userId, endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscriptions, userId+" "+endpoint)
+	return nil
+}
+
+// ListPushSubscriptions returns the subscriptions of the user, ordered by endpoint
+func (s *MemoryPushSubscriptionStore) ListPushSubscriptions(ctx context.Context, userId string) ([]PushSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subscriptions []PushSubscription
+	for _, subscription := range s.subscriptions {
+		if subscription.UserId == userId {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].Endpoint < subscriptions[j].Endpoint
+	})
+	return subscriptions, nil
+}
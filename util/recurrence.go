@@ -0,0 +1,368 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies supported in RRULE values
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+// weekdayCodes are the RFC 5545 two-letter weekday names
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// RuleDay is a BYDAY entry: a weekday, with an ordinal such as 2 (second) or -1 (last)
+// in monthly rules, or 0 for every such weekday
+type RuleDay struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+// Recurrence is a parsed RFC 5545 recurrence rule. Daily, weekly and monthly rules
+// are supported, with INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and WKST.
+type Recurrence struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []RuleDay
+	ByMonthDay []int
+	WeekStart  time.Weekday
+}
+
+// ParseRecurrence parses an RRULE value such as "FREQ=WEEKLY;BYDAY=TU,TH".
+// UNTIL values without a time zone are read in loc.
+func ParseRecurrence(rule string, loc *time.Location) (*Recurrence, error) {
+	r := &Recurrence{Interval: 1, WeekStart: time.Monday}
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+
+	for _, part := range strings.Split(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+			if r.Freq != FreqDaily && r.Freq != FreqWeekly && r.Freq != FreqMonthly {
+				return nil, fmt.Errorf("FREQ=%s is not supported, use DAILY, WEEKLY or MONTHLY", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = positiveInt(name, value)
+		case "COUNT":
+			r.Count, err = positiveInt(name, value)
+		case "UNTIL":
+			var until time.Time
+			until, err = parseUntil(value, loc)
+			r.Until = &until
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseByMonthDay(value)
+		case "WKST":
+			weekday, ok := weekdayCodes[strings.ToUpper(value)]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %q", value)
+			}
+			r.WeekStart = weekday
+		default:
+			err = fmt.Errorf("rule part %s is not supported", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, fmt.Errorf("COUNT and UNTIL cannot be combined")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq != FreqMonthly {
+		return nil, fmt.Errorf("BYMONTHDAY is only supported in monthly rules")
+	}
+	for _, day := range r.ByDay {
+		if day.Ordinal != 0 && r.Freq != FreqMonthly {
+			return nil, fmt.Errorf("BYDAY ordinals are only supported in monthly rules")
+		}
+	}
+	return r, nil
+}
+
+// String formats the rule as an RRULE value, with UNTIL in UTC as RFC 5545 requires
+func (r *Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = weekdayCode(day.Weekday)
+			if day.Ordinal != 0 {
+				days[i] = strconv.Itoa(day.Ordinal) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCode(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Starts returns the start times of the occurrences beginning with dtstart that start
+// before end. Occurrences keep the wall-clock time of dtstart in its location, also
+// across daylight saving changes.
+func (r *Recurrence) Starts(dtstart, end time.Time) []time.Time {
+	var starts []time.Time
+	hour, minute, second := dtstart.Clock()
+	loc := dtstart.Location()
+	year, month, day := dtstart.Date()
+	count := 0
+
+	for period := 0; ; period++ {
+		// Every period starts later than the one before, so this ends once periods pass end
+		days, periodStart := r.periodDays(year, month, day, dtstart.Weekday(), period, loc)
+		if !periodStart.Before(end) && periodStart.After(dtstart) {
+			return starts
+		}
+
+		for _, d := range days {
+			start := time.Date(d.year, d.month, d.day, hour, minute, second, 0, loc)
+			if start.Before(dtstart) {
+				continue
+			}
+			if !start.Before(end) || (r.Until != nil && start.After(*r.Until)) {
+				return starts
+			}
+			starts = append(starts, start)
+			count++
+			if r.Count > 0 && count >= r.Count {
+				return starts
+			}
+		}
+	}
+}
+
+type ruleDate struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+// periodDays returns the candidate dates of the nth period after the one containing
+// the start date, in order, together with the time the period begins in loc
+func (r *Recurrence) periodDays(year int, month time.Month, day int, weekday time.Weekday, n int, loc *time.Location) ([]ruleDate, time.Time) {
+	var dates []ruleDate
+	switch r.Freq {
+	case FreqDaily:
+		date := time.Date(year, month, day+n*r.Interval, 0, 0, 0, 0, loc)
+		if r.matchesWeekday(date.Weekday()) {
+			dates = append(dates, toRuleDate(date))
+		}
+		return dates, date
+
+	case FreqWeekly:
+		// Weeks begin on WKST
+		offset := (int(weekday) - int(r.WeekStart) + 7) % 7
+		weekStart := time.Date(year, month, day-offset+7*n*r.Interval, 0, 0, 0, 0, loc)
+		weekdays := []time.Weekday{weekday}
+		if len(r.ByDay) > 0 {
+			weekdays = weekdays[:0]
+			for _, d := range r.ByDay {
+				weekdays = append(weekdays, d.Weekday)
+			}
+		}
+		offsets := make([]int, 0, len(weekdays))
+		for _, wd := range weekdays {
+			offsets = append(offsets, (int(wd)-int(r.WeekStart)+7)%7)
+		}
+		sort.Ints(offsets)
+		for i, o := range offsets {
+			if i > 0 && o == offsets[i-1] {
+				continue
+			}
+			dates = append(dates, toRuleDate(weekStart.AddDate(0, 0, o)))
+		}
+		return dates, weekStart
+
+	default:
+		monthStart := time.Date(year, month+time.Month(n*r.Interval), 1, 0, 0, 0, 0, loc)
+		for _, d := range r.monthDays(monthStart, day) {
+			dates = append(dates, ruleDate{monthStart.Year(), monthStart.Month(), d})
+		}
+		return dates, monthStart
+	}
+}
+
+// monthDays returns the days of the month the rule selects, in order
+func (r *Recurrence) monthDays(monthStart time.Time, startDay int) []int {
+	daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+
+	byMonthDay := make(map[int]bool)
+	for _, d := range r.ByMonthDay {
+		if d < 0 {
+			d = daysInMonth + d + 1
+		}
+		if d >= 1 && d <= daysInMonth {
+			byMonthDay[d] = true
+		}
+	}
+
+	byDay := make(map[int]bool)
+	for _, rd := range r.ByDay {
+		first := 1 + (int(rd.Weekday)-int(monthStart.Weekday())+7)%7
+		switch {
+		case rd.Ordinal > 0:
+			if d := first + 7*(rd.Ordinal-1); d <= daysInMonth {
+				byDay[d] = true
+			}
+		case rd.Ordinal < 0:
+			last := first + 7*((daysInMonth-first)/7)
+			if d := last + 7*(rd.Ordinal+1); d >= 1 {
+				byDay[d] = true
+			}
+		default:
+			for d := first; d <= daysInMonth; d += 7 {
+				byDay[d] = true
+			}
+		}
+	}
+
+	var days []int
+	for d := 1; d <= daysInMonth; d++ {
+		switch {
+		case len(r.ByMonthDay) > 0 && len(r.ByDay) > 0:
+			if byMonthDay[d] && byDay[d] {
+				days = append(days, d)
+			}
+		case len(r.ByMonthDay) > 0:
+			if byMonthDay[d] {
+				days = append(days, d)
+			}
+		case len(r.ByDay) > 0:
+			if byDay[d] {
+				days = append(days, d)
+			}
+		case d == startDay:
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+func (r *Recurrence) matchesWeekday(weekday time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func toRuleDate(t time.Time) ruleDate {
+	year, month, day := t.Date()
+	return ruleDate{year, month, day}
+}
+
+func weekdayCode(weekday time.Weekday) string {
+	for code, wd := range weekdayCodes {
+		if wd == weekday {
+			return code
+		}
+	}
+	return ""
+}
+
+func positiveInt(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive number", name)
+	}
+	return n, nil
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, nil
+	}
+	// A date includes the whole day
+	if t, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func parseByDay(value string) ([]RuleDay, error) {
+	var days []RuleDay
+	for _, entry := range strings.Split(strings.ToUpper(value), ",") {
+		if len(entry) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY %q", entry)
+		}
+		weekday, ok := weekdayCodes[entry[len(entry)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY %q", entry)
+		}
+
+		ordinal := 0
+		if prefix := entry[:len(entry)-2]; prefix != "" {
+			var err error
+			ordinal, err = strconv.Atoi(prefix)
+			if err != nil || ordinal == 0 || ordinal < -5 || ordinal > 5 {
+				return nil, fmt.Errorf("invalid BYDAY %q", entry)
+			}
+		}
+		days = append(days, RuleDay{Ordinal: ordinal, Weekday: weekday})
+	}
+	return days, nil
+}
+
+func parseByMonthDay(value string) ([]int, error) {
+	var days []int
+	for _, entry := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(entry)
+		if err != nil || day == 0 || day < -31 || day > 31 {
+			return nil, fmt.Errorf("invalid BYMONTHDAY %q", entry)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
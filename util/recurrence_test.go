@@ -0,0 +1,102 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startsOf(t *testing.T, rule string, dtstart time.Time, end time.Time) []string {
+	r, err := ParseRecurrence(rule, dtstart.Location())
+	assert.NoError(t, err)
+
+	var starts []string
+	for _, s := range r.Starts(dtstart, end) {
+		starts = append(starts, s.Format("2006-01-02 15:04 MST"))
+	}
+	return starts
+}
+
+func TestRecurrence_Weekly(t *testing.T) {
+	amsterdam, _ := time.LoadLocation("Europe/Amsterdam")
+	// A Thursday evening meeting, across the change to summer time on 31 March 2024
+	dtstart := time.Date(2024, 3, 21, 19, 30, 0, 0, amsterdam)
+
+	starts := startsOf(t, "FREQ=WEEKLY;BYDAY=TH", dtstart, dtstart.AddDate(0, 0, 21))
+	assert.Equal(t, []string{"2024-03-21 19:30 CET", "2024-03-28 19:30 CET", "2024-04-04 19:30 CEST"}, starts)
+
+	// Twice a week, every other week, three times in all
+	starts = startsOf(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=TH,SU;COUNT=3", dtstart, dtstart.AddDate(1, 0, 0))
+	assert.Equal(t, []string{"2024-03-21 19:30 CET", "2024-03-24 19:30 CET", "2024-04-04 19:30 CEST"}, starts)
+}
+
+func TestRecurrence_Monthly(t *testing.T) {
+	dtstart := time.Date(2024, 1, 6, 10, 0, 0, 0, time.UTC)
+
+	// The first Saturday of the month
+	starts := startsOf(t, "FREQ=MONTHLY;BYDAY=1SA", dtstart, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, []string{"2024-01-06 10:00 UTC", "2024-02-03 10:00 UTC", "2024-03-02 10:00 UTC"}, starts)
+
+	// The last day of the month, until the end of March
+	dtstart = time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	starts = startsOf(t, "FREQ=MONTHLY;BYMONTHDAY=-1;UNTIL=20240331", dtstart, dtstart.AddDate(1, 0, 0))
+	assert.Equal(t, []string{"2024-01-31 10:00 UTC", "2024-02-29 10:00 UTC", "2024-03-31 10:00 UTC"}, starts)
+
+	// Without a rule day, months lacking the start's day are skipped
+	starts = startsOf(t, "FREQ=MONTHLY;COUNT=3", dtstart, dtstart.AddDate(1, 0, 0))
+	assert.Equal(t, []string{"2024-01-31 10:00 UTC", "2024-03-31 10:00 UTC", "2024-05-31 10:00 UTC"}, starts)
+}
+
+func TestRecurrence_Invalid(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=YEARLY",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;COUNT=2;UNTIL=20240101",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;BYHOUR=9",
+	} {
+		_, err := ParseRecurrence(rule, time.UTC)
+		assert.Error(t, err, rule)
+	}
+
+	r, err := ParseRecurrence("RRULE:freq=monthly;interval=2;byday=-1fr;until=20241231T000000Z", time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, "FREQ=MONTHLY;INTERVAL=2;UNTIL=20241231T000000Z;BYDAY=-1FR", r.String())
+}
+
+func TestNoticeOccurrences(t *testing.T) {
+	notice := &Notice{
+		ID:    1,
+		Title: "Midweek meeting",
+		Type:  NoticeTypeEvent,
+		Event: &EventDetails{
+			Start:          time.Date(2024, 5, 2, 17, 30, 0, 0, time.UTC),
+			End:            time.Date(2024, 5, 2, 19, 15, 0, 0, time.UTC),
+			TimeZone:       "Europe/Amsterdam",
+			RRule:          "FREQ=WEEKLY",
+			ExDates:        []string{"2024-05-16"},
+			CancelledDates: []string{"2024-05-23"},
+		},
+	}
+
+	// Occurrences overlapping the start of the range are included; exceptions are not,
+	// and cancelled ones are marked
+	from := time.Date(2024, 5, 9, 18, 0, 0, 0, time.UTC)
+	occurrences, err := notice.Occurrences(from, from.AddDate(0, 0, 14))
+	assert.NoError(t, err)
+	assert.Len(t, occurrences, 2)
+	assert.Equal(t, "2024-05-09T19:30:00+02:00", occurrences[0].Start.Format(time.RFC3339))
+	assert.Equal(t, "2024-05-09T21:15:00+02:00", occurrences[0].End.Format(time.RFC3339))
+	assert.Equal(t, "2024-05-23T19:30:00+02:00", occurrences[1].Start.Format(time.RFC3339))
+	assert.False(t, occurrences[0].Cancelled)
+	assert.True(t, occurrences[1].Cancelled)
+
+	// Plain notices have none
+	occurrences, err = (&Notice{ID: 2}).Occurrences(from, from.AddDate(0, 0, 14))
+	assert.NoError(t, err)
+	assert.Empty(t, occurrences)
+}
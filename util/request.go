@@ -0,0 +1,40 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrMissingNoticeId is returned when a request does not identify a notice
+var ErrMissingNoticeId = errors.New("notice id is required")
+
+// NoticeIdFromRequest reads the notice ID from the id path parameter, falling back
+// to an Id field in the JSON body as sent by older clients
+func NoticeIdFromRequest(request events.APIGatewayProxyRequest) (int, error) {
+	if value := request.PathParameters["id"]; value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return 0, errors.New("notice id must be a positive integer")
+		}
+		return id, nil
+	}
+
+	if request.Body == "" {
+		return 0, ErrMissingNoticeId
+	}
+
+	var key struct {
+		Id int `json:"Id"`
+	}
+	err := json.Unmarshal([]byte(request.Body), &key)
+	if err != nil {
+		return 0, errors.New("invalid request payload")
+	}
+	if key.Id <= 0 {
+		return 0, ErrMissingNoticeId
+	}
+	return key.Id, nil
+}
@@ -0,0 +1,87 @@
+package util
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Revision is a saved version of a notice. Every edit through the store function
+// adds one, keyed by the notice ID and its version number.
+type Revision struct {
+	NoticeId  int       `json:"notice_id" dynamodbav:"NoticeId"`
+	Version   int       `json:"version" dynamodbav:"Version"`
+	Notice    Notice    `json:"notice"`
+	EditedBy  string    `json:"edited_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewRevision records the notice as it is now
+func NewRevision(notice *Notice, editedBy string) Revision {
+	return Revision{
+		NoticeId:  notice.ID,
+		Version:   notice.Version,
+		Notice:    *notice,
+		EditedBy:  editedBy,
+		CreatedAt: notice.UpdatedAt,
+	}
+}
+
+// FieldChange is a difference in one field between two versions of a notice
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// revisionFields are the fields editors change, and that are compared and restored
+var revisionFields = []struct {
+	name  string
+	value func(n *Notice) interface{}
+}{
+	{"title", func(n *Notice) interface{} { return n.Title }},
+	{"content", func(n *Notice) interface{} { return n.Content }},
+	{"author", func(n *Notice) interface{} { return n.Author }},
+	{"category", func(n *Notice) interface{} { return n.Category }},
+	{"tags", func(n *Notice) interface{} { return n.Tags }},
+	{"pinned", func(n *Notice) interface{} { return n.Pinned }},
+	{"priority", func(n *Notice) interface{} { return n.Priority }},
+	{"type", func(n *Notice) interface{} { return n.Type }},
+	{"event", func(n *Notice) interface{} { return n.Event }},
+	{"publish_at", func(n *Notice) interface{} { return n.PublishAt }},
+	{"expire_at", func(n *Notice) interface{} { return n.ExpireAt }},
+}
+
+// DiffNotices lists the fields that differ between two versions of a notice
+func DiffNotices(from, to *Notice) []FieldChange {
+	changes := []FieldChange{}
+	for _, field := range revisionFields {
+		a, b := field.value(from), field.value(to)
+
+		// Compare the JSON forms so equal times in different locations match
+		aJSON, _ := json.Marshal(a)
+		bJSON, _ := json.Marshal(b)
+		if string(aJSON) != string(bJSON) {
+			changes = append(changes, FieldChange{Field: field.name, From: a, To: b})
+		}
+	}
+	return changes
+}
+
+// RestoreRevision copies the edited fields of an older version back onto the notice
+func (n *Notice) RestoreRevision(revision *Revision) {
+	old := revision.Notice
+	n.Title = old.Title
+	n.Content = old.Content
+	n.Author = old.Author
+	n.Category = old.Category
+	n.Tags = old.Tags
+	n.Pinned = old.Pinned
+	n.Priority = old.Priority
+	n.Type = old.Type
+	n.Event = old.Event
+	n.PublishAt = old.PublishAt
+	n.ExpireAt = old.ExpireAt
+	n.Language = old.Language
+	n.Translations = old.Translations
+	n.RenderContent()
+}
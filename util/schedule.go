@@ -0,0 +1,56 @@
+package util
+
+import (
+	"os"
+	"time"
+)
+
+// Schedule states of a notice
+const (
+	ScheduleScheduled = "scheduled"
+	ScheduleLive      = "live"
+	ScheduleExpired   = "expired"
+)
+
+// Expiry modes, read from NOTICE_EXPIRY_MODE
+const (
+	// ExpiryDelete lets DynamoDB TTL remove expired notices
+	ExpiryDelete = "delete"
+	// ExpiryArchive keeps expired notices, hidden from members
+	ExpiryArchive = "archive"
+)
+
+// NoticeExpiryMode returns how expired notices are disposed of
+func NoticeExpiryMode() string {
+	if os.Getenv("NOTICE_EXPIRY_MODE") == ExpiryArchive {
+		return ExpiryArchive
+	}
+	return ExpiryDelete
+}
+
+// ScheduleStateAt returns whether the notice is scheduled, live or expired at the given time
+func (n *Notice) ScheduleStateAt(now time.Time) string {
+	if n.ExpireAt != nil && !now.Before(*n.ExpireAt) {
+		return ScheduleExpired
+	}
+	if n.PublishAt != nil && now.Before(*n.PublishAt) {
+		return ScheduleScheduled
+	}
+	return ScheduleLive
+}
+
+// IsLiveAt reports whether the notice is inside its publishing window at the given time
+func (n *Notice) IsLiveAt(now time.Time) bool {
+	return n.ScheduleStateAt(now) == ScheduleLive
+}
+
+// ApplySchedule records the schedule state and the DynamoDB TTL for the notice.
+// The TTL is only set when expired notices are to be deleted.
+func (n *Notice) ApplySchedule(now time.Time) {
+	n.ScheduleState = n.ScheduleStateAt(now)
+
+	n.TTL = 0
+	if n.ExpireAt != nil && NoticeExpiryMode() == ExpiryDelete {
+		n.TTL = n.ExpireAt.Unix()
+	}
+}
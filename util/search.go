@@ -0,0 +1,469 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// titleBoost is how much more a word in the title counts than one in the content
+const titleBoost = 3
+
+// Posting records how often a search term occurs in a notice
+type Posting struct {
+	Term     string `json:"term" dynamodbav:"Term"`
+	NoticeId int    `json:"notice_id" dynamodbav:"NoticeId"`
+	// Title counts the term in the title, Body in the content, tags and category
+	Title int `json:"title" dynamodbav:"title"`
+	Body  int `json:"body" dynamodbav:"body"`
+}
+
+// SearchIndex is the inverted index notices are searched through: for every term, the
+// notices it occurs in
+type SearchIndex interface {
+	// IndexPostings stores the notice's postings and removes its postings for the terms
+	// it no longer contains
+	IndexPostings(ctx context.Context, noticeId int, postings []Posting, removed []string) error
+	// Postings returns the postings of the term
+	Postings(ctx context.Context, term string) ([]Posting, error)
+	// ClearSearchIndex removes every posting
+	ClearSearchIndex(ctx context.Context) error
+}
+
+// Search is the index used to search notices
+var Search SearchIndex
+
+// noticeLanguage returns the language the notice is written in, guessing it when the
+// notice does not say or is in a language there is no stemmer for
+func noticeLanguage(notice *Notice) string {
+	if language := baseLanguage(notice.Language); stemmers[language] != nil {
+		return language
+	}
+	return detectLanguage(tokenize(notice.Title + " " + notice.Content))
+}
+
+// noticeText is the text of the notice's content, without its formatting
+func noticeText(notice *Notice) string {
+	if text := plainText(notice.ContentHTML); text != "" {
+		return text
+	}
+	return notice.Content
+}
+
+// noticeBody is the searchable text of the notice besides its title
+func noticeBody(notice *Notice) string {
+	return strings.Join(append([]string{noticeText(notice), notice.Category}, notice.Tags...), " ")
+}
+
+// NoticePostings analyses the notice into its postings: the stems of the words in its
+// title, content, tags and category, in the notice's language, without stopwords.
+// Notices in the trash have none.
+func NoticePostings(notice *Notice) []Posting {
+	if notice == nil || notice.IsDeleted() {
+		return nil
+	}
+	language := noticeLanguage(notice)
+	postings := make(map[string]*Posting)
+	count := func(text string, title bool) {
+		for _, token := range tokenize(text) {
+			if isStopword(token.term) {
+				continue
+			}
+			term := stemTerm(token.term, language)
+			posting, ok := postings[term]
+			if !ok {
+				posting = &Posting{Term: term, NoticeId: notice.ID}
+				postings[term] = posting
+			}
+			if title {
+				posting.Title++
+			} else {
+				posting.Body++
+			}
+		}
+	}
+	count(notice.Title, true)
+	count(noticeBody(notice), false)
+
+	result := make([]Posting, 0, len(postings))
+	for _, posting := range postings {
+		result = append(result, *posting)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Term < result[j].Term
+	})
+	return result
+}
+
+// IndexNotice brings the index up to date with a change from one version of a notice to
+// the next; before is nil for a new notice and after nil for one removed for good.
+// Indexing the same change twice leaves the index as it is.
+func IndexNotice(ctx context.Context, before, after *Notice) error {
+	postings := NoticePostings(after)
+	current := make(map[string]bool, len(postings))
+	for _, posting := range postings {
+		current[posting.Term] = true
+	}
+	var removed []string
+	for _, posting := range NoticePostings(before) {
+		if !current[posting.Term] {
+			removed = append(removed, posting.Term)
+		}
+	}
+
+	if len(postings) == 0 && len(removed) == 0 {
+		return nil
+	}
+	notice := after
+	if notice == nil {
+		notice = before
+	}
+	return Search.IndexPostings(ctx, notice.ID, postings, removed)
+}
+
+// SearchIndexSubscriber keeps the search index up to date with the notice changes it is
+// handed by the stream consumer
+type SearchIndexSubscriber struct{}
+
+// HandleNoticeChange indexes the notice as it is after the change
+func (SearchIndexSubscriber) HandleNoticeChange(ctx context.Context, change *NoticeChange) error {
+	return IndexNotice(ctx, change.Old, change.New)
+}
+
+// RebuildSearchIndex clears the index and indexes every notice outside the trash again,
+// returning how many it indexed. Notices changed while it runs are indexed by the stream.
+func RebuildSearchIndex(ctx context.Context) (int, error) {
+	err := Search.ClearSearchIndex(ctx)
+	if err != nil {
+		return 0, err
+	}
+	notices, err := Notices.ListNotices(ctx, NoticeFilter{})
+	if err != nil {
+		return 0, err
+	}
+	for i := range notices {
+		if err := IndexNotice(ctx, nil, &notices[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(notices), nil
+}
+
+// SearchQuery is a search for notices
+type SearchQuery struct {
+	Text     string
+	Category string
+	Limit    int
+}
+
+// SearchResult is a notice found by a search, with its title and an extract of its
+// content as HTML in which the matching words are marked
+type SearchResult struct {
+	Notice  Notice  `json:"notice"`
+	Score   float64 `json:"score"`
+	Title   string  `json:"title_html"`
+	Snippet string  `json:"snippet_html"`
+}
+
+// queryTerms returns, for each word of the query, the stems it may have been indexed
+// under: the query's language is not known, so every language's stem is tried
+func queryTerms(text string) [][]string {
+	var words [][]string
+	seen := make(map[string]bool)
+	for _, token := range tokenize(text) {
+		if isStopword(token.term) || seen[token.term] {
+			continue
+		}
+		seen[token.term] = true
+
+		var variants []string
+		for _, language := range []string{LanguageEnglish, LanguageDutch} {
+			stem := stemTerm(token.term, language)
+			if len(variants) == 0 || variants[0] != stem {
+				variants = append(variants, stem)
+			}
+		}
+		words = append(words, variants)
+	}
+	return words
+}
+
+// SearchNotices finds the notices the session may see that contain every word of the
+// query, best matches first. A word counts more in the title than in the content, and
+// less the more notices it occurs in.
+func SearchNotices(ctx context.Context, claims *SessionClaims, query SearchQuery, now time.Time) ([]SearchResult, error) {
+	words := queryTerms(query.Text)
+	if len(words) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	// Score every notice on each word by the best of its stems
+	scores := make(map[int]float64)
+	matched := make(map[int]int)
+	terms := make(map[string]bool)
+	for _, variants := range words {
+		best := make(map[int]float64)
+		for _, term := range variants {
+			terms[term] = true
+			postings, err := Search.Postings(ctx, term)
+			if err != nil {
+				return nil, err
+			}
+			weight := 1 / (1 + math.Log(float64(len(postings)+1)))
+			for _, posting := range postings {
+				score := float64(titleBoost*posting.Title+posting.Body) * weight
+				if score > best[posting.NoticeId] {
+					best[posting.NoticeId] = score
+				}
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+			matched[id]++
+		}
+	}
+
+	var ids []int
+	for id, count := range matched {
+		if count == len(words) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return []SearchResult{}, nil
+	}
+	notices, err := Notices.ListNotices(ctx, NoticeFilter{Ids: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	results := []SearchResult{}
+	for i := range notices {
+		notice := &notices[i]
+		if !NoticeVisibleTo(claims, notice, now) || (query.Category != "" && notice.Category != query.Category) {
+			continue
+		}
+		language := noticeLanguage(notice)
+		results = append(results, SearchResult{
+			Notice:  *notice,
+			Score:   math.Round(scores[notice.ID]*1000) / 1000,
+			Title:   highlight(notice.Title, language, terms),
+			Snippet: snippet(noticeText(notice), language, terms),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Notice.UpdatedAt.After(results[j].Notice.UpdatedAt)
+	})
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
+
+// DynamoSearchIndex keeps the postings in the AWS_DYNAMO_SEARCH_TABLE_NAME table, keyed by
+// Term and NoticeId
+type DynamoSearchIndex struct {
+	db         dynamodbiface.DynamoDBAPI
+	table      string
+	retryDelay time.Duration
+}
+
+// batchWriteRetries is how often in a row unprocessed writes are sent again, waiting
+// twice as long each time, before batchWrite gives up
+const batchWriteRetries = 6
+
+// NewDynamoSearchIndex creates an index for the configured table
+func NewDynamoSearchIndex() *DynamoSearchIndex {
+	return &DynamoSearchIndex{
+		db:         newDynamoClient(),
+		table:      os.Getenv("AWS_DYNAMO_SEARCH_TABLE_NAME"),
+		retryDelay: 50 * time.Millisecond,
+	}
+}
+
+func postingKey(term string, noticeId int) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Term":     {S: aws.String(term)},
+		"NoticeId": {N: aws.String(strconv.Itoa(noticeId))},
+	}
+}
+
+// IndexPostings stores the notice's postings and removes its postings for the terms it
+// no longer contains
+func (s *DynamoSearchIndex) IndexPostings(ctx context.Context, noticeId int, postings []Posting, removed []string) error {
+	var requests []*dynamodb.WriteRequest
+	for i := range postings {
+		av, err := dynamodbattribute.MarshalMap(&postings[i])
+		if err != nil {
+			return fmt.Errorf("failed to index notice %d: %w", noticeId, err)
+		}
+		requests = append(requests, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: av}})
+	}
+	for _, term := range removed {
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{Key: postingKey(term, noticeId)},
+		})
+	}
+
+	err := s.batchWrite(ctx, requests)
+	if err != nil {
+		return fmt.Errorf("failed to index notice %d: %w", noticeId, err)
+	}
+	return nil
+}
+
+// batchWrite sends the requests, 25 at a time as BatchWriteItem requires. Writes DynamoDB
+// leaves unprocessed, because the table is throttled, are sent again with exponential
+// backoff. When they still fail batchWrite returns an error, so that the caller, such as
+// the stream consumer, retries later.
+func (s *DynamoSearchIndex) batchWrite(ctx context.Context, requests []*dynamodb.WriteRequest) error {
+	retries := 0
+	delay := s.retryDelay
+	for len(requests) > 0 {
+		n := len(requests)
+		if n > 25 {
+			n = 25
+		}
+		result, err := s.db.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{s.table: requests[:n]},
+		})
+		if err != nil {
+			return err
+		}
+
+		unprocessed := result.UnprocessedItems[s.table]
+		if len(unprocessed) == 0 {
+			retries = 0
+			delay = s.retryDelay
+			requests = requests[n:]
+			continue
+		}
+		if retries == batchWriteRetries {
+			return fmt.Errorf("%d writes were still unprocessed after %d retries", len(unprocessed)+len(requests)-n, retries)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		retries++
+		delay *= 2
+		requests = append(unprocessed, requests[n:]...)
+	}
+	return nil
+}
+
+// Postings returns the postings of the term
+func (s *DynamoSearchIndex) Postings(ctx context.Context, term string) ([]Posting, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	err := s.db.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.table),
+		KeyConditionExpression:    aws.String("Term = :term"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":term": {S: aws.String(term)}},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for %q: %w", term, err)
+	}
+
+	var postings []Posting
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &postings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read postings of %q: %w", term, err)
+	}
+	return postings, nil
+}
+
+// ClearSearchIndex removes every posting
+func (s *DynamoSearchIndex) ClearSearchIndex(ctx context.Context) error {
+	var requests []*dynamodb.WriteRequest
+	err := s.db.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName:            aws.String(s.table),
+		ProjectionExpression: aws.String("Term, NoticeId"),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			requests = append(requests, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: item}})
+		}
+		return true
+	})
+	if err == nil {
+		err = s.batchWrite(ctx, requests)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to clear the search index: %w", err)
+	}
+	return nil
+}
+
+// MemorySearchIndex is an in-memory SearchIndex for tests and local runs
+type MemorySearchIndex struct {
+	mu       sync.Mutex
+	postings map[string]map[int]Posting
+}
+
+// NewMemorySearchIndex creates an empty in-memory index
+func NewMemorySearchIndex() *MemorySearchIndex {
+	return &MemorySearchIndex{postings: make(map[string]map[int]Posting)}
+}
+
+// IndexPostings stores the notice's postings and removes its postings for the terms it
+// no longer contains
+func (s *MemorySearchIndex) IndexPostings(ctx context.Context, noticeId int, postings []Posting, removed []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, posting := range postings {
+		if s.postings[posting.Term] == nil {
+			s.postings[posting.Term] = make(map[int]Posting)
+		}
+		s.postings[posting.Term][noticeId] = posting
+	}
+	for _, term := range removed {
+		delete(s.postings[term], noticeId)
+		if len(s.postings[term]) == 0 {
+			delete(s.postings, term)
+		}
+	}
+	return nil
+}
+
+// Postings returns the postings of the term
+func (s *MemorySearchIndex) Postings(ctx context.Context, term string) ([]Posting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	postings := make([]Posting, 0, len(s.postings[term]))
+	for _, posting := range s.postings[term] {
+		postings = append(postings, posting)
+	}
+	sort.Slice(postings, func(i, j int) bool {
+		return postings[i].NoticeId < postings[j].NoticeId
+	})
+	return postings, nil
+}
+
+// ClearSearchIndex removes every posting
+func (s *MemorySearchIndex) ClearSearchIndex(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.postings = make(map[string]map[int]Posting)
+	return nil
+}
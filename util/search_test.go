@@ -0,0 +1,185 @@
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStemmers(t *testing.T) {
+	for _, words := range [][]string{
+		{"meeting", "meetings"},
+		{"assembly", "assemblies"},
+		{"plans", "planned", "planning"},
+		{"clean", "cleaning", "cleaned"},
+		{"notice", "notices"},
+	} {
+		for _, word := range words[1:] {
+			assert.Equal(t, stemEnglish(words[0]), stemEnglish(word), word)
+		}
+	}
+	for _, words := range [][]string{
+		{"vergadering", "vergaderingen"},
+		{"zaak", "zaken"},
+		{"huis", "huisje"},
+		{"mogelijkheid", "mogelijkheden"},
+		{"dienst", "diensten"},
+	} {
+		for _, word := range words[1:] {
+			assert.Equal(t, stemDutch(words[0]), stemDutch(word), word)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize("Kringvergadering: café & the 2 Zaal-A's")
+	var terms []string
+	for _, token := range tokens {
+		terms = append(terms, token.term)
+	}
+	assert.Equal(t, []string{"kringvergadering", "cafe", "the", "2", "zaal"}, terms)
+	assert.Equal(t, "café", "Kringvergadering: café & the 2 Zaal-A's"[tokens[1].start:tokens[1].end])
+
+	assert.Equal(t, LanguageDutch, detectLanguage(tokenize("De vergadering is in de zaal van het gebouw")))
+	assert.Equal(t, LanguageEnglish, detectLanguage(tokenize("The meeting is in the hall")))
+}
+
+func TestSnippet(t *testing.T) {
+	terms := map[string]bool{stemEnglish("assembly"): true}
+	assert.Equal(t, "The circuit <mark>assembly</mark> is on &lt;Sunday&gt;", snippet("The circuit assembly is on <Sunday>", LanguageEnglish, terms))
+
+	long := "Please read carefully. "
+	for len(long) < 400 {
+		long += "Cleaning starts early and everyone is welcome to join. "
+	}
+	extract := snippet(long+"The assemblies are in June.", LanguageEnglish, terms)
+	assert.Contains(t, extract, "<mark>assemblies</mark>")
+	assert.True(t, len(extract) < 250)
+	assert.Equal(t, "…", extract[:len("…")])
+}
+
+func TestSearchNotices(t *testing.T) {
+	Notices = NewMemoryNoticeStore()
+	Search = NewMemorySearchIndex()
+	ctx := context.Background()
+	now := time.Now()
+
+	notices := []*Notice{
+		{ID: 1, Title: "Circuit assembly", Content: "The assembly is on Sunday.", Status: StatusPublished},
+		{ID: 2, Title: "Cleaning", Content: "After the circuit assembly the hall needs cleaning.", Status: StatusPublished},
+		{ID: 3, Title: "Kringvergadering", Content: "De vergaderingen zijn in de grote zaal.", Status: StatusPublished},
+		{ID: 4, Title: "Assembly draft", Content: "Not ready yet", Status: StatusDraft},
+		{ID: 5, Title: "Circuit assembly elsewhere", CongregationId: "south", Status: StatusPublished},
+	}
+	for _, notice := range notices {
+		notice.RenderContent()
+		require.NoError(t, Notices.PutNotice(ctx, notice))
+		require.NoError(t, IndexNotice(ctx, nil, notice))
+	}
+	member := &SessionClaims{Subject: "member"}
+
+	// Title matches rank first; drafts and other congregations stay hidden
+	results, err := SearchNotices(ctx, member, SearchQuery{Text: "circuit assemblies"}, now)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, 1, results[0].Notice.ID)
+	assert.Equal(t, "<mark>Circuit</mark> <mark>assembly</mark>", results[0].Title)
+	assert.Equal(t, 2, results[1].Notice.ID)
+	assert.Contains(t, results[1].Snippet, "<mark>circuit</mark> <mark>assembly</mark>")
+
+	// Dutch words are stemmed too
+	results, err = SearchNotices(ctx, member, SearchQuery{Text: "vergadering"}, now)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Snippet, "<mark>vergaderingen</mark>")
+
+	// Changing a notice replaces its terms
+	changed := *notices[1]
+	changed.Title = "Hall"
+	changed.Content = "Windows"
+	changed.RenderContent()
+	require.NoError(t, IndexNotice(ctx, notices[1], &changed))
+	results, err = SearchNotices(ctx, member, SearchQuery{Text: "cleaning"}, now)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	postings, err := Search.Postings(ctx, stemEnglish("clean"))
+	require.NoError(t, err)
+	assert.Empty(t, postings)
+
+	// Notices moved to the trash drop out of the index
+	trashed := *notices[0]
+	trashed.DeletedAt = &now
+	require.NoError(t, IndexNotice(ctx, notices[0], &trashed))
+	results, err = SearchNotices(ctx, member, SearchQuery{Text: "assembly"}, now)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Stopwords alone find nothing
+	results, err = SearchNotices(ctx, member, SearchQuery{Text: "the de"}, now)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// A rebuild indexes what is stored
+	count, err := RebuildSearchIndex(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(notices), count)
+	results, err = SearchNotices(ctx, member, SearchQuery{Text: "assembly"}, now)
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+}
+
+// throttledTable leaves the first write of every batch unprocessed until it has been
+// throttled the given number of times
+type throttledTable struct {
+	dynamodbiface.DynamoDBAPI
+	throttles int
+	written   []string
+}
+
+func (t *throttledTable) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	output := &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]*dynamodb.WriteRequest{}}
+	for table, requests := range input.RequestItems {
+		if t.throttles > 0 {
+			t.throttles--
+			output.UnprocessedItems[table] = requests[:1]
+			requests = requests[1:]
+		}
+		for _, request := range requests {
+			t.written = append(t.written, aws.StringValue(request.PutRequest.Item["Term"].S))
+		}
+	}
+	return output, nil
+}
+
+func TestDynamoSearchIndex_Unprocessed(t *testing.T) {
+	var requests []*dynamodb.WriteRequest
+	for _, term := range []string{"assembly", "clean", "hall"} {
+		requests = append(requests, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: postingKey(term, 1)}})
+	}
+
+	// Unprocessed writes are sent again
+	table := &throttledTable{throttles: 2}
+	index := &DynamoSearchIndex{db: table, table: "search", retryDelay: time.Millisecond}
+	require.NoError(t, index.batchWrite(context.Background(), requests))
+	assert.ElementsMatch(t, []string{"assembly", "clean", "hall"}, table.written)
+
+	// But not forever
+	table = &throttledTable{throttles: batchWriteRetries + 1}
+	index = &DynamoSearchIndex{db: table, table: "search", retryDelay: time.Millisecond}
+	assert.EqualError(t, index.batchWrite(context.Background(), requests), "1 writes were still unprocessed after 6 retries")
+	assert.ElementsMatch(t, []string{"clean", "hall"}, table.written)
+
+	// Nor once the caller stops waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	table = &throttledTable{throttles: 1}
+	index = &DynamoSearchIndex{db: table, table: "search", retryDelay: time.Hour}
+	assert.ErrorIs(t, index.batchWrite(ctx, requests), context.Canceled)
+}
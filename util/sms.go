@@ -0,0 +1,213 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+// SMSMessage is a text message to a phone number in E.164 format
+type SMSMessage struct {
+	To   string
+	Body string
+}
+
+// SMSSender sends text messages and returns the ID the provider gave the message
+type SMSSender interface {
+	Send(ctx context.Context, message SMSMessage) (string, error)
+}
+
+// SMS is the sender text messages go out through
+var SMS SMSSender
+
+// NewSMSSender returns the configured sender: SNS, or with SMS_SENDER set to log,
+// a LogSMSSender for local development
+func NewSMSSender() SMSSender {
+	if os.Getenv("SMS_SENDER") == "log" {
+		return LogSMSSender{}
+	}
+	return NewSNSSender()
+}
+
+// SNSSender sends text messages through Amazon SNS as transactional messages.
+// SMS_SENDER_ID optionally sets the alphanumeric sender ID, where the country allows one.
+type SNSSender struct {
+	sns      snsiface.SNSAPI
+	senderId string
+}
+
+// NewSNSSender creates a sender for the configured region
+func NewSNSSender() *SNSSender {
+	config := &aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}
+	if endpoint := os.Getenv("AWS_SNS_ENDPOINT"); endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+
+	sess := session.Must(session.NewSession(config))
+	return &SNSSender{
+		sns:      sns.New(sess),
+		senderId: os.Getenv("SMS_SENDER_ID"),
+	}
+}
+
+// Send sends the text message
+func (s *SNSSender) Send(ctx context.Context, message SMSMessage) (string, error) {
+	attributes := map[string]*sns.MessageAttributeValue{
+		"AWS.SNS.SMS.SMSType": {DataType: aws.String("String"), StringValue: aws.String("Transactional")},
+	}
+	if s.senderId != "" {
+		attributes["AWS.SNS.SMS.SenderID"] = &sns.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(s.senderId)}
+	}
+
+	result, err := s.sns.PublishWithContext(ctx, &sns.PublishInput{
+		PhoneNumber:       aws.String(message.To),
+		Message:           aws.String(message.Body),
+		MessageAttributes: attributes,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send text message: %w", err)
+	}
+	return aws.StringValue(result.MessageId), nil
+}
+
+// LogSMSSender writes text messages to the log instead of sending them
+type LogSMSSender struct{}
+
+// Send logs the text message
+func (LogSMSSender) Send(ctx context.Context, message SMSMessage) (string, error) {
+	log.Printf("sms to %s: %s", message.To, message.Body)
+	return "log", nil
+}
+
+// MemorySMSSender captures text messages for tests. Numbers in Fail are refused.
+type MemorySMSSender struct {
+	Fail     map[string]bool
+	mu       sync.Mutex
+	messages []SMSMessage
+}
+
+// Send records the text message
+func (s *MemorySMSSender) Send(ctx context.Context, message SMSMessage) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Fail[message.To] {
+		return "", errors.New("failed to send text message: unreachable")
+	}
+	s.messages = append(s.messages, message)
+	return fmt.Sprintf("memory-%d", len(s.messages)), nil
+}
+
+// Sent returns the text messages recorded so far
+func (s *MemorySMSSender) Sent() []SMSMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]SMSMessage(nil), s.messages...)
+}
+
+const shortCodeDigits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// ShortCode encodes a notice ID in base 62 for short links
+func ShortCode(id int) string {
+	if id <= 0 {
+		return "0"
+	}
+	var code []byte
+	for ; id > 0; id /= len(shortCodeDigits) {
+		code = append([]byte{shortCodeDigits[id%len(shortCodeDigits)]}, code...)
+	}
+	return string(code)
+}
+
+// ParseShortCode decodes the notice ID of a short link
+func ParseShortCode(code string) (int, error) {
+	if code == "" || len(code) > 10 {
+		return 0, fmt.Errorf("invalid short code %q", code)
+	}
+	id := 0
+	for _, c := range code {
+		digit := strings.IndexRune(shortCodeDigits, c)
+		if digit < 0 {
+			return 0, fmt.Errorf("invalid short code %q", code)
+		}
+		id = id*len(shortCodeDigits) + digit
+	}
+	return id, nil
+}
+
+// ShortLink returns a short link to the notice: SHORT_LINK_URL followed by the notice's
+// short code, or else the notice in the web app at NOTICEBOARD_URL. It is empty when
+// neither is set.
+func ShortLink(id int) string {
+	if base := strings.TrimSuffix(os.Getenv("SHORT_LINK_URL"), "/"); base != "" {
+		return base + "/" + ShortCode(id)
+	}
+	if base := noticeboardURL(); base != "" {
+		return fmt.Sprintf("%s/notices/%d", base, id)
+	}
+	return ""
+}
+
+// gsmCharacters is the GSM 03.38 default alphabet; gsmExtension characters take two septets
+const (
+	gsmCharacters = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsmExtension  = "^{}\\[~]|€\f"
+)
+
+// smsLength returns the length of the text in a single SMS and the most a single SMS holds:
+// 160 septets in the GSM alphabet, or 70 UTF-16 units for other text
+func smsLength(text string) (int, int) {
+	septets := 0
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(gsmCharacters, r):
+			septets++
+		case strings.ContainsRune(gsmExtension, r):
+			septets += 2
+		default:
+			return len(utf16.Encode([]rune(text))), 70
+		}
+	}
+	return septets, 160
+}
+
+// SMSText returns the text message announcing an urgent notice, with the title shortened
+// so the message fits in a single SMS
+func SMSText(board, title, link string) string {
+	prefix := fmt.Sprintf("[%s] URGENT: ", board)
+	suffix := ""
+	if link != "" {
+		suffix = " " + link
+	}
+
+	text := prefix + title + suffix
+	if length, limit := smsLength(text); length <= limit {
+		return text
+	}
+	runes := []rune(strings.TrimSpace(title))
+	for n := len(runes) - 1; n >= 0; n-- {
+		// An ellipsis outside the GSM alphabet would halve the space, so use dots there
+		ellipsis := "..."
+		if _, limit := smsLength(prefix + string(runes) + suffix); limit == 70 {
+			ellipsis = "…"
+		}
+		text = prefix + strings.TrimSpace(string(runes[:n])) + ellipsis + suffix
+		if length, limit := smsLength(text); length <= limit {
+			return text
+		}
+	}
+	return text
+}
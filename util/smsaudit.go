@@ -0,0 +1,165 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Outcomes of a text message in the audit log
+const (
+	SMSSent    = "sent"
+	SMSFailed  = "failed"
+	SMSLimited = "rate_limited"
+)
+
+// smsTimestampLayout keeps audit timestamps the same length, so they sort as strings
+const smsTimestampLayout = "2006-01-02T15:04:05.000000000Z"
+
+// SMSRecord is the audit log entry of a text message sent, or not sent, to a member
+type SMSRecord struct {
+	UserId      string `json:"user_id" dynamodbav:"UserId"`
+	Timestamp   string `json:"timestamp" dynamodbav:"Timestamp"`
+	NoticeId    int    `json:"notice_id"`
+	PhoneNumber string `json:"phone_number"`
+	Status      string `json:"status"`
+	MessageId   string `json:"message_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// NewSMSRecord starts the audit log entry of a text message about the notice
+func NewSMSRecord(member *UserPreferences, noticeId int, at time.Time) *SMSRecord {
+	return &SMSRecord{
+		UserId:      member.UserId,
+		Timestamp:   at.UTC().Format(smsTimestampLayout),
+		NoticeId:    noticeId,
+		PhoneNumber: member.PhoneNumber,
+	}
+}
+
+// SMSAuditStore keeps the audit log of text messages
+type SMSAuditStore interface {
+	RecordSMS(ctx context.Context, record *SMSRecord) error
+	// CountSMS returns the number of messages sent to the user since the given time
+	CountSMS(ctx context.Context, userId string, since time.Time) (int, error)
+}
+
+// SMSAudit is the store holding the audit log of text messages
+var SMSAudit SMSAuditStore
+
+// DynamoSMSAuditStore keeps the audit log in the AWS_DYNAMO_SMS_AUDIT_TABLE_NAME table,
+// keyed by UserId and Timestamp
+type DynamoSMSAuditStore struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+// NewDynamoSMSAuditStore creates an audit store for the configured table
+func NewDynamoSMSAuditStore() *DynamoSMSAuditStore {
+	return &DynamoSMSAuditStore{
+		db:    newDynamoClient(),
+		table: os.Getenv("AWS_DYNAMO_SMS_AUDIT_TABLE_NAME"),
+	}
+}
+
+// RecordSMS adds the entry to the audit log
+func (s *DynamoSMSAuditStore) RecordSMS(ctx context.Context, record *SMSRecord) error {
+	av, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return fmt.Errorf("failed to record text message to %s: %w", record.UserId, err)
+	}
+
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record text message to %s: %w", record.UserId, err)
+	}
+	return nil
+}
+
+// CountSMS returns the number of messages sent to the user since the given time
+func (s *DynamoSMSAuditStore) CountSMS(ctx context.Context, userId string, since time.Time) (int, error) {
+	count := 0
+	err := s.db.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		KeyConditionExpression: aws.String("UserId = :user AND #timestamp >= :since"),
+		FilterExpression:       aws.String("#status = :sent"),
+		ExpressionAttributeNames: map[string]*string{
+			"#timestamp": aws.String("Timestamp"),
+			"#status":    aws.String("Status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":user":  {S: aws.String(userId)},
+			":since": {S: aws.String(since.UTC().Format(smsTimestampLayout))},
+			":sent":  {S: aws.String(SMSSent)},
+		},
+		Select: aws.String(dynamodb.SelectCount),
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		count += int(aws.Int64Value(page.Count))
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count text messages to %s: %w", userId, err)
+	}
+	return count, nil
+}
+
+// MemorySMSAuditStore is an in-memory SMSAuditStore for tests and local runs
+type MemorySMSAuditStore struct {
+	mu      sync.Mutex
+	records []SMSRecord
+}
+
+// NewMemorySMSAuditStore creates an empty in-memory audit store
+func NewMemorySMSAuditStore() *MemorySMSAuditStore {
+	return &MemorySMSAuditStore{}
+}
+
+// RecordSMS adds the entry to the audit log
+func (s *MemorySMSAuditStore) RecordSMS(ctx context.Context, record *SMSRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, *record)
+	return nil
+}
+
+// CountSMS returns the number of messages sent to the user since the given time
+func (s *MemorySMSAuditStore) CountSMS(ctx context.Context, userId string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := since.UTC().Format(smsTimestampLayout)
+	count := 0
+	for _, record := range s.records {
+		if record.UserId == userId && record.Timestamp >= from && record.Status == SMSSent {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Records returns the audit log, ordered by user and time
+func (s *MemorySMSAuditStore) Records() []SMSRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := append([]SMSRecord(nil), s.records...)
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].UserId != records[j].UserId {
+			return records[i].UserId < records[j].UserId
+		}
+		return records[i].Timestamp < records[j].Timestamp
+	})
+	return records
+}
@@ -0,0 +1,217 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Kinds of change to a notice, as read from the notices table's stream
+const (
+	ChangeCreated   = "created"
+	ChangeUpdated   = "updated"
+	ChangePublished = "published"
+	ChangeDeleted   = "deleted"
+	ChangeRestored  = "restored"
+)
+
+// NoticeChange is a change to a notice read from the notices table's stream
+type NoticeChange struct {
+	Type string `json:"type"`
+	// EventId is the stream record's ID, the same when the record is delivered again
+	EventId  string `json:"event_id"`
+	NoticeId int    `json:"notice_id"`
+	// Old is the notice before the change, nil when it was created
+	Old *Notice `json:"old,omitempty"`
+	// New is the notice after the change, nil when it was removed from the table
+	New        *Notice   `json:"new,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Notice returns the notice as it is after the change, or as it was before it was removed
+func (c *NoticeChange) Notice() *Notice {
+	if c.New != nil {
+		return c.New
+	}
+	return c.Old
+}
+
+// ClassifyNoticeChange tells what kind of change turned one version of a notice into the
+// next. Moving a notice into the trash, or removing it from the table for good, deletes
+// it and taking it out of the trash restores it. A notice that is published now, but was
+// not or did not exist before, is published; other new notices are created and the rest
+// updated.
+func ClassifyNoticeChange(before, after *Notice) string {
+	switch {
+	case after == nil:
+		return ChangeDeleted
+	case before != nil && !before.IsDeleted() && after.IsDeleted():
+		return ChangeDeleted
+	case before != nil && before.IsDeleted() && !after.IsDeleted():
+		return ChangeRestored
+	case after.EffectiveStatus() == StatusPublished && (before == nil || before.EffectiveStatus() != StatusPublished):
+		return ChangePublished
+	case before == nil:
+		return ChangeCreated
+	}
+	return ChangeUpdated
+}
+
+// DecodeNoticeChange reads the change from a stream record. The stream must include new
+// and old images.
+func DecodeNoticeChange(record events.DynamoDBEventRecord) (*NoticeChange, error) {
+	change := &NoticeChange{
+		EventId:    record.EventID,
+		OccurredAt: record.Change.ApproximateCreationDateTime.UTC(),
+	}
+
+	var err error
+	if len(record.Change.OldImage) > 0 {
+		if change.Old, err = decodeNoticeImage(record.Change.OldImage); err != nil {
+			return nil, fmt.Errorf("failed to decode old image of %s: %w", record.EventID, err)
+		}
+	}
+	if len(record.Change.NewImage) > 0 && record.EventName != string(events.DynamoDBOperationTypeRemove) {
+		if change.New, err = decodeNoticeImage(record.Change.NewImage); err != nil {
+			return nil, fmt.Errorf("failed to decode new image of %s: %w", record.EventID, err)
+		}
+	}
+	if change.Old == nil && change.New == nil {
+		return nil, fmt.Errorf("stream record %s has no notice image", record.EventID)
+	}
+
+	change.Type = ClassifyNoticeChange(change.Old, change.New)
+	change.NoticeId = change.Notice().ID
+	return change, nil
+}
+
+func decodeNoticeImage(image map[string]events.DynamoDBAttributeValue) (*Notice, error) {
+	item := make(map[string]*dynamodb.AttributeValue, len(image))
+	for name, value := range image {
+		av, err := streamAttributeValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+		item[name] = av
+	}
+
+	var notice Notice
+	err := dynamodbattribute.UnmarshalMap(item, &notice)
+	if err != nil {
+		return nil, err
+	}
+	return &notice, nil
+}
+
+// streamAttributeValue converts a stream attribute to the SDK's attribute value
+func streamAttributeValue(value events.DynamoDBAttributeValue) (*dynamodb.AttributeValue, error) {
+	switch value.DataType() {
+	case events.DataTypeString:
+		return &dynamodb.AttributeValue{S: aws.String(value.String())}, nil
+	case events.DataTypeNumber:
+		return &dynamodb.AttributeValue{N: aws.String(value.Number())}, nil
+	case events.DataTypeBinary:
+		return &dynamodb.AttributeValue{B: value.Binary()}, nil
+	case events.DataTypeBoolean:
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(value.Boolean())}, nil
+	case events.DataTypeNull:
+		return &dynamodb.AttributeValue{NULL: aws.Bool(true)}, nil
+	case events.DataTypeStringSet:
+		return &dynamodb.AttributeValue{SS: aws.StringSlice(value.StringSet())}, nil
+	case events.DataTypeNumberSet:
+		return &dynamodb.AttributeValue{NS: aws.StringSlice(value.NumberSet())}, nil
+	case events.DataTypeBinarySet:
+		return &dynamodb.AttributeValue{BS: value.BinarySet()}, nil
+	case events.DataTypeList:
+		list := make([]*dynamodb.AttributeValue, 0, len(value.List()))
+		for _, item := range value.List() {
+			av, err := streamAttributeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, av)
+		}
+		return &dynamodb.AttributeValue{L: list}, nil
+	case events.DataTypeMap:
+		m := make(map[string]*dynamodb.AttributeValue, len(value.Map()))
+		for name, item := range value.Map() {
+			av, err := streamAttributeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			m[name] = av
+		}
+		return &dynamodb.AttributeValue{M: m}, nil
+	}
+	return nil, fmt.Errorf("unsupported attribute type %v", value.DataType())
+}
+
+// NoticeChangeSubscriber reacts to changes to notices. A change is delivered again when
+// any subscriber fails on it, so subscribers must cope with seeing a change twice.
+type NoticeChangeSubscriber interface {
+	HandleNoticeChange(ctx context.Context, change *NoticeChange) error
+}
+
+// NoticeChangeFunc lets a function subscribe to changes
+type NoticeChangeFunc func(ctx context.Context, change *NoticeChange) error
+
+// HandleNoticeChange calls the function
+func (f NoticeChangeFunc) HandleNoticeChange(ctx context.Context, change *NoticeChange) error {
+	return f(ctx, change)
+}
+
+// LogNoticeChanges writes every change as a JSON line to the Lambda log, as an audit trail
+type LogNoticeChanges struct{}
+
+// HandleNoticeChange logs the change, without the notice images
+func (LogNoticeChanges) HandleNoticeChange(ctx context.Context, change *NoticeChange) error {
+	body, err := json.Marshal(NoticeChange{
+		Type:       change.Type,
+		EventId:    change.EventId,
+		NoticeId:   change.NoticeId,
+		OccurredAt: change.OccurredAt,
+	})
+	if err != nil {
+		return err
+	}
+	log.Println("change:", string(body))
+	return nil
+}
+
+// ProcessNoticeStream hands the changes in the batch to every subscriber, in order.
+// Processing stops at the first record that cannot be decoded or that a subscriber fails
+// on, which is reported as the batch item failure: Lambda retries the batch from there.
+func ProcessNoticeStream(ctx context.Context, event events.DynamoDBEvent, subscribers []NoticeChangeSubscriber) events.DynamoDBEventResponse {
+	response := events.DynamoDBEventResponse{BatchItemFailures: []events.DynamoDBBatchItemFailure{}}
+	for _, record := range event.Records {
+		err := processNoticeChange(ctx, record, subscribers)
+		if err != nil {
+			log.Printf("Failed to process stream record %s: %v", record.EventID, err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.DynamoDBBatchItemFailure{
+				ItemIdentifier: record.Change.SequenceNumber,
+			})
+			break
+		}
+	}
+	return response
+}
+
+func processNoticeChange(ctx context.Context, record events.DynamoDBEventRecord, subscribers []NoticeChangeSubscriber) error {
+	change, err := DecodeNoticeChange(record)
+	if err != nil {
+		return err
+	}
+	for _, subscriber := range subscribers {
+		if err := subscriber.HandleNoticeChange(ctx, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package util
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MaxFeedEntries is the number of notices a news feed holds
+const MaxFeedEntries = 50
+
+// Syndication is an RSS or Atom feed of notices
+type Syndication struct {
+	Id    string
+	Title string
+	// SelfURL is the address of the feed itself
+	SelfURL string
+	Notices []Notice
+	// EnclosureURL returns a lasting download link for an attachment
+	EnclosureURL func(n *Notice, a *Attachment) string
+}
+
+// NoticeGUID returns the permanent, globally unique ID of a notice in news feeds
+func NoticeGUID(n *Notice) string {
+	return fmt.Sprintf("urn:noticeboard:notice:%d", n.ID)
+}
+
+// PublishedAt returns when the notice was published: when it passed the publish step of
+// the review workflow or, if later, its publish_at time. Notices from before the workflow
+// count as published when they were created.
+func (n *Notice) PublishedAt() time.Time {
+	published := n.CreatedAt
+	for _, comment := range n.ReviewComments {
+		if comment.Action == "publish" && comment.CreatedAt.After(published) {
+			published = comment.CreatedAt
+		}
+	}
+	if n.PublishAt != nil && n.PublishAt.After(published) {
+		published = *n.PublishAt
+	}
+	return published
+}
+
+// SortForFeed orders notices newest published first and keeps the most recent MaxFeedEntries
+func SortForFeed(notices []Notice) []Notice {
+	sort.SliceStable(notices, func(i, j int) bool {
+		a, b := notices[i].PublishedAt(), notices[j].PublishedAt()
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return notices[i].ID > notices[j].ID
+	})
+	if len(notices) > MaxFeedEntries {
+		notices = notices[:MaxFeedEntries]
+	}
+	return notices
+}
+
+// Updated returns when a notice in the feed last changed
+func (s *Syndication) Updated() time.Time {
+	var updated time.Time
+	for i := range s.Notices {
+		if s.Notices[i].UpdatedAt.After(updated) {
+			updated = s.Notices[i].UpdatedAt
+		}
+	}
+	return updated
+}
+
+type rssFeed struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomSpace string     `xml:"xmlns:atom,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string         `xml:"title"`
+	Description string         `xml:"description,omitempty"`
+	Guid        rssGuid        `xml:"guid"`
+	PubDate     string         `xml:"pubDate"`
+	Updated     string         `xml:"atom:updated"`
+	Category    string         `xml:"category,omitempty"`
+	Enclosures  []rssEnclosure `xml:"enclosure"`
+}
+
+type rssGuid struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// RSS renders the feed as RSS 2.0, with the rendered HTML of each notice as its description
+func (s *Syndication) RSS() ([]byte, error) {
+	feed := rssFeed{
+		Version:   "2.0",
+		AtomSpace: "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       s.Title,
+			Link:        s.SelfURL,
+			Description: s.Title,
+			Self:        atomLink{Href: s.SelfURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if updated := s.Updated(); !updated.IsZero() {
+		feed.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for i := range s.Notices {
+		n := &s.Notices[i]
+		item := rssItem{
+			Title:       n.Title,
+			Description: n.ContentHTML,
+			Guid:        rssGuid{IsPermaLink: "false", Value: NoticeGUID(n)},
+			PubDate:     n.PublishedAt().UTC().Format(time.RFC1123Z),
+			Updated:     n.UpdatedAt.UTC().Format(time.RFC3339),
+			Category:    n.Category,
+		}
+		for j := range n.Attachments {
+			a := &n.Attachments[j]
+			item.Enclosures = append(item.Enclosures, rssEnclosure{URL: s.EnclosureURL(n, a), Length: a.Size, Type: a.ContentType})
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	return marshalFeed(feed)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Self    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Id         string        `xml:"id"`
+	Title      string        `xml:"title"`
+	Published  string        `xml:"published"`
+	Updated    string        `xml:"updated"`
+	Author     *atomAuthor   `xml:"author,omitempty"`
+	Category   *atomCategory `xml:"category,omitempty"`
+	Content    atomContent   `xml:"content"`
+	Enclosures []atomLink    `xml:"link"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Title  string `xml:"title,attr,omitempty"`
+	Length string `xml:"length,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Atom renders the feed as Atom (RFC 4287), with the rendered HTML of each notice as its content
+func (s *Syndication) Atom() ([]byte, error) {
+	// A feed without entries has not been updated since the epoch
+	updated := s.Updated()
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	feed := atomFeed{
+		Id:      s.Id,
+		Title:   s.Title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Self:    atomLink{Href: s.SelfURL, Rel: "self", Type: "application/atom+xml"},
+		Author:  atomAuthor{Name: s.Title},
+	}
+
+	for i := range s.Notices {
+		n := &s.Notices[i]
+		entry := atomEntry{
+			Id:        NoticeGUID(n),
+			Title:     n.Title,
+			Published: n.PublishedAt().UTC().Format(time.RFC3339),
+			Updated:   n.UpdatedAt.UTC().Format(time.RFC3339),
+			Content:   atomContent{Type: "html", Value: n.ContentHTML},
+		}
+		if n.Author != "" {
+			entry.Author = &atomAuthor{Name: n.Author}
+		}
+		if n.Category != "" {
+			entry.Category = &atomCategory{Term: n.Category}
+		}
+		for j := range n.Attachments {
+			a := &n.Attachments[j]
+			entry.Enclosures = append(entry.Enclosures, atomLink{
+				Href:   s.EnclosureURL(n, a),
+				Rel:    "enclosure",
+				Type:   a.ContentType,
+				Title:  a.FileName,
+				Length: strconv.FormatInt(a.Size, 10),
+			})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return marshalFeed(feed)
+}
+
+func marshalFeed(feed interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode feed: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,172 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Session token scopes
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	// ScopeCalendar and ScopeFeed only grant reading the calendar and news feeds,
+	// whose URLs hold the token
+	ScopeCalendar = "calendar"
+	ScopeFeed     = "feed"
+)
+
+// sessionTokenVersion prefixes every token so the format can change later
+const sessionTokenVersion = "v1"
+
+var (
+	// ErrInvalidToken is returned when a token is malformed or its signature does not match
+	ErrInvalidToken = errors.New("invalid session token")
+	// ErrExpiredToken is returned when a token is past its expiry time
+	ErrExpiredToken = errors.New("session token expired")
+	// ErrMissingTokenSecret is returned when SESSION_TOKEN_SECRET is not configured
+	ErrMissingTokenSecret = errors.New("SESSION_TOKEN_SECRET is not set")
+)
+
+// SessionClaims holds the information carried by a signed session token
+type SessionClaims struct {
+	Subject        string   `json:"sub"`
+	Username       string   `json:"username,omitempty"`
+	Email          string   `json:"email,omitempty"`
+	PhoneNumber    string   `json:"phone_number,omitempty"`
+	Groups         []string `json:"groups,omitempty"`
+	CongregationId string   `json:"congregation_id,omitempty"`
+	Scopes         []string `json:"scopes"`
+	// Device is set for display devices authenticated with an API key
+	Device    bool  `json:"device,omitempty"`
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// HasScope reports whether the claims grant the given scope
+func (c *SessionClaims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// InGroup reports whether the claims carry the given Cognito group
+func (c *SessionClaims) InGroup(group string) bool {
+	for _, g := range c.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Cognito groups with elevated permissions. Admins and coordinators act within their own
+// congregation; platform admins run the deployment and manage every congregation.
+const (
+	GroupAdmins         = "admins"
+	GroupCoordinators   = "coordinators"
+	GroupPlatformAdmins = "platform-admins"
+)
+
+// GenerateSessionToken signs the claims with SESSION_TOKEN_SECRET and returns a token valid for ttl.
+// IssuedAt and ExpiresAt are always set from the current time.
+func GenerateSessionToken(claims SessionClaims, ttl time.Duration) (string, error) {
+	secret, err := sessionTokenSecret()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+
+	// Encode the claims as the token payload
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session claims: %w", err)
+	}
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+
+	// Sign the version and payload together
+	signingInput := sessionTokenVersion + "." + encodedPayload
+	signature := signSessionToken(secret, signingInput)
+
+	return signingInput + "." + signature, nil
+}
+
+// VerifySessionToken checks the signature and expiry of a token and returns its claims
+func VerifySessionToken(token string) (*SessionClaims, error) {
+	secret, err := sessionTokenSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != sessionTokenVersion {
+		return nil, ErrInvalidToken
+	}
+
+	// Compare signatures in constant time
+	expected := signSessionToken(secret, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims SessionClaims
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+// SessionTokenTTL returns the lifetime of interactive session tokens, read from SESSION_TOKEN_TTL
+func SessionTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("SESSION_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return 12 * time.Hour
+	}
+	return ttl
+}
+
+// FeedTokenTTL returns the lifetime of calendar and news feed tokens, read from FEED_TOKEN_TTL
+func FeedTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("FEED_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return 365 * 24 * time.Hour
+	}
+	return ttl
+}
+
+func sessionTokenSecret() ([]byte, error) {
+	secret := os.Getenv("SESSION_TOKEN_SECRET")
+	if secret == "" {
+		return nil, ErrMissingTokenSecret
+	}
+	return []byte(secret), nil
+}
+
+func signSessionToken(secret []byte, input string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionTokenRoundTrip(t *testing.T) {
+	os.Setenv("SESSION_TOKEN_SECRET", "test-secret")
+
+	claims := SessionClaims{
+		Subject: "1234-abcd",
+		Groups:  []string{"coordinators"},
+		Scopes:  []string{ScopeRead},
+	}
+
+	token, err := GenerateSessionToken(claims, time.Hour)
+	assert.NoError(t, err)
+
+	verified, err := VerifySessionToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "1234-abcd", verified.Subject)
+	assert.True(t, verified.HasScope(ScopeRead))
+	assert.False(t, verified.HasScope(ScopeWrite))
+	assert.True(t, verified.InGroup("coordinators"))
+}
+
+func TestSessionTokenRejectsTampering(t *testing.T) {
+	os.Setenv("SESSION_TOKEN_SECRET", "test-secret")
+
+	token, err := GenerateSessionToken(SessionClaims{Subject: "1234", Scopes: []string{ScopeRead}}, time.Hour)
+	assert.NoError(t, err)
+
+	// Swap the payload for one granting write access
+	forged, _ := GenerateSessionToken(SessionClaims{Subject: "1234", Scopes: []string{ScopeWrite}}, time.Hour)
+	parts := strings.Split(token, ".")
+	forgedParts := strings.Split(forged, ".")
+	_, err = VerifySessionToken(parts[0] + "." + forgedParts[1] + "." + parts[2])
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// A token signed with another secret is rejected
+	os.Setenv("SESSION_TOKEN_SECRET", "other-secret")
+	_, err = VerifySessionToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestSessionTokenExpiry(t *testing.T) {
+	os.Setenv("SESSION_TOKEN_SECRET", "test-secret")
+
+	token, err := GenerateSessionToken(SessionClaims{Subject: "1234"}, -time.Minute)
+	assert.NoError(t, err)
+
+	_, err = VerifySessionToken(token)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+}
@@ -0,0 +1,27 @@
+package util
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// TrashRetention returns how long notices stay in the trash before they are purged,
+// read from TRASH_RETENTION_DAYS and defaulting to 30 days
+func TrashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// IsDeleted reports whether the notice is in the trash
+func (n *Notice) IsDeleted() bool {
+	return n.DeletedAt != nil
+}
+
+// PurgeDueAt reports whether the notice has been in the trash longer than the retention
+func (n *Notice) PurgeDueAt(now time.Time, retention time.Duration) bool {
+	return n.IsDeleted() && now.Sub(*n.DeletedAt) > retention
+}
@@ -0,0 +1,57 @@
+package util
+
+import (
+	"context"
+	"fmt"
+)
+
+// ValidationError describes why a notice cannot be saved, in words fit for the client
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ValidateNotice checks the fields a client sets on a notice and normalises its tags.
+// Problems with the notice are returned as a *ValidationError.
+func ValidateNotice(ctx context.Context, notice *Notice) error {
+	if notice.Title == "" {
+		return &ValidationError{Message: "title is required"}
+	}
+
+	// Translations are keyed by well-formed language tags, other than the notice's own
+	err := validateTranslations(notice)
+	if err != nil {
+		return err
+	}
+
+	if !ValidPriority(notice.Priority) {
+		return &ValidationError{Message: "priority must be normal, important or urgent"}
+	}
+
+	// Events need a start, a valid time zone and a valid recurrence rule
+	err = validateEvent(ctx, notice)
+	if err != nil {
+		return err
+	}
+
+	// A notice must expire after it is published
+	if notice.PublishAt != nil && notice.ExpireAt != nil && !notice.ExpireAt.After(*notice.PublishAt) {
+		return &ValidationError{Message: "expire_at must be after publish_at"}
+	}
+
+	// Only categories from the allowed list may be used
+	notice.Tags = NormalizeTags(notice.Tags)
+	if notice.Category != "" {
+		allowed, err := IsAllowedCategory(ctx, Categories, notice.Category)
+		if err != nil {
+			return fmt.Errorf("failed to load categories: %w", err)
+		}
+		if !allowed {
+			return &ValidationError{Message: fmt.Sprintf("Unknown category: %s", notice.Category)}
+		}
+	}
+	return nil
+}
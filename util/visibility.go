@@ -0,0 +1,64 @@
+package util
+
+import "time"
+
+// IsEditor reports whether the session may see and manage notices members cannot see yet
+func IsEditor(claims *SessionClaims) bool {
+	if claims == nil || claims.Device {
+		return false
+	}
+	return claims.InGroup(GroupCoordinators) || claims.InGroup(GroupAdmins)
+}
+
+// IsPlatformAdmin reports whether the session may manage every congregation
+func IsPlatformAdmin(claims *SessionClaims) bool {
+	return claims != nil && !claims.Device && claims.InGroup(GroupPlatformAdmins)
+}
+
+// ManagesCongregation reports whether the session may manage the congregation's settings,
+// members and display devices: platform admins manage all of them, admins their own
+func ManagesCongregation(claims *SessionClaims, congregationId string) bool {
+	if IsPlatformAdmin(claims) {
+		return true
+	}
+	return claims != nil && !claims.Device && claims.InGroup(GroupAdmins) && claims.CongregationId == congregationId
+}
+
+// InCongregation reports whether the notice belongs to the session's congregation.
+// Sessions and notices from before congregations existed both have none, and match.
+func InCongregation(claims *SessionClaims, notice *Notice) bool {
+	congregationId := ""
+	if claims != nil {
+		congregationId = claims.CongregationId
+	}
+	return notice.CongregationId == congregationId
+}
+
+// NoticeVisibleTo reports whether the session may see the notice at the given time.
+// Nobody sees the notices of another congregation. Within their own, editors see
+// every notice and authors see their own; members and display devices only see
+// published notices that are live.
+func NoticeVisibleTo(claims *SessionClaims, notice *Notice, now time.Time) bool {
+	// Notices in the trash are only shown through the trash listing
+	if notice.IsDeleted() || !InCongregation(claims, notice) {
+		return false
+	}
+	if IsEditor(claims) {
+		return true
+	}
+	if claims != nil && !claims.Device && notice.AuthorId != "" && notice.AuthorId == claims.Subject {
+		return true
+	}
+	return notice.EffectiveStatus() == StatusPublished && notice.IsLiveAt(now)
+}
+
+// VisibleNotices filters the notices down to those the session may see
+func VisibleNotices(claims *SessionClaims, notices []Notice, now time.Time) []Notice {
+	visible := make([]Notice, 0, len(notices))
+	for i := range notices {
+		if NoticeVisibleTo(claims, &notices[i], now) {
+			visible = append(visible, notices[i])
+		}
+	}
+	return visible
+}
@@ -0,0 +1,340 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Urgency of push messages, telling the push service how soon to wake the device
+const (
+	PushUrgencyNormal = "normal"
+	PushUrgencyHigh   = "high"
+)
+
+// pushRecordSize is the record size of encrypted messages. Push services accept bodies
+// of up to 4096 bytes, so a message is a single record.
+const pushRecordSize = 4096
+
+// MaxPushPayload is the largest payload that fits a push message after encryption: the
+// record size less the header, the authentication tag and the padding delimiter
+const MaxPushPayload = pushRecordSize - 86 - 16 - 1
+
+var (
+	// ErrPushSubscriptionGone is returned when the push service no longer knows the
+	// subscription, which should then be removed
+	ErrPushSubscriptionGone = errors.New("push subscription expired or unsubscribed")
+	// ErrMissingVAPIDKey is returned when VAPID_PRIVATE_KEY is not configured
+	ErrMissingVAPIDKey = errors.New("VAPID_PRIVATE_KEY is not set")
+)
+
+// PushMessage is a Web Push message to one subscription
+type PushMessage struct {
+	Subscription *PushSubscription
+	Payload      []byte
+	// TTL is how long the push service keeps the message for an offline device
+	TTL time.Duration
+	// Urgency is PushUrgencyNormal or PushUrgencyHigh
+	Urgency string
+	// Topic replaces an undelivered message with the same topic
+	Topic string
+}
+
+// PushSender delivers Web Push messages. It returns ErrPushSubscriptionGone for
+// subscriptions the push service has dropped.
+type PushSender interface {
+	Send(ctx context.Context, message PushMessage) error
+}
+
+// Push is the sender push notifications go out through
+var Push PushSender
+
+// NewPushSender returns the configured sender: Web Push, or with PUSH_SENDER set to log,
+// a LogPushSender for local development
+func NewPushSender() PushSender {
+	if os.Getenv("PUSH_SENDER") == "log" {
+		return LogPushSender{}
+	}
+	return NewWebPushSender()
+}
+
+// VAPIDKeys identify the application server to push services (RFC 8292)
+type VAPIDKeys struct {
+	private *ecdsa.PrivateKey
+	// PublicKey is the uncompressed P-256 public key, which browsers take as the
+	// applicationServerKey when subscribing
+	PublicKey []byte
+	// Subject is a mailto: or https: contact address for the push service operators
+	Subject string
+}
+
+// LoadVAPIDKeys reads the key pair from VAPID_PRIVATE_KEY, the P-256 private key in
+// unpadded base64url as generated by most Web Push libraries, and the contact address
+// from VAPID_SUBJECT
+func LoadVAPIDKeys() (*VAPIDKeys, error) {
+	encoded := os.Getenv("VAPID_PRIVATE_KEY")
+	if encoded == "" {
+		return nil, ErrMissingVAPIDKey
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID_PRIVATE_KEY: %w", err)
+	}
+	keys, err := NewVAPIDKeys(raw, os.Getenv("VAPID_SUBJECT"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID_PRIVATE_KEY: %w", err)
+	}
+	return keys, nil
+}
+
+// NewVAPIDKeys creates the key pair from the 32-byte private key
+func NewVAPIDKeys(private []byte, subject string) (*VAPIDKeys, error) {
+	key, err := ecdh.P256().NewPrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	public := key.PublicKey().Bytes()
+	return &VAPIDKeys{
+		private: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: new(big.Int).SetBytes(private),
+		},
+		PublicKey: public,
+		Subject:   subject,
+	}, nil
+}
+
+// PublicKeyString returns the public key in unpadded base64url
+func (k *VAPIDKeys) PublicKeyString() string {
+	return base64.RawURLEncoding.EncodeToString(k.PublicKey)
+}
+
+// Authorization returns the Authorization header for a message to the endpoint: a
+// JWT signed with ES256 for the endpoint's origin, valid for 12 hours
+func (k *VAPIDKeys) Authorization(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid push endpoint: %w", err)
+	}
+
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims := map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(12 * time.Hour).Unix(),
+	}
+	if k.Subject != "" {
+		claims["sub"] = k.Subject
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign VAPID token: %w", err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, k.PublicKeyString()), nil
+}
+
+// EncryptPushPayload encrypts the payload for the subscription with the aes128gcm
+// content coding, as Web Push requires (RFC 8291)
+func EncryptPushPayload(subscription *PushSubscription, payload []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return encryptPushPayload(subscription, payload, serverKey, salt)
+}
+
+func encryptPushPayload(subscription *PushSubscription, payload []byte, serverKey *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	if len(payload) > MaxPushPayload {
+		return nil, fmt.Errorf("push payload of %d bytes exceeds %d bytes", len(payload), MaxPushPayload)
+	}
+	userPublic, authSecret, err := subscription.Keys.decode()
+	if err != nil {
+		return nil, err
+	}
+	userKey, err := ecdh.P256().NewPublicKey(userPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	sharedSecret, err := serverKey.ECDH(userKey)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	// Combine the shared secret with the subscription's authentication secret
+	keyInfo := append([]byte("WebPush: info\x00"), userPublic...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm := hkdf(authSecret, sharedSecret, keyInfo, 32)
+
+	// Derive the content encryption key and nonce (RFC 8188)
+	cek := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single record, ended by the last record delimiter
+	plaintext := append(append([]byte(nil), payload...), 0x02)
+
+	header := make([]byte, 0, 86)
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// hkdf derives a key of up to 32 bytes with HKDF-SHA-256 (RFC 5869)
+func hkdf(salt, secret, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{0x01})
+	return expand.Sum(nil)[:length]
+}
+
+// WebPushSender posts encrypted messages to the subscriptions' push services, signed
+// with the VAPID keys from VAPID_PRIVATE_KEY and VAPID_SUBJECT
+type WebPushSender struct {
+	client *http.Client
+	keys   *VAPIDKeys
+	err    error
+}
+
+// NewWebPushSender creates a sender for the configured VAPID keys. Missing or invalid
+// keys are reported when sending.
+func NewWebPushSender() *WebPushSender {
+	keys, err := LoadVAPIDKeys()
+	return &WebPushSender{
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   keys,
+		err:    err,
+	}
+}
+
+// Send encrypts and delivers the message
+func (s *WebPushSender) Send(ctx context.Context, message PushMessage) error {
+	if s.err != nil {
+		return s.err
+	}
+	body, err := EncryptPushPayload(message.Subscription, message.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt push message: %w", err)
+	}
+	authorization, err := s.keys.Authorization(message.Subscription.Endpoint, time.Now())
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, message.Subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send push message: %w", err)
+	}
+	request.Header.Set("Authorization", authorization)
+	request.Header.Set("Content-Encoding", "aes128gcm")
+	request.Header.Set("Content-Type", "application/octet-stream")
+	request.Header.Set("TTL", strconv.Itoa(int(message.TTL.Seconds())))
+	if message.Urgency != "" {
+		request.Header.Set("Urgency", message.Urgency)
+	}
+	if message.Topic != "" {
+		request.Header.Set("Topic", message.Topic)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send push message: %w", err)
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone:
+		return ErrPushSubscriptionGone
+	case response.StatusCode >= 300:
+		return fmt.Errorf("failed to send push message: push service returned %s", response.Status)
+	}
+	return nil
+}
+
+// LogPushSender writes push messages to the log instead of sending them
+type LogPushSender struct{}
+
+// Send logs the push message
+func (LogPushSender) Send(ctx context.Context, message PushMessage) error {
+	log.Printf("push to %s: %s", message.Subscription.Endpoint, message.Payload)
+	return nil
+}
+
+// MemoryPushSender captures push messages for tests. Endpoints in Gone are reported
+// as dropped by the push service.
+type MemoryPushSender struct {
+	Gone     map[string]bool
+	mu       sync.Mutex
+	messages []PushMessage
+}
+
+// Send records the push message
+func (s *MemoryPushSender) Send(ctx context.Context, message PushMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Gone[message.Subscription.Endpoint] {
+		return ErrPushSubscriptionGone
+	}
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// Sent returns the push messages recorded so far
+func (s *MemoryPushSender) Sent() []PushMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]PushMessage(nil), s.messages...)
+}
@@ -0,0 +1,182 @@
+package util
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSubscription creates a subscription the way a browser would, returning the
+// browser's private key to decrypt messages with
+func newTestSubscription(t *testing.T, userId, endpoint string) (*PushSubscription, *ecdh.PrivateKey, []byte) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	auth := make([]byte, 16)
+	_, err = rand.Read(auth)
+	require.NoError(t, err)
+
+	return &PushSubscription{
+		UserId:   userId,
+		Endpoint: endpoint,
+		Keys: PushKeys{
+			P256dh: base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString(auth),
+		},
+	}, key, auth
+}
+
+// decryptPushPayload decrypts a message as the browser does
+func decryptPushPayload(t *testing.T, body []byte, key *ecdh.PrivateKey, auth []byte) []byte {
+	salt := body[:16]
+	assert.Equal(t, uint32(4096), binary.BigEndian.Uint32(body[16:20]))
+	serverPublic := body[21 : 21+int(body[20])]
+	ciphertext := body[21+int(body[20]):]
+
+	serverKey, err := ecdh.P256().NewPublicKey(serverPublic)
+	require.NoError(t, err)
+	sharedSecret, err := key.ECDH(serverKey)
+	require.NoError(t, err)
+
+	keyInfo := append([]byte("WebPush: info\x00"), key.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm := hkdf(auth, sharedSecret, keyInfo, 32)
+	block, err := aes.NewCipher(hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16))
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	plaintext, err := gcm.Open(nil, hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12), ciphertext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x02), plaintext[len(plaintext)-1])
+	return plaintext[:len(plaintext)-1]
+}
+
+func TestEncryptPushPayload(t *testing.T) {
+	subscription, key, auth := newTestSubscription(t, "user", "https://push.example.org/send/1")
+
+	body, err := EncryptPushPayload(subscription, []byte(`{"title":"Hall closed"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"title":"Hall closed"}`, string(decryptPushPayload(t, body, key, auth)))
+
+	// Every message has its own salt and key
+	again, err := EncryptPushPayload(subscription, []byte(`{"title":"Hall closed"}`))
+	assert.NoError(t, err)
+	assert.NotEqual(t, body, again)
+
+	_, err = EncryptPushPayload(subscription, make([]byte, MaxPushPayload+1))
+	assert.Error(t, err)
+	body, err = EncryptPushPayload(subscription, make([]byte, MaxPushPayload))
+	assert.NoError(t, err)
+	assert.Len(t, body, 4096)
+}
+
+func TestVAPIDKeys_Authorization(t *testing.T) {
+	t.Setenv("VAPID_PRIVATE_KEY", "")
+	_, err := LoadVAPIDKeys()
+	assert.ErrorIs(t, err, ErrMissingVAPIDKey)
+
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	t.Setenv("VAPID_PRIVATE_KEY", base64.RawURLEncoding.EncodeToString(private.Bytes()))
+	t.Setenv("VAPID_SUBJECT", "mailto:admin@example.org")
+	keys, err := LoadVAPIDKeys()
+	require.NoError(t, err)
+	assert.Equal(t, private.PublicKey().Bytes(), keys.PublicKey)
+
+	now := time.Unix(1700000000, 0)
+	authorization, err := keys.Authorization("https://push.example.org/send/1?x=y", now)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(authorization, "vapid t="))
+	assert.True(t, strings.HasSuffix(authorization, ", k="+keys.PublicKeyString()))
+
+	// The token is an ES256 JWT for the push service's origin
+	token := strings.TrimSuffix(strings.TrimPrefix(authorization, "vapid t="), ", k="+keys.PublicKeyString())
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"aud":"https://push.example.org","exp":1700043200,"sub":"mailto:admin@example.org"}`, string(claims))
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(&keys.private.PublicKey, digest[:], r, s))
+}
+
+func TestPushNotifier(t *testing.T) {
+	Congregations = NewMemoryCongregationStore()
+	Preferences = NewMemoryPreferencesStore()
+	PushSubscriptions = NewMemoryPushSubscriptionStore()
+	push := &MemoryPushSender{Gone: map[string]bool{"https://push.example.org/gone": true}}
+	Push = push
+	t.Setenv("NOTICEBOARD_URL", "https://board.example.org")
+
+	ctx := context.Background()
+	for _, preferences := range []*UserPreferences{
+		{UserId: "phone", CongregationId: "north", Channels: []string{ChannelPush}},
+		{UserId: "email", CongregationId: "north", Channels: []string{ChannelEmail}},
+	} {
+		err := Preferences.PutPreferences(ctx, preferences)
+		assert.NoError(t, err)
+	}
+	for _, endpoint := range []string{"https://push.example.org/gone", "https://push.example.org/phone"} {
+		subscription, _, _ := newTestSubscription(t, "phone", endpoint)
+		err := PushSubscriptions.PutPushSubscription(ctx, subscription)
+		assert.NoError(t, err)
+	}
+	subscription, _, _ := newTestSubscription(t, "email", "https://push.example.org/email")
+	err := PushSubscriptions.PutPushSubscription(ctx, subscription)
+	assert.NoError(t, err)
+
+	notice := &Notice{ID: 7, Title: "Cleaning", Content: "Group\n\n**2** cleans the hall", CongregationId: "north", Status: StatusPublished}
+	notifier := PushNotifier{}
+
+	// Published notices reach every device; dropped subscriptions are removed
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticePublished, notice))
+	assert.NoError(t, err)
+	sent := push.Sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "https://push.example.org/phone", sent[0].Subscription.Endpoint)
+	assert.Equal(t, PushUrgencyNormal, sent[0].Urgency)
+	assert.Equal(t, "notice-7", sent[0].Topic)
+	var payload map[string]interface{}
+	err = json.Unmarshal(sent[0].Payload, &payload)
+	assert.NoError(t, err)
+	assert.Equal(t, "published", payload["type"])
+	assert.Equal(t, "Group **2** cleans the hall", payload["body"])
+	assert.Equal(t, "https://board.example.org/notices/7", payload["url"])
+
+	subscriptions, err := PushSubscriptions.ListPushSubscriptions(ctx, "phone")
+	assert.NoError(t, err)
+	assert.Len(t, subscriptions, 1)
+
+	// Updates are pushed too, urgent ones with high urgency
+	notice.Priority = PriorityUrgent
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticeUpdated, notice))
+	assert.NoError(t, err)
+	sent = push.Sent()
+	require.Len(t, sent, 2)
+	assert.Equal(t, PushUrgencyHigh, sent[1].Urgency)
+	assert.Contains(t, string(sent[1].Payload), `"type":"updated"`)
+
+	// Drafts are not pushed
+	draft := &Notice{ID: 8, Title: "Draft", CongregationId: "north", Status: StatusDraft}
+	err = notifier.Publish(ctx, NewNoticeEvent(EventNoticeUpdated, draft))
+	assert.NoError(t, err)
+	assert.Len(t, push.Sent(), 2)
+}
@@ -0,0 +1,118 @@
+package util
+
+import (
+	"errors"
+	"time"
+)
+
+// Review statuses of a notice
+const (
+	StatusDraft     = "draft"
+	StatusSubmitted = "submitted"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+var (
+	// ErrUnknownAction is returned for transition actions that do not exist
+	ErrUnknownAction = errors.New("unknown action")
+	// ErrTransitionNotAllowed is returned when the action does not apply to the current status
+	ErrTransitionNotAllowed = errors.New("action not allowed in the current status")
+	// ErrNotApprover is returned when someone other than a coordinator or admin reviews a notice
+	ErrNotApprover = errors.New("only coordinators and admins may do this")
+	// ErrNotAuthor is returned when someone other than the author or an editor submits a notice
+	ErrNotAuthor = errors.New("only the author or an editor may do this")
+	// ErrCommentRequired is returned when rejecting a notice without saying why
+	ErrCommentRequired = errors.New("a comment is required")
+)
+
+// ReviewComment is a note left on a notice when its status changes
+type ReviewComment struct {
+	Author    string    `json:"author"`
+	Action    string    `json:"action"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type transition struct {
+	from            string
+	to              string
+	approverOnly    bool
+	commentRequired bool
+}
+
+// transitions lists the workflow actions:
+// draft → submitted → approved/rejected → published → archived, with rejected notices revised back to draft
+var transitions = map[string]transition{
+	"submit":  {from: StatusDraft, to: StatusSubmitted},
+	"approve": {from: StatusSubmitted, to: StatusApproved, approverOnly: true},
+	"reject":  {from: StatusSubmitted, to: StatusRejected, approverOnly: true, commentRequired: true},
+	"revise":  {from: StatusRejected, to: StatusDraft},
+	"publish": {from: StatusApproved, to: StatusPublished, approverOnly: true},
+	"archive": {from: StatusPublished, to: StatusArchived, approverOnly: true},
+}
+
+// EffectiveStatus returns the review status, treating notices from before the workflow as published
+func (n *Notice) EffectiveStatus() string {
+	if n.Status == "" {
+		return StatusPublished
+	}
+	return n.Status
+}
+
+// PlanTransition checks whether the session may apply the action to the notice
+// and returns the status the notice moves from and to
+func PlanTransition(notice *Notice, action, comment string, claims *SessionClaims) (string, string, error) {
+	t, ok := transitions[action]
+	if !ok {
+		return "", "", ErrUnknownAction
+	}
+
+	from := notice.EffectiveStatus()
+	if from != t.from {
+		return "", "", ErrTransitionNotAllowed
+	}
+
+	if t.approverOnly && !IsEditor(claims) {
+		return "", "", ErrNotApprover
+	}
+	if !t.approverOnly && !IsEditor(claims) && (claims == nil || notice.AuthorId != claims.Subject) {
+		return "", "", ErrNotAuthor
+	}
+	if t.commentRequired && comment == "" {
+		return "", "", ErrCommentRequired
+	}
+
+	return notice.Status, t.to, nil
+}
+
+// CanViewHistory reports whether the session may read the revisions of a notice. They
+// hold earlier drafts and the review comments, so only editors and the author may.
+func CanViewHistory(claims *SessionClaims, notice *Notice) bool {
+	if !InCongregation(claims, notice) {
+		return false
+	}
+	if IsEditor(claims) {
+		return true
+	}
+	return claims != nil && !claims.Device && notice.AuthorId != "" && notice.AuthorId == claims.Subject
+}
+
+// CanEditNotice reports whether the session may change the content of a notice.
+// Editors may change any notice of their congregation; authors only their own drafts
+// and rejected notices.
+func CanEditNotice(claims *SessionClaims, notice *Notice) bool {
+	if !InCongregation(claims, notice) {
+		return false
+	}
+	if IsEditor(claims) {
+		return true
+	}
+	if claims == nil || notice.AuthorId == "" || notice.AuthorId != claims.Subject {
+		return false
+	}
+	status := notice.EffectiveStatus()
+	return status == StatusDraft || status == StatusRejected
+}